@@ -0,0 +1,198 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RemediationPhase is the phase of a HivelocityRemediation.
+type RemediationPhase string
+
+const (
+	// RemediationPhasePending means that the remediation has not started yet.
+	RemediationPhasePending RemediationPhase = "Pending"
+
+	// RemediationPhaseRateLimited means that the node has been remediated recently.
+	// The remediation starts after the minimal interval between two remediations.
+	RemediationPhaseRateLimited RemediationPhase = "RateLimited"
+
+	// RemediationPhasePowerCycling means that the device was power-cycled and
+	// the controller waits for the node to become ready.
+	RemediationPhasePowerCycling RemediationPhase = "PowerCycling"
+
+	// RemediationPhaseReloading means that the operating system of the device
+	// gets reloaded and the controller waits for the node to become ready.
+	RemediationPhaseReloading RemediationPhase = "Reloading"
+
+	// RemediationPhaseSucceeded means that the node is ready again.
+	RemediationPhaseSucceeded RemediationPhase = "Succeeded"
+
+	// RemediationPhaseFailed means that the node did not become ready again.
+	RemediationPhaseFailed RemediationPhase = "Failed"
+
+	// RemediationPhaseDryRun means that the remediation was only logged because dryRun is set.
+	RemediationPhaseDryRun RemediationPhase = "DryRun"
+)
+
+// Condition types of a HivelocityRemediation. They follow the conventions of
+// the medik8s Node Health Check operator.
+const (
+	// RemediationConditionProcessing is true while the remediation is in progress.
+	RemediationConditionProcessing = "Processing"
+
+	// RemediationConditionSucceeded is true if the node is ready after the remediation.
+	RemediationConditionSucceeded = "Succeeded"
+)
+
+// HivelocityRemediationSpec defines how an unhealthy node gets remediated.
+// The device gets power-cycled first. If the node does not become ready in time,
+// the operating system gets reloaded if reload is configured.
+type HivelocityRemediationSpec struct {
+	// PowerCycleTimeout is the time to wait for the node to become ready after the power-cycle.
+	// Defaults to 10m.
+	// +optional
+	PowerCycleTimeout *metav1.Duration `json:"powerCycleTimeout,omitempty"`
+
+	// Reload escalates the remediation to an OS reload if the power-cycle does not help.
+	// All data on the device gets lost. The remediation fails without escalation if unset.
+	// +optional
+	Reload *ReloadSpec `json:"reload,omitempty"`
+
+	// DryRun only records what would be done, without changing the device.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// ReloadSpec contains the parameters for reloading the operating system of a device.
+type ReloadSpec struct {
+	// OperatingSystemID is the ID of the operating system product option.
+	OperatingSystemID int32 `json:"operatingSystemID"`
+
+	// IgnitionID is the ID of an Ignition file for Flatcar provisions.
+	// +optional
+	IgnitionID int32 `json:"ignitionID,omitempty"`
+
+	// Script is a cloud-init or post-install script.
+	// +optional
+	Script string `json:"script,omitempty"`
+
+	// PublicSSHKeyIDs are the IDs of the public SSH keys which get installed.
+	// +optional
+	PublicSSHKeyIDs []int32 `json:"publicSSHKeyIDs,omitempty"`
+
+	// Timeout is the time to wait for the node to become ready after the reload.
+	// Defaults to 60m.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// HivelocityRemediationStatus defines the observed state of HivelocityRemediation.
+type HivelocityRemediationStatus struct {
+	// Phase is the current phase of the remediation.
+	// +optional
+	Phase RemediationPhase `json:"phase,omitempty"`
+
+	// DeviceID is the Hivelocity device of the node.
+	// +optional
+	DeviceID int32 `json:"deviceID,omitempty"`
+
+	// PowerCycleTime is the time the device was power-cycled.
+	// +optional
+	PowerCycleTime *metav1.Time `json:"powerCycleTime,omitempty"`
+
+	// ReloadTime is the time the reload of the operating system was started.
+	// +optional
+	ReloadTime *metav1.Time `json:"reloadTime,omitempty"`
+
+	// Message describes the current phase.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Conditions contains the Processing and Succeeded conditions.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=hvr
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Device",type=integer,JSONPath=`.status.deviceID`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// HivelocityRemediation remediates the node with the same name. It is usually
+// created by the Node Health Check operator from a HivelocityRemediationTemplate.
+type HivelocityRemediation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HivelocityRemediationSpec   `json:"spec,omitempty"`
+	Status HivelocityRemediationStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// HivelocityRemediationList contains a list of HivelocityRemediation.
+type HivelocityRemediationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HivelocityRemediation `json:"items"`
+}
+
+// HivelocityRemediationTemplateResource describes the HivelocityRemediation
+// which gets created from the template.
+type HivelocityRemediationTemplateResource struct {
+	Spec HivelocityRemediationSpec `json:"spec"`
+}
+
+// HivelocityRemediationTemplateSpec defines the desired state of HivelocityRemediationTemplate.
+type HivelocityRemediationTemplateSpec struct {
+	Template HivelocityRemediationTemplateResource `json:"template"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=hvrt
+
+// HivelocityRemediationTemplate is referenced by a NodeHealthCheck. It is used
+// as template for the HivelocityRemediation of unhealthy nodes.
+type HivelocityRemediationTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec HivelocityRemediationTemplateSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// HivelocityRemediationTemplateList contains a list of HivelocityRemediationTemplate.
+type HivelocityRemediationTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HivelocityRemediationTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(
+		&HivelocityRemediation{},
+		&HivelocityRemediationList{},
+		&HivelocityRemediationTemplate{},
+		&HivelocityRemediationTemplateList{},
+	)
+}
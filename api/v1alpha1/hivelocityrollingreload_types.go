@@ -0,0 +1,157 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RollingReloadPhase is the phase of a HivelocityRollingReload.
+type RollingReloadPhase string
+
+const (
+	// RollingReloadPhaseProgressing means that nodes are getting reloaded.
+	RollingReloadPhaseProgressing RollingReloadPhase = "Progressing"
+
+	// RollingReloadPhasePaused means that no further nodes get reloaded. This
+	// happens if spec.paused is set, which the controller does if a node fails.
+	RollingReloadPhasePaused RollingReloadPhase = "Paused"
+
+	// RollingReloadPhaseCompleted means that all selected nodes have been reloaded.
+	RollingReloadPhaseCompleted RollingReloadPhase = "Completed"
+)
+
+// NodeReloadPhase is the phase of a single node of a HivelocityRollingReload.
+type NodeReloadPhase string
+
+const (
+	// NodeReloadPhasePending means that the node waits for its turn.
+	NodeReloadPhasePending NodeReloadPhase = "Pending"
+
+	// NodeReloadPhaseDraining means that the node is cordoned and its pods get evicted.
+	NodeReloadPhaseDraining NodeReloadPhase = "Draining"
+
+	// NodeReloadPhaseReloading means that the operating system gets reloaded and
+	// the controller waits for the node to rejoin the cluster.
+	NodeReloadPhaseReloading NodeReloadPhase = "Reloading"
+
+	// NodeReloadPhaseDone means that the node is ready and uncordoned again.
+	NodeReloadPhaseDone NodeReloadPhase = "Done"
+
+	// NodeReloadPhaseFailed means that the node could not be drained or did not
+	// become ready in time. It gets retried when the reload gets resumed.
+	NodeReloadPhaseFailed NodeReloadPhase = "Failed"
+)
+
+// HivelocityRollingReloadSpec defines the desired state of HivelocityRollingReload.
+type HivelocityRollingReloadSpec struct {
+	// NodeSelector selects the nodes which get reloaded. The nodes are selected
+	// once, when the reload starts.
+	NodeSelector metav1.LabelSelector `json:"nodeSelector"`
+
+	// MaxUnavailable is the number of nodes which get reloaded at the same time.
+	// Defaults to 1.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxUnavailable int32 `json:"maxUnavailable,omitempty"`
+
+	// Reload contains the target operating system, ignition and script.
+	Reload ReloadSpec `json:"reload"`
+
+	// DrainTimeout is the time to wait for the pods of a node to be evicted.
+	// Defaults to 10m.
+	// +optional
+	DrainTimeout *metav1.Duration `json:"drainTimeout,omitempty"`
+
+	// Paused stops reloading further nodes. The controller sets it if a node fails.
+	// Set it to false to resume, failed nodes get retried.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+}
+
+// NodeReloadStatus is the status of a single node.
+type NodeReloadStatus struct {
+	// Name is the name of the node.
+	Name string `json:"name"`
+
+	// Phase is the phase of the node.
+	Phase NodeReloadPhase `json:"phase"`
+
+	// DrainStartTime is the time the node was cordoned.
+	// +optional
+	DrainStartTime *metav1.Time `json:"drainStartTime,omitempty"`
+
+	// ReloadTime is the time the reload of the operating system was started.
+	// +optional
+	ReloadTime *metav1.Time `json:"reloadTime,omitempty"`
+
+	// WasUnschedulable is true if the node was cordoned before the reload.
+	// Such nodes stay cordoned after the reload. It is recorded when the node
+	// gets cordoned first, and kept when a failed node is retried.
+	// +optional
+	WasUnschedulable *bool `json:"wasUnschedulable,omitempty"`
+
+	// Message describes the current phase.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// HivelocityRollingReloadStatus defines the observed state of HivelocityRollingReload.
+type HivelocityRollingReloadStatus struct {
+	// Phase is the phase of the whole reload.
+	// +optional
+	Phase RollingReloadPhase `json:"phase,omitempty"`
+
+	// Nodes contains the selected nodes and their progress.
+	// +optional
+	Nodes []NodeReloadStatus `json:"nodes,omitempty"`
+
+	// Message describes the current phase.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=hvrr
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Paused",type=boolean,JSONPath=`.spec.paused`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// HivelocityRollingReload reloads the operating system of the selected nodes,
+// a few at a time. Every node gets cordoned and drained first. The next nodes
+// are reloaded when the previous ones are ready again.
+type HivelocityRollingReload struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HivelocityRollingReloadSpec   `json:"spec,omitempty"`
+	Status HivelocityRollingReloadStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// HivelocityRollingReloadList contains a list of HivelocityRollingReload.
+type HivelocityRollingReloadList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HivelocityRollingReload `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HivelocityRollingReload{}, &HivelocityRollingReloadList{})
+}
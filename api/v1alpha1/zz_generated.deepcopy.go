@@ -0,0 +1,387 @@
+//go:build !ignore_autogenerated
+// +build !ignore_autogenerated
+
+/*
+Copyright The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HivelocityRemediation) DeepCopyInto(out *HivelocityRemediation) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HivelocityRemediation.
+func (in *HivelocityRemediation) DeepCopy() *HivelocityRemediation {
+	if in == nil {
+		return nil
+	}
+	out := new(HivelocityRemediation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HivelocityRemediation) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HivelocityRemediationList) DeepCopyInto(out *HivelocityRemediationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HivelocityRemediation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HivelocityRemediationList.
+func (in *HivelocityRemediationList) DeepCopy() *HivelocityRemediationList {
+	if in == nil {
+		return nil
+	}
+	out := new(HivelocityRemediationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HivelocityRemediationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HivelocityRemediationSpec) DeepCopyInto(out *HivelocityRemediationSpec) {
+	*out = *in
+	if in.PowerCycleTimeout != nil {
+		in, out := &in.PowerCycleTimeout, &out.PowerCycleTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Reload != nil {
+		in, out := &in.Reload, &out.Reload
+		*out = new(ReloadSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HivelocityRemediationSpec.
+func (in *HivelocityRemediationSpec) DeepCopy() *HivelocityRemediationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HivelocityRemediationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HivelocityRemediationStatus) DeepCopyInto(out *HivelocityRemediationStatus) {
+	*out = *in
+	if in.PowerCycleTime != nil {
+		in, out := &in.PowerCycleTime, &out.PowerCycleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ReloadTime != nil {
+		in, out := &in.ReloadTime, &out.ReloadTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HivelocityRemediationStatus.
+func (in *HivelocityRemediationStatus) DeepCopy() *HivelocityRemediationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HivelocityRemediationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HivelocityRemediationTemplate) DeepCopyInto(out *HivelocityRemediationTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HivelocityRemediationTemplate.
+func (in *HivelocityRemediationTemplate) DeepCopy() *HivelocityRemediationTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(HivelocityRemediationTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HivelocityRemediationTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HivelocityRemediationTemplateList) DeepCopyInto(out *HivelocityRemediationTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HivelocityRemediationTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HivelocityRemediationTemplateList.
+func (in *HivelocityRemediationTemplateList) DeepCopy() *HivelocityRemediationTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(HivelocityRemediationTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HivelocityRemediationTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HivelocityRemediationTemplateResource) DeepCopyInto(out *HivelocityRemediationTemplateResource) {
+	*out = *in
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HivelocityRemediationTemplateResource.
+func (in *HivelocityRemediationTemplateResource) DeepCopy() *HivelocityRemediationTemplateResource {
+	if in == nil {
+		return nil
+	}
+	out := new(HivelocityRemediationTemplateResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HivelocityRemediationTemplateSpec) DeepCopyInto(out *HivelocityRemediationTemplateSpec) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HivelocityRemediationTemplateSpec.
+func (in *HivelocityRemediationTemplateSpec) DeepCopy() *HivelocityRemediationTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HivelocityRemediationTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HivelocityRollingReload) DeepCopyInto(out *HivelocityRollingReload) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HivelocityRollingReload.
+func (in *HivelocityRollingReload) DeepCopy() *HivelocityRollingReload {
+	if in == nil {
+		return nil
+	}
+	out := new(HivelocityRollingReload)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HivelocityRollingReload) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HivelocityRollingReloadList) DeepCopyInto(out *HivelocityRollingReloadList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HivelocityRollingReload, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HivelocityRollingReloadList.
+func (in *HivelocityRollingReloadList) DeepCopy() *HivelocityRollingReloadList {
+	if in == nil {
+		return nil
+	}
+	out := new(HivelocityRollingReloadList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HivelocityRollingReloadList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HivelocityRollingReloadSpec) DeepCopyInto(out *HivelocityRollingReloadSpec) {
+	*out = *in
+	in.NodeSelector.DeepCopyInto(&out.NodeSelector)
+	in.Reload.DeepCopyInto(&out.Reload)
+	if in.DrainTimeout != nil {
+		in, out := &in.DrainTimeout, &out.DrainTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HivelocityRollingReloadSpec.
+func (in *HivelocityRollingReloadSpec) DeepCopy() *HivelocityRollingReloadSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HivelocityRollingReloadSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HivelocityRollingReloadStatus) DeepCopyInto(out *HivelocityRollingReloadStatus) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]NodeReloadStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HivelocityRollingReloadStatus.
+func (in *HivelocityRollingReloadStatus) DeepCopy() *HivelocityRollingReloadStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HivelocityRollingReloadStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeReloadStatus) DeepCopyInto(out *NodeReloadStatus) {
+	*out = *in
+	if in.DrainStartTime != nil {
+		in, out := &in.DrainStartTime, &out.DrainStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ReloadTime != nil {
+		in, out := &in.ReloadTime, &out.ReloadTime
+		*out = (*in).DeepCopy()
+	}
+	if in.WasUnschedulable != nil {
+		in, out := &in.WasUnschedulable, &out.WasUnschedulable
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeReloadStatus.
+func (in *NodeReloadStatus) DeepCopy() *NodeReloadStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeReloadStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReloadSpec) DeepCopyInto(out *ReloadSpec) {
+	*out = *in
+	if in.PublicSSHKeyIDs != nil {
+		in, out := &in.PublicSSHKeyIDs, &out.PublicSSHKeyIDs
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReloadSpec.
+func (in *ReloadSpec) DeepCopy() *ReloadSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ReloadSpec)
+	in.DeepCopyInto(out)
+	return out
+}
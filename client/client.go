@@ -27,8 +27,11 @@ import (
 	"net/http"
 	"regexp"
 	"runtime/debug"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/antihax/optional"
 	"github.com/go-logr/logr"
 	hv "github.com/hivelocity/hivelocity-client-go/client"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -39,8 +42,49 @@ import (
 type Interface interface {
 	GetBareMetalDevice(ctx context.Context, deviceID int32) (*hv.BareMetalDevice, error)
 	ListDevices(context.Context) ([]hv.BareMetalDevice, error)
+	GetIPMIInfo(ctx context.Context, deviceID int32) (*hv.DeviceIpmiInfo, error)
+	GetIPMIThresholds(ctx context.Context, deviceID int32) (*hv.DeviceIpmiThresholds, error)
+	PowerDevice(ctx context.Context, deviceID int32, action PowerAction) error
+	ReloadDevice(ctx context.Context, deviceID int32, reload hv.DeviceReload) error
+	SetDeviceTags(ctx context.Context, deviceID int32, tags []string) error
+	GetProductStock(ctx context.Context, productID int32) (*hv.Stock, error)
+	ListOrderGroups(ctx context.Context) ([]hv.OrderGroup, error)
+	GetService(ctx context.Context, serviceID int32) (*hv.Service, error)
+	GetDeviceBandwidth(ctx context.Context, deviceID int32, iface string, step int32, start, end time.Time) ([]hv.Bandwidth, error)
+	ListDevicePorts(ctx context.Context) ([]hv.DevicePort, error)
+	ListIPAssignments(ctx context.Context) ([]hv.IpAssignment, error)
+	GetIPAssignment(ctx context.Context, assignmentID int32) (*hv.IpAssignment, error)
+	SetIPAssignmentNextHop(ctx context.Context, assignmentID int32, nextHop string) (*hv.NetworkTaskDump, error)
+	ClearIPAssignment(ctx context.Context, assignmentID int32) (*hv.NetworkTaskDump, error)
+	SplitIPAssignment(ctx context.Context, assignmentID int32) ([]hv.IpAssignment, error)
+	GetVLAN(ctx context.Context, vlanID int32) (*hv.Vlan, error)
+	UpdateVLAN(ctx context.Context, vlanID int32, update hv.VlanUpdate) (*hv.NetworkTaskDump, error)
+	GetNetworkTask(ctx context.Context, taskID string) (*hv.NetworkTaskDump, error)
+	ListDomains(ctx context.Context) ([]hv.DomainReturn, error)
+	ListARecords(ctx context.Context, domainID int32) ([]hv.ARecord, error)
+	CreateARecord(ctx context.Context, domainID int32, record hv.ARecord) error
+	UpdateARecord(ctx context.Context, domainID int32, record hv.ARecord) error
+	DeleteARecord(ctx context.Context, domainID int32, name string) error
+	ListAAAARecords(ctx context.Context, domainID int32) ([]hv.AaaaRecordReturn, error)
+	CreateAAAARecord(ctx context.Context, domainID int32, record hv.AaaaRecordCreate) error
+	DeleteAAAARecord(ctx context.Context, domainID, recordID int32) error
+	ListMXRecords(ctx context.Context, domainID int32) ([]hv.MxRecordReturn, error)
+	CreateMXRecord(ctx context.Context, domainID int32, record hv.MxRecordCreate) error
+	DeleteMXRecord(ctx context.Context, domainID, recordID int32) error
+	ListPTRRecords(ctx context.Context) ([]hv.PtrRecordReturn, error)
+	UpdatePTRRecord(ctx context.Context, recordID int32, update hv.PtrRecordUpdate) error
 }
 
+// PowerAction is an action which changes the power status of a device.
+type PowerAction string
+
+// Power actions supported by the Hivelocity API.
+const (
+	PowerActionBoot     PowerAction = "boot"
+	PowerActionReboot   PowerAction = "reboot"
+	PowerActionShutdown PowerAction = "shutdown"
+)
+
 // Client implements the Interface interface.
 type Client struct {
 	client *hv.APIClient
@@ -168,3 +212,479 @@ func (c *Client) ListDevices(ctx context.Context) ([]hv.BareMetalDevice, error)
 		err,
 	)
 }
+
+// statusCode returns the status code of the response, or zero if there is no response.
+// The Hivelocity client does not return a response if the request could not be sent.
+func statusCode(response *http.Response) int {
+	if response == nil {
+		return 0
+	}
+	return response.StatusCode
+}
+
+// GetIPMIInfo returns the IPMI info including the sensor readings of a device.
+func (c *Client) GetIPMIInfo(ctx context.Context, deviceID int32) (*hv.DeviceIpmiInfo, error) {
+	info, response, err := c.client.DeviceApi.GetIpmiInfoIdResource(ctx, deviceID, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[GetIPMIInfo] GetIpmiInfoIdResource failed. StatusCode %d, deviceID %d: %w",
+			statusCode(response),
+			deviceID,
+			err,
+		)
+	}
+	return &info, nil
+}
+
+// GetIPMIThresholds returns the alert thresholds for the IPMI sensors of a device.
+func (c *Client) GetIPMIThresholds(ctx context.Context, deviceID int32) (*hv.DeviceIpmiThresholds, error) {
+	thresholds, response, err := c.client.DeviceApi.GetIpmiThresholdsIdResource(ctx, deviceID, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[GetIPMIThresholds] GetIpmiThresholdsIdResource failed. StatusCode %d, deviceID %d: %w",
+			statusCode(response),
+			deviceID,
+			err,
+		)
+	}
+	return &thresholds, nil
+}
+
+// PowerDevice changes the power status of a device, for example to power-cycle it.
+func (c *Client) PowerDevice(ctx context.Context, deviceID int32, action PowerAction) error {
+	_, response, err := c.client.DeviceApi.PostPowerResource(ctx, deviceID, string(action), nil)
+	if err != nil {
+		return fmt.Errorf(
+			"[PowerDevice] PostPowerResource failed. StatusCode %d, deviceID %d, action %q: %w",
+			statusCode(response),
+			deviceID,
+			action,
+			err,
+		)
+	}
+	return nil
+}
+
+// ReloadDevice reinstalls the operating system of a device. All data on the device gets lost.
+func (c *Client) ReloadDevice(ctx context.Context, deviceID int32, reload hv.DeviceReload) error {
+	_, response, err := c.client.DeviceApi.PostDeviceReloadResource(ctx, deviceID, reload, nil)
+	if err != nil {
+		return fmt.Errorf(
+			"[ReloadDevice] PostDeviceReloadResource failed. StatusCode %d, deviceID %d: %w",
+			statusCode(response),
+			deviceID,
+			err,
+		)
+	}
+	return nil
+}
+
+// SetDeviceTags replaces all tags of a device.
+func (c *Client) SetDeviceTags(ctx context.Context, deviceID int32, tags []string) error {
+	_, response, err := c.client.DeviceApi.PutDeviceTagIdResource(ctx, deviceID, hv.DeviceTag{Tags: tags}, nil)
+	if err != nil {
+		return fmt.Errorf(
+			"[SetDeviceTags] PutDeviceTagIdResource failed. StatusCode %d, deviceID %d: %w",
+			statusCode(response),
+			deviceID,
+			err,
+		)
+	}
+	return nil
+}
+
+// GetProductStock returns the stock information of a product. It contains the
+// hardware specs, like CPU, memory and drives.
+func (c *Client) GetProductStock(ctx context.Context, productID int32) (*hv.Stock, error) {
+	stock, response, err := c.client.InventoryApi.GetStockByProductResource(ctx, productID, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[GetProductStock] GetStockByProductResource failed. StatusCode %d, productID %d: %w",
+			statusCode(response),
+			productID,
+			err,
+		)
+	}
+	return &stock, nil
+}
+
+// ListOrderGroups lists all order groups. An order group contains devices
+// which were ordered together, optionally in the same rack.
+func (c *Client) ListOrderGroups(ctx context.Context) ([]hv.OrderGroup, error) {
+	orderGroups, response, err := c.client.OrderGroupsApi.GetOrderGroupResource(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[ListOrderGroups] GetOrderGroupResource failed. StatusCode %d: %w",
+			statusCode(response),
+			err,
+		)
+	}
+	return orderGroups, nil
+}
+
+// GetService returns a service, which contains the billing data of a device.
+func (c *Client) GetService(ctx context.Context, serviceID int32) (*hv.Service, error) {
+	service, response, err := c.client.ServiceApi.GetServiceIdResource(ctx, serviceID, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[GetService] GetServiceIdResource failed. StatusCode %d, serviceID %d: %w",
+			statusCode(response),
+			serviceID,
+			err,
+		)
+	}
+	return &service, nil
+}
+
+// GetDeviceBandwidth returns the traffic of an interface of a device between
+// start and end, with one measurement per step seconds. Interface is for
+// example "public", "private" or "eth0".
+func (c *Client) GetDeviceBandwidth(
+	ctx context.Context,
+	deviceID int32,
+	iface string,
+	step int32,
+	start, end time.Time,
+) ([]hv.Bandwidth, error) {
+	bandwidth, response, err := c.client.BandwidthApi.PostDeviceIdBandwidthResource(
+		ctx, deviceID, "custom", iface, step, &hv.BandwidthApiPostDeviceIdBandwidthResourceOpts{
+			Start: optional.NewInt32(int32(start.Unix())),
+			End:   optional.NewInt32(int32(end.Unix())),
+		})
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[GetDeviceBandwidth] PostDeviceIdBandwidthResource failed. StatusCode %d, deviceID %d, interface %q: %w",
+			statusCode(response),
+			deviceID,
+			iface,
+			err,
+		)
+	}
+	return bandwidth, nil
+}
+
+// ListDevicePorts lists the switch ports of all devices.
+func (c *Client) ListDevicePorts(ctx context.Context) ([]hv.DevicePort, error) {
+	ports, response, err := c.client.NetworkApi.GetDeviceNetworkPortResource(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[ListDevicePorts] GetDeviceNetworkPortResource failed. StatusCode %d: %w",
+			statusCode(response),
+			err,
+		)
+	}
+	return ports, nil
+}
+
+// ListIPAssignments lists the IPv4 and IPv6 assignments of the account.
+func (c *Client) ListIPAssignments(ctx context.Context) ([]hv.IpAssignment, error) {
+	opts := &hv.IPAssignmentApiGetIpAssignmentResourceOpts{
+		DisplayIPv6: optional.NewBool(true),
+	}
+	assignments, response, err := c.client.IPAssignmentApi.GetIpAssignmentResource(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[ListIPAssignments] GetIpAssignmentResource failed. StatusCode %d: %w",
+			statusCode(response),
+			err,
+		)
+	}
+	return assignments, nil
+}
+
+// GetIPAssignment returns the assignment with the given ID.
+func (c *Client) GetIPAssignment(ctx context.Context, assignmentID int32) (*hv.IpAssignment, error) {
+	assignment, response, err := c.client.IPAssignmentApi.GetIpAssignmentIdResource(ctx, assignmentID, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[GetIPAssignment] GetIpAssignmentIdResource failed. StatusCode %d, assignmentID %d: %w",
+			statusCode(response),
+			assignmentID,
+			err,
+		)
+	}
+	return &assignment, nil
+}
+
+// SetIPAssignmentNextHop routes the traffic of the assignment to nextHop.
+// The change is applied asynchronously by the returned network task.
+func (c *Client) SetIPAssignmentNextHop(ctx context.Context, assignmentID int32, nextHop string) (*hv.NetworkTaskDump, error) {
+	task, response, err := c.client.IPAssignmentApi.PutIpAssignmentIdResource(
+		ctx, assignmentID, hv.IpAssignmentPut{NextHopIp: nextHop}, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[SetIPAssignmentNextHop] PutIpAssignmentIdResource failed. StatusCode %d, assignmentID %d, nextHop %q: %w",
+			statusCode(response),
+			assignmentID,
+			nextHop,
+			err,
+		)
+	}
+	return &task, nil
+}
+
+// ClearIPAssignment removes the routing of the assignment, so that it does not
+// receive traffic anymore. The assignment itself is kept.
+// The change is applied asynchronously by the returned network task.
+func (c *Client) ClearIPAssignment(ctx context.Context, assignmentID int32) (*hv.NetworkTaskDump, error) {
+	task, response, err := c.client.IPAssignmentApi.PostIpAssignmentIdClearResource(ctx, assignmentID, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[ClearIPAssignment] PostIpAssignmentIdClearResource failed. StatusCode %d, assignmentID %d: %w",
+			statusCode(response),
+			assignmentID,
+			err,
+		)
+	}
+	return &task, nil
+}
+
+// SplitIPAssignment splits the assignment into two assignments of half its size.
+func (c *Client) SplitIPAssignment(ctx context.Context, assignmentID int32) ([]hv.IpAssignment, error) {
+	assignments, response, err := c.client.IPAssignmentApi.PostIpAssignmentSplitResource(ctx, assignmentID, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[SplitIPAssignment] PostIpAssignmentSplitResource failed. StatusCode %d, assignmentID %d: %w",
+			statusCode(response),
+			assignmentID,
+			err,
+		)
+	}
+	return assignments, nil
+}
+
+// GetVLAN returns the VLAN with the given ID.
+func (c *Client) GetVLAN(ctx context.Context, vlanID int32) (*hv.Vlan, error) {
+	vlan, response, err := c.client.VLANApi.GetVlanIdResource(ctx, vlanID, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[GetVLAN] GetVlanIdResource failed. StatusCode %d, vlanID %d: %w",
+			statusCode(response),
+			vlanID,
+			err,
+		)
+	}
+	return &vlan, nil
+}
+
+// UpdateVLAN replaces the ports and IPs of the VLAN.
+// The change is applied asynchronously by the returned network task.
+func (c *Client) UpdateVLAN(ctx context.Context, vlanID int32, update hv.VlanUpdate) (*hv.NetworkTaskDump, error) {
+	task, response, err := c.client.VLANApi.PutVlanIdResource(ctx, vlanID, update, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[UpdateVLAN] PutVlanIdResource failed. StatusCode %d, vlanID %d: %w",
+			statusCode(response),
+			vlanID,
+			err,
+		)
+	}
+	return &task, nil
+}
+
+// GetNetworkTask returns the network task with the given ID.
+func (c *Client) GetNetworkTask(ctx context.Context, taskID string) (*hv.NetworkTaskDump, error) {
+	task, response, err := c.client.NetworkApi.GetNetworkTaskIdResource(ctx, taskID, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[GetNetworkTask] GetNetworkTaskIdResource failed. StatusCode %d, taskID %q: %w",
+			statusCode(response),
+			taskID,
+			err,
+		)
+	}
+	return &task, nil
+}
+
+// ListDomains lists the DNS zones of the account.
+func (c *Client) ListDomains(ctx context.Context) ([]hv.DomainReturn, error) {
+	domains, response, err := c.client.DomainsApi.GetDomainResource(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[ListDomains] GetDomainResource failed. StatusCode %d: %w",
+			statusCode(response),
+			err,
+		)
+	}
+	return domains, nil
+}
+
+// ListARecords lists the A records of the zone. An A record contains all
+// IPv4 addresses of a name.
+func (c *Client) ListARecords(ctx context.Context, domainID int32) ([]hv.ARecord, error) {
+	records, response, err := c.client.DomainsApi.GetARecordResource(ctx, strconv.Itoa(int(domainID)), nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[ListARecords] GetARecordResource failed. StatusCode %d, domainID %d: %w",
+			statusCode(response),
+			domainID,
+			err,
+		)
+	}
+	return records, nil
+}
+
+// CreateARecord creates an A record in the zone.
+func (c *Client) CreateARecord(ctx context.Context, domainID int32, record hv.ARecord) error {
+	_, response, err := c.client.DomainsApi.PostARecordResource(ctx, strconv.Itoa(int(domainID)), record, nil)
+	if err != nil {
+		return fmt.Errorf(
+			"[CreateARecord] PostARecordResource failed. StatusCode %d, domainID %d, name %q: %w",
+			statusCode(response),
+			domainID,
+			record.Name,
+			err,
+		)
+	}
+	return nil
+}
+
+// UpdateARecord replaces the addresses and the TTL of the A record with the
+// name of record. A records are identified by their name.
+func (c *Client) UpdateARecord(ctx context.Context, domainID int32, record hv.ARecord) error {
+	_, response, err := c.client.DomainsApi.PutARecordIdResource(
+		ctx, strconv.Itoa(int(domainID)), record.Name, record, nil)
+	if err != nil {
+		return fmt.Errorf(
+			"[UpdateARecord] PutARecordIdResource failed. StatusCode %d, domainID %d, name %q: %w",
+			statusCode(response),
+			domainID,
+			record.Name,
+			err,
+		)
+	}
+	return nil
+}
+
+// DeleteARecord deletes the A record with the name.
+func (c *Client) DeleteARecord(ctx context.Context, domainID int32, name string) error {
+	response, err := c.client.DomainsApi.DeleteARecordIdResource(ctx, strconv.Itoa(int(domainID)), name)
+	if err != nil {
+		return fmt.Errorf(
+			"[DeleteARecord] DeleteARecordIdResource failed. StatusCode %d, domainID %d, name %q: %w",
+			statusCode(response),
+			domainID,
+			name,
+			err,
+		)
+	}
+	return nil
+}
+
+// ListAAAARecords lists the AAAA records of the zone. An AAAA record contains
+// a single IPv6 address, a name can have several records.
+func (c *Client) ListAAAARecords(ctx context.Context, domainID int32) ([]hv.AaaaRecordReturn, error) {
+	records, response, err := c.client.DomainsApi.GetAaaaRecordResource(ctx, domainID, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[ListAAAARecords] GetAaaaRecordResource failed. StatusCode %d, domainID %d: %w",
+			statusCode(response),
+			domainID,
+			err,
+		)
+	}
+	return records, nil
+}
+
+// CreateAAAARecord creates an AAAA record in the zone.
+func (c *Client) CreateAAAARecord(ctx context.Context, domainID int32, record hv.AaaaRecordCreate) error {
+	_, response, err := c.client.DomainsApi.PostAaaaRecordResource(ctx, domainID, record, nil)
+	if err != nil {
+		return fmt.Errorf(
+			"[CreateAAAARecord] PostAaaaRecordResource failed. StatusCode %d, domainID %d, name %q: %w",
+			statusCode(response),
+			domainID,
+			record.Name,
+			err,
+		)
+	}
+	return nil
+}
+
+// DeleteAAAARecord deletes the AAAA record with the given ID.
+func (c *Client) DeleteAAAARecord(ctx context.Context, domainID, recordID int32) error {
+	response, err := c.client.DomainsApi.DeleteAaaaRecordIdResource(ctx, domainID, recordID)
+	if err != nil {
+		return fmt.Errorf(
+			"[DeleteAAAARecord] DeleteAaaaRecordIdResource failed. StatusCode %d, domainID %d, recordID %d: %w",
+			statusCode(response),
+			domainID,
+			recordID,
+			err,
+		)
+	}
+	return nil
+}
+
+// ListMXRecords lists the MX records of the zone. An MX record contains a
+// single mail exchange, a name can have several records.
+func (c *Client) ListMXRecords(ctx context.Context, domainID int32) ([]hv.MxRecordReturn, error) {
+	records, response, err := c.client.DomainsApi.GetMxRecordResource(ctx, domainID, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[ListMXRecords] GetMxRecordResource failed. StatusCode %d, domainID %d: %w",
+			statusCode(response),
+			domainID,
+			err,
+		)
+	}
+	return records, nil
+}
+
+// CreateMXRecord creates an MX record in the zone.
+func (c *Client) CreateMXRecord(ctx context.Context, domainID int32, record hv.MxRecordCreate) error {
+	_, response, err := c.client.DomainsApi.PostMxRecordResource(ctx, domainID, record, nil)
+	if err != nil {
+		return fmt.Errorf(
+			"[CreateMXRecord] PostMxRecordResource failed. StatusCode %d, domainID %d, name %q: %w",
+			statusCode(response),
+			domainID,
+			record.Name,
+			err,
+		)
+	}
+	return nil
+}
+
+// DeleteMXRecord deletes the MX record with the given ID.
+func (c *Client) DeleteMXRecord(ctx context.Context, domainID, recordID int32) error {
+	response, err := c.client.DomainsApi.DeleteMxRecordIdResource(ctx, domainID, recordID)
+	if err != nil {
+		return fmt.Errorf(
+			"[DeleteMXRecord] DeleteMxRecordIdResource failed. StatusCode %d, domainID %d, recordID %d: %w",
+			statusCode(response),
+			domainID,
+			recordID,
+			err,
+		)
+	}
+	return nil
+}
+
+// ListPTRRecords lists the reverse DNS records of the IPs of the account.
+func (c *Client) ListPTRRecords(ctx context.Context) ([]hv.PtrRecordReturn, error) {
+	records, response, err := c.client.DomainsApi.GetPtrRecordResource(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf(
+			"[ListPTRRecords] GetPtrRecordResource failed. StatusCode %d: %w",
+			statusCode(response),
+			err,
+		)
+	}
+	return records, nil
+}
+
+// UpdatePTRRecord changes the name of a reverse DNS record.
+func (c *Client) UpdatePTRRecord(ctx context.Context, recordID int32, update hv.PtrRecordUpdate) error {
+	_, response, err := c.client.DomainsApi.PutPtrRecordIdResource(ctx, recordID, update, nil)
+	if err != nil {
+		return fmt.Errorf(
+			"[UpdatePTRRecord] PutPtrRecordIdResource failed. StatusCode %d, recordID %d, name %q: %w",
+			statusCode(response),
+			recordID,
+			update.Name,
+			err,
+		)
+	}
+	return nil
+}
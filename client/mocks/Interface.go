@@ -5,8 +5,13 @@ package mocks
 import (
 	context "context"
 
-	swagger "github.com/hivelocity/hivelocity-client-go/client"
+	client "github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+
 	mock "github.com/stretchr/testify/mock"
+
+	swagger "github.com/hivelocity/hivelocity-client-go/client"
+
+	time "time"
 )
 
 // Interface is an autogenerated mock type for the Interface type
@@ -14,6 +19,116 @@ type Interface struct {
 	mock.Mock
 }
 
+// ClearIPAssignment provides a mock function with given fields: ctx, assignmentID
+func (_m *Interface) ClearIPAssignment(ctx context.Context, assignmentID int32) (*swagger.NetworkTaskDump, error) {
+	ret := _m.Called(ctx, assignmentID)
+
+	var r0 *swagger.NetworkTaskDump
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32) (*swagger.NetworkTaskDump, error)); ok {
+		return rf(ctx, assignmentID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int32) *swagger.NetworkTaskDump); ok {
+		r0 = rf(ctx, assignmentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*swagger.NetworkTaskDump)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int32) error); ok {
+		r1 = rf(ctx, assignmentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CreateAAAARecord provides a mock function with given fields: ctx, domainID, record
+func (_m *Interface) CreateAAAARecord(ctx context.Context, domainID int32, record swagger.AaaaRecordCreate) error {
+	ret := _m.Called(ctx, domainID, record)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32, swagger.AaaaRecordCreate) error); ok {
+		r0 = rf(ctx, domainID, record)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateARecord provides a mock function with given fields: ctx, domainID, record
+func (_m *Interface) CreateARecord(ctx context.Context, domainID int32, record swagger.ARecord) error {
+	ret := _m.Called(ctx, domainID, record)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32, swagger.ARecord) error); ok {
+		r0 = rf(ctx, domainID, record)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateMXRecord provides a mock function with given fields: ctx, domainID, record
+func (_m *Interface) CreateMXRecord(ctx context.Context, domainID int32, record swagger.MxRecordCreate) error {
+	ret := _m.Called(ctx, domainID, record)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32, swagger.MxRecordCreate) error); ok {
+		r0 = rf(ctx, domainID, record)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteAAAARecord provides a mock function with given fields: ctx, domainID, recordID
+func (_m *Interface) DeleteAAAARecord(ctx context.Context, domainID int32, recordID int32) error {
+	ret := _m.Called(ctx, domainID, recordID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32, int32) error); ok {
+		r0 = rf(ctx, domainID, recordID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteARecord provides a mock function with given fields: ctx, domainID, name
+func (_m *Interface) DeleteARecord(ctx context.Context, domainID int32, name string) error {
+	ret := _m.Called(ctx, domainID, name)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32, string) error); ok {
+		r0 = rf(ctx, domainID, name)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteMXRecord provides a mock function with given fields: ctx, domainID, recordID
+func (_m *Interface) DeleteMXRecord(ctx context.Context, domainID int32, recordID int32) error {
+	ret := _m.Called(ctx, domainID, recordID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32, int32) error); ok {
+		r0 = rf(ctx, domainID, recordID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetBareMetalDevice provides a mock function with given fields: ctx, deviceID
 func (_m *Interface) GetBareMetalDevice(ctx context.Context, deviceID int32) (*swagger.BareMetalDevice, error) {
 	ret := _m.Called(ctx, deviceID)
@@ -40,6 +155,292 @@ func (_m *Interface) GetBareMetalDevice(ctx context.Context, deviceID int32) (*s
 	return r0, r1
 }
 
+// GetDeviceBandwidth provides a mock function with given fields: ctx, deviceID, iface, step, start, end
+func (_m *Interface) GetDeviceBandwidth(ctx context.Context, deviceID int32, iface string, step int32, start time.Time, end time.Time) ([]swagger.Bandwidth, error) {
+	ret := _m.Called(ctx, deviceID, iface, step, start, end)
+
+	var r0 []swagger.Bandwidth
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32, string, int32, time.Time, time.Time) ([]swagger.Bandwidth, error)); ok {
+		return rf(ctx, deviceID, iface, step, start, end)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int32, string, int32, time.Time, time.Time) []swagger.Bandwidth); ok {
+		r0 = rf(ctx, deviceID, iface, step, start, end)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]swagger.Bandwidth)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int32, string, int32, time.Time, time.Time) error); ok {
+		r1 = rf(ctx, deviceID, iface, step, start, end)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetIPAssignment provides a mock function with given fields: ctx, assignmentID
+func (_m *Interface) GetIPAssignment(ctx context.Context, assignmentID int32) (*swagger.IpAssignment, error) {
+	ret := _m.Called(ctx, assignmentID)
+
+	var r0 *swagger.IpAssignment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32) (*swagger.IpAssignment, error)); ok {
+		return rf(ctx, assignmentID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int32) *swagger.IpAssignment); ok {
+		r0 = rf(ctx, assignmentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*swagger.IpAssignment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int32) error); ok {
+		r1 = rf(ctx, assignmentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetIPMIInfo provides a mock function with given fields: ctx, deviceID
+func (_m *Interface) GetIPMIInfo(ctx context.Context, deviceID int32) (*swagger.DeviceIpmiInfo, error) {
+	ret := _m.Called(ctx, deviceID)
+
+	var r0 *swagger.DeviceIpmiInfo
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32) (*swagger.DeviceIpmiInfo, error)); ok {
+		return rf(ctx, deviceID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int32) *swagger.DeviceIpmiInfo); ok {
+		r0 = rf(ctx, deviceID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*swagger.DeviceIpmiInfo)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int32) error); ok {
+		r1 = rf(ctx, deviceID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetIPMIThresholds provides a mock function with given fields: ctx, deviceID
+func (_m *Interface) GetIPMIThresholds(ctx context.Context, deviceID int32) (*swagger.DeviceIpmiThresholds, error) {
+	ret := _m.Called(ctx, deviceID)
+
+	var r0 *swagger.DeviceIpmiThresholds
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32) (*swagger.DeviceIpmiThresholds, error)); ok {
+		return rf(ctx, deviceID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int32) *swagger.DeviceIpmiThresholds); ok {
+		r0 = rf(ctx, deviceID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*swagger.DeviceIpmiThresholds)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int32) error); ok {
+		r1 = rf(ctx, deviceID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetNetworkTask provides a mock function with given fields: ctx, taskID
+func (_m *Interface) GetNetworkTask(ctx context.Context, taskID string) (*swagger.NetworkTaskDump, error) {
+	ret := _m.Called(ctx, taskID)
+
+	var r0 *swagger.NetworkTaskDump
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*swagger.NetworkTaskDump, error)); ok {
+		return rf(ctx, taskID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *swagger.NetworkTaskDump); ok {
+		r0 = rf(ctx, taskID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*swagger.NetworkTaskDump)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, taskID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetProductStock provides a mock function with given fields: ctx, productID
+func (_m *Interface) GetProductStock(ctx context.Context, productID int32) (*swagger.Stock, error) {
+	ret := _m.Called(ctx, productID)
+
+	var r0 *swagger.Stock
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32) (*swagger.Stock, error)); ok {
+		return rf(ctx, productID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int32) *swagger.Stock); ok {
+		r0 = rf(ctx, productID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*swagger.Stock)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int32) error); ok {
+		r1 = rf(ctx, productID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetService provides a mock function with given fields: ctx, serviceID
+func (_m *Interface) GetService(ctx context.Context, serviceID int32) (*swagger.Service, error) {
+	ret := _m.Called(ctx, serviceID)
+
+	var r0 *swagger.Service
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32) (*swagger.Service, error)); ok {
+		return rf(ctx, serviceID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int32) *swagger.Service); ok {
+		r0 = rf(ctx, serviceID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*swagger.Service)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int32) error); ok {
+		r1 = rf(ctx, serviceID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetVLAN provides a mock function with given fields: ctx, vlanID
+func (_m *Interface) GetVLAN(ctx context.Context, vlanID int32) (*swagger.Vlan, error) {
+	ret := _m.Called(ctx, vlanID)
+
+	var r0 *swagger.Vlan
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32) (*swagger.Vlan, error)); ok {
+		return rf(ctx, vlanID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int32) *swagger.Vlan); ok {
+		r0 = rf(ctx, vlanID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*swagger.Vlan)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int32) error); ok {
+		r1 = rf(ctx, vlanID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListAAAARecords provides a mock function with given fields: ctx, domainID
+func (_m *Interface) ListAAAARecords(ctx context.Context, domainID int32) ([]swagger.AaaaRecordReturn, error) {
+	ret := _m.Called(ctx, domainID)
+
+	var r0 []swagger.AaaaRecordReturn
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32) ([]swagger.AaaaRecordReturn, error)); ok {
+		return rf(ctx, domainID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int32) []swagger.AaaaRecordReturn); ok {
+		r0 = rf(ctx, domainID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]swagger.AaaaRecordReturn)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int32) error); ok {
+		r1 = rf(ctx, domainID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListARecords provides a mock function with given fields: ctx, domainID
+func (_m *Interface) ListARecords(ctx context.Context, domainID int32) ([]swagger.ARecord, error) {
+	ret := _m.Called(ctx, domainID)
+
+	var r0 []swagger.ARecord
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32) ([]swagger.ARecord, error)); ok {
+		return rf(ctx, domainID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int32) []swagger.ARecord); ok {
+		r0 = rf(ctx, domainID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]swagger.ARecord)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int32) error); ok {
+		r1 = rf(ctx, domainID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListDevicePorts provides a mock function with given fields: ctx
+func (_m *Interface) ListDevicePorts(ctx context.Context) ([]swagger.DevicePort, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []swagger.DevicePort
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]swagger.DevicePort, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []swagger.DevicePort); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]swagger.DevicePort)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListDevices provides a mock function with given fields: _a0
 func (_m *Interface) ListDevices(_a0 context.Context) ([]swagger.BareMetalDevice, error) {
 	ret := _m.Called(_a0)
@@ -66,6 +467,284 @@ func (_m *Interface) ListDevices(_a0 context.Context) ([]swagger.BareMetalDevice
 	return r0, r1
 }
 
+// ListDomains provides a mock function with given fields: ctx
+func (_m *Interface) ListDomains(ctx context.Context) ([]swagger.DomainReturn, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []swagger.DomainReturn
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]swagger.DomainReturn, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []swagger.DomainReturn); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]swagger.DomainReturn)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListIPAssignments provides a mock function with given fields: ctx
+func (_m *Interface) ListIPAssignments(ctx context.Context) ([]swagger.IpAssignment, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []swagger.IpAssignment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]swagger.IpAssignment, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []swagger.IpAssignment); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]swagger.IpAssignment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListMXRecords provides a mock function with given fields: ctx, domainID
+func (_m *Interface) ListMXRecords(ctx context.Context, domainID int32) ([]swagger.MxRecordReturn, error) {
+	ret := _m.Called(ctx, domainID)
+
+	var r0 []swagger.MxRecordReturn
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32) ([]swagger.MxRecordReturn, error)); ok {
+		return rf(ctx, domainID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int32) []swagger.MxRecordReturn); ok {
+		r0 = rf(ctx, domainID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]swagger.MxRecordReturn)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int32) error); ok {
+		r1 = rf(ctx, domainID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListOrderGroups provides a mock function with given fields: ctx
+func (_m *Interface) ListOrderGroups(ctx context.Context) ([]swagger.OrderGroup, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []swagger.OrderGroup
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]swagger.OrderGroup, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []swagger.OrderGroup); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]swagger.OrderGroup)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ListPTRRecords provides a mock function with given fields: ctx
+func (_m *Interface) ListPTRRecords(ctx context.Context) ([]swagger.PtrRecordReturn, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []swagger.PtrRecordReturn
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]swagger.PtrRecordReturn, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []swagger.PtrRecordReturn); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]swagger.PtrRecordReturn)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PowerDevice provides a mock function with given fields: ctx, deviceID, action
+func (_m *Interface) PowerDevice(ctx context.Context, deviceID int32, action client.PowerAction) error {
+	ret := _m.Called(ctx, deviceID, action)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32, client.PowerAction) error); ok {
+		r0 = rf(ctx, deviceID, action)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReloadDevice provides a mock function with given fields: ctx, deviceID, reload
+func (_m *Interface) ReloadDevice(ctx context.Context, deviceID int32, reload swagger.DeviceReload) error {
+	ret := _m.Called(ctx, deviceID, reload)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32, swagger.DeviceReload) error); ok {
+		r0 = rf(ctx, deviceID, reload)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetDeviceTags provides a mock function with given fields: ctx, deviceID, tags
+func (_m *Interface) SetDeviceTags(ctx context.Context, deviceID int32, tags []string) error {
+	ret := _m.Called(ctx, deviceID, tags)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32, []string) error); ok {
+		r0 = rf(ctx, deviceID, tags)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetIPAssignmentNextHop provides a mock function with given fields: ctx, assignmentID, nextHop
+func (_m *Interface) SetIPAssignmentNextHop(ctx context.Context, assignmentID int32, nextHop string) (*swagger.NetworkTaskDump, error) {
+	ret := _m.Called(ctx, assignmentID, nextHop)
+
+	var r0 *swagger.NetworkTaskDump
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32, string) (*swagger.NetworkTaskDump, error)); ok {
+		return rf(ctx, assignmentID, nextHop)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int32, string) *swagger.NetworkTaskDump); ok {
+		r0 = rf(ctx, assignmentID, nextHop)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*swagger.NetworkTaskDump)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int32, string) error); ok {
+		r1 = rf(ctx, assignmentID, nextHop)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SplitIPAssignment provides a mock function with given fields: ctx, assignmentID
+func (_m *Interface) SplitIPAssignment(ctx context.Context, assignmentID int32) ([]swagger.IpAssignment, error) {
+	ret := _m.Called(ctx, assignmentID)
+
+	var r0 []swagger.IpAssignment
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32) ([]swagger.IpAssignment, error)); ok {
+		return rf(ctx, assignmentID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int32) []swagger.IpAssignment); ok {
+		r0 = rf(ctx, assignmentID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]swagger.IpAssignment)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int32) error); ok {
+		r1 = rf(ctx, assignmentID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateARecord provides a mock function with given fields: ctx, domainID, record
+func (_m *Interface) UpdateARecord(ctx context.Context, domainID int32, record swagger.ARecord) error {
+	ret := _m.Called(ctx, domainID, record)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32, swagger.ARecord) error); ok {
+		r0 = rf(ctx, domainID, record)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdatePTRRecord provides a mock function with given fields: ctx, recordID, update
+func (_m *Interface) UpdatePTRRecord(ctx context.Context, recordID int32, update swagger.PtrRecordUpdate) error {
+	ret := _m.Called(ctx, recordID, update)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32, swagger.PtrRecordUpdate) error); ok {
+		r0 = rf(ctx, recordID, update)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateVLAN provides a mock function with given fields: ctx, vlanID, update
+func (_m *Interface) UpdateVLAN(ctx context.Context, vlanID int32, update swagger.VlanUpdate) (*swagger.NetworkTaskDump, error) {
+	ret := _m.Called(ctx, vlanID, update)
+
+	var r0 *swagger.NetworkTaskDump
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int32, swagger.VlanUpdate) (*swagger.NetworkTaskDump, error)); ok {
+		return rf(ctx, vlanID, update)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int32, swagger.VlanUpdate) *swagger.NetworkTaskDump); ok {
+		r0 = rf(ctx, vlanID, update)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*swagger.NetworkTaskDump)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int32, swagger.VlanUpdate) error); ok {
+		r1 = rf(ctx, vlanID, update)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type mockConstructorTestingTNewInterface interface {
 	mock.TestingT
 	Cleanup(func())
@@ -0,0 +1,97 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package main provides the executable of the external-dns webhook provider.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/pkg/externaldns"
+	"k8s.io/klog/v2"
+)
+
+const (
+	hivelocityAPIKeyENVVar = "HIVELOCITY_API_KEY" // #nosec G101
+	domainsENVVar          = "HIVELOCITY_EXTERNAL_DNS_DOMAINS"
+
+	shutdownTimeout = 10 * time.Second
+)
+
+func main() {
+	listenAddress := flag.String("listen-address", "localhost:8888",
+		"Address of the webhook. external-dns expects it on localhost:8888.")
+	healthAddress := flag.String("health-address", ":8080",
+		"Address of the health endpoint /healthz.")
+	klog.InitFlags(nil)
+	flag.Parse()
+
+	apiKey := os.Getenv(hivelocityAPIKeyENVVar)
+	if apiKey == "" {
+		klog.Fatalf("environment variable %s is missing", hivelocityAPIKeyENVVar)
+	}
+	var domains []string
+	for _, domain := range strings.Split(os.Getenv(domainsENVVar), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			domains = append(domains, domain)
+		}
+	}
+
+	provider, err := externaldns.NewProvider(client.NewClient(apiKey), domains)
+	if err != nil {
+		klog.Fatalf("environment variable %s is missing: %v", domainsENVVar, err)
+	}
+
+	health := http.NewServeMux()
+	health.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	servers := []*http.Server{
+		{Addr: *listenAddress, Handler: externaldns.NewHandler(provider), ReadHeaderTimeout: 5 * time.Second},
+		{Addr: *healthAddress, Handler: health, ReadHeaderTimeout: 5 * time.Second},
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	for _, server := range servers {
+		go func(server *http.Server) {
+			klog.Infof("Listening on %s", server.Addr)
+			if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				klog.Fatalf("serving on %s failed: %v", server.Addr, err)
+			}
+		}(server)
+	}
+
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			klog.Errorf("shutting down %s failed: %v", server.Addr, err)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+// nodeConditionBandwidthBudgetPressure is true if the outbound public traffic
+// of the current month is above the threshold of the budget.
+const nodeConditionBandwidthBudgetPressure corev1.NodeConditionType = "BandwidthBudgetPressure"
+
+const (
+	// bandwidthStep is the interval of the traffic measurements in seconds.
+	bandwidthStep = 3600
+
+	// budgetInterface is the interface whose outbound traffic is billed.
+	budgetInterface = "public"
+
+	bytesPerGB = 1e9
+)
+
+// bandwidthSample is a traffic measurement. Rates are in bits per second.
+type bandwidthSample struct {
+	time     time.Time
+	duration time.Duration
+	in       float64
+	out      float64
+}
+
+// bandwidthKey identifies an interface of a node.
+type bandwidthKey struct {
+	node  string
+	iface string
+}
+
+// bandwidthTotals is the traffic of an interface in a month which was added to the counter.
+type bandwidthTotals struct {
+	month time.Time
+	in    float64
+	out   float64
+}
+
+// bandwidthController exports the traffic of the devices of all nodes as
+// metrics and warns if a node is about to exceed its monthly budget.
+type bandwidthController struct {
+	client      client.Interface
+	kubeClient  kubernetes.Interface
+	nodeLister  corelisters.NodeLister
+	nodesSynced cache.InformerSynced
+	recorder    record.EventRecorder
+	cfg         bandwidthConfig
+	now         func() time.Time
+
+	// exportedNodes are the nodes whose series were exported by the last poll.
+	exportedNodes map[string]bool
+
+	mu sync.Mutex
+	// counted is the traffic which was added to the counter, per node and interface.
+	counted map[bandwidthKey]bandwidthTotals
+}
+
+func startBandwidthController(
+	ctx context.Context,
+	initContext app.ControllerInitContext,
+	_ genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error) {
+	ctrl := newBandwidthController(
+		c.client,
+		completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName),
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		c.config.bandwidth,
+	)
+	go ctrl.Run(ctx)
+	return nil, true, nil
+}
+
+func newBandwidthController(
+	c client.Interface,
+	kubeClient kubernetes.Interface,
+	nodeInformer coreinformers.NodeInformer,
+	cfg bandwidthConfig,
+) *bandwidthController {
+	registerMetrics()
+	return &bandwidthController{
+		client:      c,
+		kubeClient:  kubeClient,
+		nodeLister:  nodeInformer.Lister(),
+		nodesSynced: nodeInformer.Informer().HasSynced,
+		recorder:    newEventRecorder(kubeClient, bandwidthControllerName),
+		cfg:         cfg,
+		now:         time.Now,
+		counted:     make(map[bandwidthKey]bandwidthTotals),
+	}
+}
+
+// Run reads the traffic until ctx is done.
+func (c *bandwidthController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.Info("Starting Hivelocity bandwidth controller")
+	defer klog.Info("Shutting down Hivelocity bandwidth controller")
+
+	if !cache.WaitForNamedCacheSync(bandwidthControllerName, ctx.Done(), c.nodesSynced) {
+		return
+	}
+
+	wait.UntilWithContext(ctx, c.reconcileNodes, c.cfg.pollInterval)
+}
+
+func (c *bandwidthController) reconcileNodes(ctx context.Context) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("[bandwidthController] listing nodes failed: %v", err)
+		return
+	}
+
+	for _, node := range nodes {
+		if err := c.reconcileNode(ctx, node); err != nil {
+			klog.Errorf("[bandwidthController] node %q: %v", node.Name, err)
+		}
+	}
+	c.pruneCounted(nodes)
+	c.exportedNodes = deleteSeriesOfDeletedNodes(c.exportedNodes, nodes,
+		nodeBandwidthRate, nodeBandwidthMonthBytes, nodeBandwidthBytesTotal)
+}
+
+// pruneCounted forgets the traffic of nodes which have been deleted.
+func (c *bandwidthController) pruneCounted(nodes []*corev1.Node) {
+	names := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		names[node.Name] = true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.counted {
+		if !names[key.node] {
+			delete(c.counted, key)
+		}
+	}
+}
+
+func (c *bandwidthController) reconcileNode(ctx context.Context, node *corev1.Node) error {
+	if !isNodeInitialized(node) {
+		return nil
+	}
+
+	deviceID, err := getHivelocityDeviceIDFromNode(node)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] getHivelocityDeviceIDFromNode() failed: %w", err)
+	}
+
+	now := c.now().UTC()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var budgetBytes float64
+	for _, iface := range c.cfg.interfaces {
+		bandwidth, err := c.client.GetDeviceBandwidth(ctx, deviceID, iface, bandwidthStep, monthStart, now)
+		if err != nil {
+			return fmt.Errorf("[reconcileNode] GetDeviceBandwidth() failed: %w", err)
+		}
+
+		var samples []bandwidthSample
+		for i := range bandwidth {
+			samples = append(samples, parseBandwidthSamples(&bandwidth[i], monthStart)...)
+		}
+		inBytes, outBytes := c.exportSamples(node.Name, iface, monthStart, samples)
+		if iface == budgetInterface {
+			budgetBytes = outBytes
+		}
+		klog.V(4).Infof("[bandwidthController] node %q, interface %q: %.0f bytes in, %.0f bytes out this month",
+			node.Name, iface, inBytes, outBytes)
+	}
+
+	if c.cfg.monthlyBudgetGB == 0 {
+		return nil
+	}
+	return c.updateBudgetCondition(node, budgetBytes)
+}
+
+// exportSamples sets the metrics of the interface. Returns the traffic of the month.
+//
+// The counter gets the increase of the traffic of the month since the last
+// poll, so that samples which the API revises are corrected. The traffic
+// before the first poll is not added, so that a restart of the controller
+// does not show the whole month as a spike.
+func (c *bandwidthController) exportSamples(
+	nodeName, iface string,
+	month time.Time,
+	samples []bandwidthSample,
+) (inBytes, outBytes float64) {
+	for _, sample := range samples {
+		inBytes += sample.in * sample.duration.Seconds() / 8
+		outBytes += sample.out * sample.duration.Seconds() / 8
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := bandwidthKey{node: nodeName, iface: iface}
+	var newInBytes, newOutBytes float64
+	switch counted, ok := c.counted[key]; {
+	case !ok:
+	case counted.month.Equal(month):
+		// Counters can't decrease, a lower revised total is not subtracted.
+		newInBytes = math.Max(inBytes-counted.in, 0)
+		newOutBytes = math.Max(outBytes-counted.out, 0)
+	default:
+		// A new month has started.
+		newInBytes, newOutBytes = inBytes, outBytes
+	}
+	c.counted[key] = bandwidthTotals{month: month, in: inBytes, out: outBytes}
+
+	nodeBandwidthMonthBytes.WithLabelValues(nodeName, iface, "in").Set(inBytes)
+	nodeBandwidthMonthBytes.WithLabelValues(nodeName, iface, "out").Set(outBytes)
+	nodeBandwidthBytesTotal.WithLabelValues(nodeName, iface, "in").Add(newInBytes)
+	nodeBandwidthBytesTotal.WithLabelValues(nodeName, iface, "out").Add(newOutBytes)
+	if len(samples) > 0 {
+		latest := samples[len(samples)-1]
+		nodeBandwidthRate.WithLabelValues(nodeName, iface, "in").Set(latest.in)
+		nodeBandwidthRate.WithLabelValues(nodeName, iface, "out").Set(latest.out)
+	}
+	return inBytes, outBytes
+}
+
+func (c *bandwidthController) updateBudgetCondition(node *corev1.Node, usedBytes float64) error {
+	// The used traffic is not part of the message, so that the condition
+	// does not get updated on every poll. It is exported as metric.
+	condition := corev1.NodeCondition{
+		Type:   nodeConditionBandwidthBudgetPressure,
+		Status: corev1.ConditionFalse,
+		Reason: "BandwidthWithinBudget",
+		Message: fmt.Sprintf("Outbound public traffic of this month is below %.0f%% of the budget of %g GB",
+			c.cfg.budgetThreshold*100, c.cfg.monthlyBudgetGB),
+	}
+	if usedBytes/bytesPerGB >= c.cfg.monthlyBudgetGB*c.cfg.budgetThreshold {
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = "BandwidthBudgetAlmostExceeded"
+		condition.Message = fmt.Sprintf("Outbound public traffic of this month is above %.0f%% of the budget of %g GB",
+			c.cfg.budgetThreshold*100, c.cfg.monthlyBudgetGB)
+	}
+	return updateNodeCondition(c.kubeClient, c.recorder, node, condition)
+}
+
+// parseBandwidthSamples converts the rows of the bandwidth data into samples.
+// The columns are described by the legend in the metadata, for example
+// ["time", "in", "out"]. Without legend, this order is assumed. Rows without
+// time are spaced by bandwidthStep, beginning at start.
+func parseBandwidthSamples(bandwidth *hv.Bandwidth, start time.Time) []bandwidthSample {
+	timeIndex, inIndex, outIndex := 0, 1, 2
+	if legend := bandwidthLegend(bandwidth.Metadata); legend != nil {
+		timeIndex, inIndex, outIndex = -1, -1, -1
+		for i, name := range legend {
+			name = strings.ToLower(name)
+			switch {
+			case strings.Contains(name, "time") || strings.Contains(name, "date"):
+				timeIndex = i
+			case strings.HasPrefix(name, "in") || strings.HasPrefix(name, "rx"):
+				inIndex = i
+			case strings.HasPrefix(name, "out") || strings.HasPrefix(name, "tx"):
+				outIndex = i
+			}
+		}
+	}
+
+	value := func(row []float32, i int) float64 {
+		if i < 0 || i >= len(row) {
+			return 0
+		}
+		return float64(row[i])
+	}
+
+	samples := make([]bandwidthSample, 0, len(bandwidth.BandwidthData))
+	for i, row := range bandwidth.BandwidthData {
+		sampleTime := start.Add(time.Duration(i) * bandwidthStep * time.Second)
+		if timeIndex >= 0 && timeIndex < len(row) {
+			sampleTime = time.Unix(int64(row[timeIndex]), 0).UTC()
+		}
+		samples = append(samples, bandwidthSample{
+			time:     sampleTime,
+			duration: bandwidthStep * time.Second,
+			in:       value(row, inIndex),
+			out:      value(row, outIndex),
+		})
+	}
+
+	// The API may return condensed data with a larger step. The times are
+	// float32 and therefore imprecise, so the durations are rounded to steps.
+	for i := 0; i+1 < len(samples); i++ {
+		if d := samples[i+1].time.Sub(samples[i].time).Round(bandwidthStep * time.Second); d > 0 {
+			samples[i].duration = d
+		}
+	}
+	return samples
+}
+
+func bandwidthLegend(metadata interface{}) []string {
+	m, ok := metadata.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	raw, ok := m["legend"].([]interface{})
+	if !ok {
+		return nil
+	}
+	legend := make([]string, 0, len(raw))
+	for _, entry := range raw {
+		name, _ := entry.(string)
+		legend = append(legend, name)
+	}
+	return legend
+}
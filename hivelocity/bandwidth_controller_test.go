@@ -0,0 +1,138 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics/testutil"
+	nodeutil "k8s.io/component-helpers/node/util"
+)
+
+func Test_parseBandwidthSamples(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	t0 := float32(start.Unix())
+
+	samples := parseBandwidthSamples(&hv.Bandwidth{
+		Metadata: map[string]interface{}{"legend": []interface{}{"out", "in", "time"}},
+		BandwidthData: [][]float32{
+			{8, 16, t0},
+			{80, 160, t0 + 7200},
+		},
+	}, start)
+	require.Len(t, samples, 2)
+	require.Equal(t, bandwidthSample{time: start, duration: 2 * time.Hour, in: 16, out: 8}, samples[0])
+	// Times are float32, which are precise to about two minutes.
+	require.WithinDuration(t, start.Add(2*time.Hour), samples[1].time, 3*time.Minute)
+	require.Equal(t, time.Hour, samples[1].duration)
+	require.Equal(t, 160.0, samples[1].in)
+
+	// Without legend and time.
+	samples = parseBandwidthSamples(&hv.Bandwidth{BandwidthData: [][]float32{{0, 1, 2}}}, start)
+	require.Equal(t, []bandwidthSample{{time: time.Unix(0, 0).UTC(), duration: time.Hour, in: 1, out: 2}}, samples)
+}
+
+func Test_bandwidth_reconcileNode(t *testing.T) {
+	t.Parallel()
+	registerMetrics()
+	now := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	monthStart := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	m := mocks.NewInterface(t)
+	// 8 Gbit/s for one hour are 3600 GB.
+	m.On("GetDeviceBandwidth", mock.Anything, int32(dummyDeviceID), "public", int32(bandwidthStep), monthStart, now).
+		Return([]hv.Bandwidth{{BandwidthData: [][]float32{{float32(monthStart.Unix()), 0, 8e9}}}}, nil)
+
+	node := newNode("hivelocity://12345", "bandwidth-node")
+	kubeClient := fake.NewSimpleClientset(node)
+	recorder := record.NewFakeRecorder(10)
+	c := &bandwidthController{
+		client:     m,
+		kubeClient: kubeClient,
+		recorder:   recorder,
+		cfg: bandwidthConfig{
+			interfaces:      []string{"public"},
+			monthlyBudgetGB: 4000,
+			budgetThreshold: 0.9,
+		},
+		now:     func() time.Time { return now },
+		counted: make(map[bandwidthKey]bandwidthTotals),
+	}
+	require.NoError(t, c.reconcileNode(context.Background(), node))
+
+	monthBytes, err := testutil.GetGaugeMetricValue(nodeBandwidthMonthBytes.WithLabelValues("bandwidth-node", "public", "out"))
+	require.NoError(t, err)
+	require.InDelta(t, 3600e9, monthBytes, 1e6)
+
+	updatedNode, err := kubeClient.CoreV1().Nodes().Get(context.Background(), "bandwidth-node", metav1.GetOptions{})
+	require.NoError(t, err)
+	_, condition := nodeutil.GetNodeCondition(&updatedNode.Status, nodeConditionBandwidthBudgetPressure)
+	require.NotNil(t, condition)
+	require.Equal(t, corev1.ConditionTrue, condition.Status)
+	require.Len(t, recorder.Events, 1)
+
+	// The traffic before the first poll and the same sample twice are not counted.
+	require.NoError(t, c.reconcileNode(context.Background(), updatedNode))
+	total, err := testutil.GetCounterMetricValue(nodeBandwidthBytesTotal.WithLabelValues("bandwidth-node", "public", "out"))
+	require.NoError(t, err)
+	require.Zero(t, total)
+}
+
+func Test_bandwidth_exportSamples(t *testing.T) {
+	t.Parallel()
+	registerMetrics()
+	may := time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+	june := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	sample := func(start time.Time, out float64) bandwidthSample {
+		return bandwidthSample{time: start, duration: time.Hour, out: out}
+	}
+	counter := nodeBandwidthBytesTotal.WithLabelValues("export-node", "public", "out")
+	c := &bandwidthController{counted: make(map[bandwidthKey]bandwidthTotals)}
+
+	// The first poll is not counted.
+	c.exportSamples("export-node", "public", may, []bandwidthSample{sample(may, 8)})
+	total, err := testutil.GetCounterMetricValue(counter)
+	require.NoError(t, err)
+	require.Zero(t, total)
+
+	// A revised partial-hour sample is counted by its increase.
+	c.exportSamples("export-node", "public", may, []bandwidthSample{sample(may, 16)})
+	total, err = testutil.GetCounterMetricValue(counter)
+	require.NoError(t, err)
+	require.InDelta(t, 3600, total, 1e-6)
+
+	// The traffic of a new month is counted completely.
+	c.exportSamples("export-node", "public", june, []bandwidthSample{sample(june, 8)})
+	total, err = testutil.GetCounterMetricValue(counter)
+	require.NoError(t, err)
+	require.InDelta(t, 7200, total, 1e-6)
+
+	// The traffic of deleted nodes is forgotten.
+	c.pruneCounted(nil)
+	require.Empty(t, c.counted)
+}
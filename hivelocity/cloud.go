@@ -19,18 +19,28 @@ limitations under the License.
 package hivelocity
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
 
 	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	"k8s.io/client-go/informers"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/klog/v2"
 )
 
 // cloud implements cloudprovider.Interface for Hivelocity.
 type cloud struct {
-	instancesV2 *HVInstancesV2
+	// client only sees the devices of the cluster, apiClient sees all devices of the account.
+	client       client.Interface
+	apiClient    client.Interface
+	clusterName  string
+	config       hvConfig
+	instancesV2  *HVInstancesV2
+	zones        *zones
+	routes       *routes
+	loadBalancer cloudprovider.LoadBalancer
 }
 
 const (
@@ -56,17 +66,67 @@ func newCloud() (*cloud, error) {
 		return nil, errEnvVarMissing
 	}
 
+	cfg, err := readConfig()
+	if err != nil {
+		return nil, fmt.Errorf("[newCloud] readConfig() failed: %w", err)
+	}
+
 	klog.Infof("Hivelocity cloud controller manager %s started\n", providerVersion)
 
-	i2 := newHVInstanceV2(client.NewClient(apiKey))
+	c := &cloud{
+		apiClient: client.NewClient(apiKey),
+		config:    cfg,
+	}
+	c.setClusterName(cfg.clusterName)
+	return c, nil
+}
 
-	return &cloud{
-		instancesV2: i2,
-	}, nil
+// setClusterName scopes the client and the device lookups to the cluster and
+// the facilities. It must be called before Initialize.
+func (c *cloud) setClusterName(clusterName string) {
+	c.clusterName = clusterName
+	clusterClient := newClusterClient(c.apiClient, clusterName)
+	c.client = newFacilityClient(clusterClient, c.config.facilities)
+	// The device lookups see all devices, to reject the nodes outside of the
+	// cluster and the facilities instead of reporting them as deleted.
+	c.instancesV2 = newHVInstanceV2(c.apiClient)
+	c.instancesV2.clusterName = clusterName
+	c.instancesV2.facilities = c.config.facilities
+	c.zones = newZones(c.instancesV2, c.config.nodeName)
+}
+
+// clusterNameOr returns the cluster name of the cloud, or flagClusterName,
+// the value of --cluster-name, if the cloud is not scoped to a cluster.
+func (c *cloud) clusterNameOr(flagClusterName string) string {
+	if c.clusterName != "" {
+		return c.clusterName
+	}
+	return flagClusterName
 }
 
 // Initialize implements cloudprovider.Interface.Initialize.
-func (*cloud) Initialize(cloudprovider.ControllerClientBuilder, <-chan struct{}) {
+// It starts the node informer which is used by Routes, and the load balancers
+// of the configured mode.
+func (c *cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder, stop <-chan struct{}) {
+	kubeClient := clientBuilder.ClientOrDie("hivelocity-cloud-provider")
+	informerFactory := informers.NewSharedInformerFactory(kubeClient, 0)
+	c.routes = newRoutes(c.client, informerFactory.Core().V1().Nodes(), c.clusterName)
+	c.instancesV2.recorder = newEventRecorder(kubeClient, "hivelocity-cloud-provider")
+	informerFactory.Start(stop)
+
+	switch {
+	case c.config.loadBalancer.mode == loadBalancerModeDNS:
+		c.loadBalancer = newDNSLoadBalancer(c.client, c.config.loadBalancer)
+	case len(c.config.loadBalancer.pools) > 0:
+		lb := newLoadBalancer(c.client, kubeClient, c.config.loadBalancer)
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-stop
+			cancel()
+		}()
+		go lb.tracker.Run(ctx)
+		c.loadBalancer = lb
+	}
 }
 
 // Instances implements cloudprovider.Interface.Instances.
@@ -81,14 +141,23 @@ func (c *cloud) InstancesV2() (cloudprovider.InstancesV2, bool) {
 }
 
 // Zones implements cloudprovider.Interface.Zones.
-func (*cloud) Zones() (cloudprovider.Zones, bool) {
-	// we only implement InstancesV2
-	return nil, false
+// Zones are reported by InstancesV2, Zones is only for legacy components.
+func (c *cloud) Zones() (cloudprovider.Zones, bool) {
+	if c.zones == nil {
+		return nil, false
+	}
+	return c.zones, true
 }
 
 // LoadBalancer implements cloudprovider.Interface.LoadBalancer.
-func (*cloud) LoadBalancer() (cloudprovider.LoadBalancer, bool) {
-	return nil, false // TODO: Up to now Hivelocity has not API for LoadBalancers.
+// Hivelocity has no load balancer product. Depending on the mode, IP
+// assignments are used as virtual IPs, or DNS names resolve to the nodes.
+// Virtual IPs are only available if pools are configured.
+func (c *cloud) LoadBalancer() (cloudprovider.LoadBalancer, bool) {
+	if c.loadBalancer == nil {
+		return nil, false
+	}
+	return c.loadBalancer, true
 }
 
 // Clusters implements cloudprovider.Interface.Clusters.
@@ -97,8 +166,12 @@ func (*cloud) Clusters() (cloudprovider.Clusters, bool) {
 }
 
 // Routes implements cloudprovider.Interface.Routes.
-func (*cloud) Routes() (cloudprovider.Routes, bool) {
-	return nil, false // TODO: Will we implement this optional method?
+// The routes are only available after Initialize.
+func (c *cloud) Routes() (cloudprovider.Routes, bool) {
+	if c.routes == nil {
+		return nil, false
+	}
+	return c.routes, true
 }
 
 // ProviderName implements cloudprovider.Interface.ProviderName.
@@ -107,8 +180,7 @@ func (*cloud) ProviderName() string {
 }
 
 // HasClusterID implements cloudprovider.Interface.HasClusterID.
-func (*cloud) HasClusterID() bool {
-	// TODO: The meaning if this method is unclear.
-	// Waiting for clarification: https://github.com/kubernetes/cloud-provider/issues/64
-	return true
+// It returns true if the cloud is scoped to the devices of a cluster.
+func (c *cloud) HasClusterID() bool {
+	return c.clusterName != ""
 }
@@ -0,0 +1,152 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/pkg/hvutils"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+)
+
+// defaultClusterName is the default of --cluster-name. It does not scope the
+// CCM, as every cluster has it unless it is configured.
+const defaultClusterName = "kubernetes"
+
+var (
+	errAmbiguousClusterName = errors.New("devices of the node have different cluster names")
+
+	errDeviceNotInCluster = errors.New("device has not the cluster name tag of the cluster")
+)
+
+// clusterClient is a client.Interface which only lists the devices with the
+// tag caphv-cluster-name=<clusterName>. Fetching a device of another cluster
+// fails with errDeviceNotInCluster, so that it is not mistaken for a deleted
+// device.
+type clusterClient struct {
+	client.Interface
+	clusterName string
+}
+
+// newClusterClient returns a client which is scoped to the cluster. An empty
+// cluster name returns c, which sees all devices of the account.
+func newClusterClient(c client.Interface, clusterName string) client.Interface {
+	if clusterName == "" {
+		return c
+	}
+	return &clusterClient{Interface: c, clusterName: clusterName}
+}
+
+// GetBareMetalDevice returns errDeviceNotInCluster for devices of other clusters.
+func (c *clusterClient) GetBareMetalDevice(ctx context.Context, deviceID int32) (*hv.BareMetalDevice, error) {
+	device, err := c.Interface.GetBareMetalDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the errors of the client are passed through.
+	}
+	if !hvutils.HasClusterNameTag(device.Tags, c.clusterName) {
+		return nil, fmt.Errorf("[GetBareMetalDevice] device %d is not in cluster %q: %w",
+			deviceID, c.clusterName, errDeviceNotInCluster)
+	}
+	return device, nil
+}
+
+// ListDevices returns the devices of the cluster.
+func (c *clusterClient) ListDevices(ctx context.Context) ([]hv.BareMetalDevice, error) {
+	devices, err := c.Interface.ListDevices(ctx)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the errors of the client are passed through.
+	}
+	clusterDevices := make([]hv.BareMetalDevice, 0, len(devices))
+	for _, device := range devices {
+		if hvutils.HasClusterNameTag(device.Tags, c.clusterName) {
+			clusterDevices = append(clusterDevices, device)
+		}
+	}
+	return clusterDevices, nil
+}
+
+// InitClusterName scopes the cloud to the devices of its cluster. The name
+// is the first of:
+//
+//   - the environment variable HIVELOCITY_CLUSTER_NAME,
+//   - clusterName, the value of --cluster-name, unless it is the default,
+//   - the caphv-cluster-name tag of the device of the node in NODE_NAME.
+//
+// Without a name, the cloud sees all devices of the account. Clouds of other
+// providers are not changed.
+func InitClusterName(ctx context.Context, cloudInterface cloudprovider.Interface, clusterName string) error {
+	c, ok := cloudInterface.(*cloud)
+	if !ok {
+		return nil
+	}
+
+	name := c.config.clusterName
+	if name == "" && clusterName != defaultClusterName {
+		name = clusterName
+	}
+	if name == "" && c.config.nodeName != "" {
+		var err error
+		name, err = nodeClusterName(ctx, c.apiClient, c.config.nodeName)
+		if err != nil {
+			return fmt.Errorf("[InitClusterName] nodeClusterName() failed: %w", err)
+		}
+	}
+
+	c.setClusterName(name)
+	if name == "" {
+		klog.Warning("No cluster name found, all devices of the account are considered")
+	} else {
+		klog.Infof("Only devices with the tag %s%s are considered", hvutils.ClusterNameTagPrefix, name)
+	}
+	return nil
+}
+
+// nodeClusterName returns the caphv-cluster-name tag of the devices with the
+// machine name of the node, or "" if none of them has the tag.
+func nodeClusterName(ctx context.Context, c client.Interface, nodeName string) (string, error) {
+	devices, err := c.ListDevices(ctx)
+	if err != nil {
+		return "", fmt.Errorf("[nodeClusterName] ListDevices() failed: %w", err)
+	}
+
+	var clusterName string
+	for _, device := range devices {
+		machineName, err := hvutils.GetMachineNameFromTags(device.Tags)
+		if err != nil || machineName != nodeName {
+			continue
+		}
+		name, err := hvutils.GetClusterNameFromTags(device.Tags)
+		if errors.Is(err, hvutils.ErrNoClusterNameFound) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("[nodeClusterName] GetClusterNameFromTags() failed. deviceID %d: %w",
+				device.DeviceId, err)
+		}
+		if clusterName != "" && clusterName != name {
+			return "", fmt.Errorf("[nodeClusterName] node %q, cluster names %q and %q: %w",
+				nodeName, clusterName, name, errAmbiguousClusterName)
+		}
+		clusterName = name
+	}
+	return clusterName, nil
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/record"
+)
+
+func newClusterTestClient(t *testing.T) *mocks.Interface {
+	t.Helper()
+	m := mocks.NewInterface(t)
+	prod := hv.BareMetalDevice{DeviceId: 1, LocationName: "LAX1",
+		Tags: []string{"caphv-machine-name=worker-0", "caphv-cluster-name=prod"}}
+	staging := hv.BareMetalDevice{DeviceId: 2, LocationName: "DAL1",
+		Tags: []string{"caphv-machine-name=worker-0", "caphv-cluster-name=staging"}}
+	m.On("ListDevices", mock.Anything).Return([]hv.BareMetalDevice{staging, prod}, nil).Maybe()
+	m.On("GetBareMetalDevice", mock.Anything, int32(1)).Return(&prod, nil).Maybe()
+	m.On("GetBareMetalDevice", mock.Anything, int32(2)).Return(&staging, nil).Maybe()
+	return m
+}
+
+func Test_clusterClient(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	m := newClusterTestClient(t)
+	require.Same(t, m, newClusterClient(m, ""))
+
+	c := newClusterClient(m, "prod")
+	devices, err := c.ListDevices(ctx)
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	require.Equal(t, int32(1), devices[0].DeviceId)
+
+	device, err := c.GetBareMetalDevice(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, int32(1), device.DeviceId)
+
+	_, err = c.GetBareMetalDevice(ctx, 2)
+	require.ErrorIs(t, err, errDeviceNotInCluster)
+	require.NotErrorIs(t, err, client.ErrNoSuchDevice)
+}
+
+func Test_HVInstancesV2_clusterName(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	m := newClusterTestClient(t)
+	untagged := hv.BareMetalDevice{DeviceId: 3, Tags: []string{"caphv-machine-name=worker-1", "caphv-device-type=x"}}
+	m.On("GetBareMetalDevice", mock.Anything, int32(3)).Return(&untagged, nil)
+	recorder := record.NewFakeRecorder(10)
+	i2 := newHVInstanceV2(m)
+	i2.clusterName = "prod"
+	i2.recorder = recorder
+
+	// Nodes never match devices of other clusters.
+	device, err := i2.lookUpDevice(ctx, newNode("", "worker-0"))
+	require.NoError(t, err)
+	require.Equal(t, int32(1), device.DeviceId)
+
+	_, err = i2.lookUpDevice(ctx, newNode("hivelocity://2", "worker-0"))
+	require.ErrorIs(t, err, errDeviceNotInCluster)
+
+	// An untagged device is rejected instead of being reported as deleted,
+	// so that the node lifecycle controller does not delete the node.
+	node := newNode("hivelocity://3", "worker-1")
+	node.UID = "uid-worker-1"
+	exists, err := i2.InstanceExists(ctx, node)
+	require.ErrorIs(t, err, errDeviceNotInCluster)
+	require.False(t, exists)
+	require.Contains(t, <-recorder.Events, "DeviceNotInCluster Rejected device 3")
+}
+
+func Test_InitClusterName(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name            string
+		config          hvConfig
+		flagClusterName string
+		want            string
+		wantErr         error
+	}{
+		{
+			name:            "config takes precedence",
+			config:          hvConfig{clusterName: "staging", nodeName: "worker-0"},
+			flagClusterName: "prod",
+			want:            "staging",
+		},
+		{
+			name:            "flag",
+			config:          hvConfig{nodeName: "worker-0"},
+			flagClusterName: "prod",
+			want:            "prod",
+		},
+		{
+			name:            "tag of the node",
+			config:          hvConfig{nodeName: "worker-1"},
+			flagClusterName: defaultClusterName,
+			want:            "prod",
+		},
+		{
+			name:            "node without tag",
+			config:          hvConfig{nodeName: "worker-2"},
+			flagClusterName: defaultClusterName,
+		},
+		{
+			name:            "node in two clusters",
+			config:          hvConfig{nodeName: "worker-0"},
+			flagClusterName: defaultClusterName,
+			wantErr:         errAmbiguousClusterName,
+		},
+		{
+			name:            "unscoped",
+			flagClusterName: defaultClusterName,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := mocks.NewInterface(t)
+			m.On("ListDevices", mock.Anything).Return([]hv.BareMetalDevice{
+				{DeviceId: 1, Tags: []string{"caphv-machine-name=worker-0", "caphv-cluster-name=prod"}},
+				{DeviceId: 2, Tags: []string{"caphv-machine-name=worker-0", "caphv-cluster-name=staging"}},
+				{DeviceId: 3, Tags: []string{"caphv-machine-name=worker-1", "caphv-cluster-name=prod"}},
+				{DeviceId: 4, Tags: []string{"caphv-machine-name=worker-2"}},
+			}, nil).Maybe()
+			c := &cloud{apiClient: m, config: tt.config}
+			c.setClusterName(tt.config.clusterName)
+
+			err := InitClusterName(context.Background(), c, tt.flagClusterName)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, c.clusterName)
+			require.Equal(t, tt.want != "", c.HasClusterID())
+			require.Equal(t, tt.want != "", c.client != client.Interface(m))
+		})
+	}
+}
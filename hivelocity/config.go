@@ -0,0 +1,578 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hvConfig contains the configuration of the cloud controller manager.
+// All values are read from environment variables, like the API key.
+type hvConfig struct {
+	// nodeName is the name of the node the CCM runs on.
+	nodeName string
+
+	// clusterName scopes the CCM to the devices with the tag caphv-cluster-name=<clusterName>.
+	// It takes precedence over --cluster-name.
+	clusterName string
+
+	// facilities are the locations (LocationName) of the devices the CCM manages.
+	// All locations are managed if it is empty.
+	facilities []string
+
+	ipmi              ipmiConfig
+	remediation       remediationConfig
+	rollingReload     rollingReloadConfig
+	labelSync         labelSyncConfig
+	nodeTags          nodeTagsConfig
+	hardwareLabels    hardwareLabelsConfig
+	topology          topologyConfig
+	deviceAnnotations deviceAnnotationsConfig
+	cost              costConfig
+	bandwidth         bandwidthConfig
+	switchPorts       switchPortsConfig
+	nodeIPAM          nodeIPAMConfig
+	privateVLAN       privateVLANConfig
+	loadBalancer      loadBalancerConfig
+	metalLB           metalLBConfig
+	controlPlaneVIP   controlPlaneVIPConfig
+	ptrRecords        ptrRecordsConfig
+}
+
+// ipmiConfig configures the optional IPMI sensor controller.
+type ipmiConfig struct {
+	// pollInterval is the time between two reads of the IPMI sensors of all nodes.
+	pollInterval time.Duration
+}
+
+// remediationConfig configures the optional remediation controller.
+type remediationConfig struct {
+	// pollInterval is the time between two reconciliations of all HivelocityRemediations.
+	pollInterval time.Duration
+
+	// minInterval is the minimal time between two remediations of the same node.
+	minInterval time.Duration
+}
+
+// rollingReloadConfig configures the optional rolling reload controller.
+type rollingReloadConfig struct {
+	// pollInterval is the time between two reconciliations of all HivelocityRollingReloads.
+	pollInterval time.Duration
+}
+
+// labelSyncConfig configures the optional controller which mirrors node labels onto device tags.
+type labelSyncConfig struct {
+	// pollInterval is the time between two syncs of all nodes.
+	pollInterval time.Duration
+
+	// labels contains the keys of the node labels which get mirrored.
+	labels []string
+}
+
+// nodeTagsConfig configures the optional controller which applies device tags to nodes.
+type nodeTagsConfig struct {
+	// pollInterval is the time between two reconciliations of all nodes.
+	pollInterval time.Duration
+}
+
+// hardwareLabelsConfig configures the optional controller which labels nodes with hardware specs.
+type hardwareLabelsConfig struct {
+	// pollInterval is the time between two reconciliations of all nodes.
+	pollInterval time.Duration
+
+	// productCacheTTL is the time the specs of a product are cached.
+	productCacheTTL time.Duration
+}
+
+// topologyConfig configures the optional controller which labels nodes with their order group.
+type topologyConfig struct {
+	// pollInterval is the time between two reconciliations of all nodes.
+	pollInterval time.Duration
+}
+
+// deviceAnnotationsConfig configures the optional controller which annotates nodes with their device identity.
+type deviceAnnotationsConfig struct {
+	// pollInterval is the time between two refreshes of the annotations of all nodes.
+	pollInterval time.Duration
+}
+
+// costConfig configures the optional controller which exports the costs of the nodes.
+type costConfig struct {
+	// pollInterval is the time between two reads of the services of all nodes.
+	pollInterval time.Duration
+}
+
+// bandwidthConfig configures the optional controller which exports the traffic of the nodes.
+type bandwidthConfig struct {
+	// pollInterval is the time between two reads of the traffic of all nodes.
+	pollInterval time.Duration
+
+	// interfaces contains the interfaces which get read, like "public" or "eth0".
+	interfaces []string
+
+	// monthlyBudgetGB is the outbound public traffic per node and month. Zero disables the budget.
+	monthlyBudgetGB float64
+
+	// budgetThreshold is the fraction of the budget at which the node condition becomes true.
+	budgetThreshold float64
+}
+
+// switchPortsConfig configures the optional controller which checks the switch ports of the nodes.
+type switchPortsConfig struct {
+	// pollInterval is the time between two checks of the switch ports of all nodes.
+	pollInterval time.Duration
+
+	// expectedMTU is the MTU every port should have. Zero disables the check.
+	expectedMTU int
+}
+
+// nodeIPAMConfig configures the optional controller which allocates the pod CIDRs of the nodes.
+type nodeIPAMConfig struct {
+	// pollInterval is the time between two checks of all nodes without pod CIDRs.
+	pollInterval time.Duration
+
+	// pools contains the CIDRs of the IP assignments which may be used for pod CIDRs.
+	// The IP families of the pod CIDRs follow the order of the pools.
+	pools []*net.IPNet
+
+	// ipv4PrefixLength is the prefix length of IPv4 pod CIDRs.
+	ipv4PrefixLength int
+
+	// ipv6PrefixLength is the prefix length of IPv6 pod CIDRs.
+	ipv6PrefixLength int
+}
+
+// privateVLANConfig configures the optional controller which adds the private ports of the nodes to a VLAN.
+type privateVLANConfig struct {
+	// pollInterval is the time between two syncs of the VLAN.
+	pollInterval time.Duration
+
+	// vlanID is the ID of the VLAN. Zero means that it is not configured.
+	vlanID int
+
+	// removeDeletedNodes removes the ports of devices of the cluster which have no node.
+	removeDeletedNodes bool
+}
+
+// loadBalancerConfig configures the load balancers of services.
+type loadBalancerConfig struct {
+	// mode is either loadBalancerModeIPAssignment or loadBalancerModeDNS.
+	mode string
+
+	// pools contains the CIDRs of the IP assignments which may be used for virtual IPs.
+	// Load balancers of mode loadBalancerModeIPAssignment are disabled if it is empty.
+	pools []*net.IPNet
+
+	// dnsZone is the name of the zone which contains the records of mode loadBalancerModeDNS.
+	dnsZone string
+
+	// dnsTTL is the TTL of the records in seconds.
+	dnsTTL int
+}
+
+// metalLBConfig configures the optional controller which syncs IP assignments into a MetalLB IPAddressPool.
+type metalLBConfig struct {
+	// pollInterval is the time between two syncs of the pool.
+	pollInterval time.Duration
+
+	// description selects the assignments by their description. The
+	// controller does not start if it is nil.
+	description *regexp.Regexp
+
+	// namespace is the namespace of the IPAddressPool.
+	namespace string
+
+	// poolName is the name of the IPAddressPool.
+	poolName string
+}
+
+// controlPlaneVIPConfig configures the optional controller which moves the IP
+// assignment of the API server endpoint to a control-plane node.
+type controlPlaneVIPConfig struct {
+	// assignmentID is the ID of the IP assignment. Zero means that it is not configured.
+	assignmentID int
+
+	// cooldown is the minimum time between two moves of the assignment.
+	cooldown time.Duration
+
+	// pollInterval is the time between two checks of the assignment by the leader.
+	pollInterval time.Duration
+}
+
+// ptrRecordsConfig configures the optional controller which sets the reverse DNS records of the nodes.
+type ptrRecordsConfig struct {
+	// pollInterval is the time between two syncs of the records.
+	pollInterval time.Duration
+
+	// template is the name of the records, see ptrRecordName. The
+	// controller does not start if it is empty.
+	template string
+}
+
+// Modes of the load balancers.
+const (
+	// loadBalancerModeIPAssignment routes an IP assignment to a single node.
+	loadBalancerModeIPAssignment = "ip-assignment"
+
+	// loadBalancerModeDNS publishes a name which resolves to all ready nodes.
+	loadBalancerModeDNS = "dns"
+)
+
+const (
+	ipmiPollIntervalENVVar              = "HIVELOCITY_IPMI_POLL_INTERVAL"
+	remediationPollIntervalENVVar       = "HIVELOCITY_REMEDIATION_POLL_INTERVAL"
+	remediationMinIntervalENVVar        = "HIVELOCITY_REMEDIATION_MIN_INTERVAL"
+	rollingReloadPollIntervalENVVar     = "HIVELOCITY_ROLLING_RELOAD_POLL_INTERVAL"
+	labelSyncPollIntervalENVVar         = "HIVELOCITY_LABEL_SYNC_POLL_INTERVAL"
+	labelSyncLabelsENVVar               = "HIVELOCITY_LABEL_SYNC_LABELS"
+	nodeTagsPollIntervalENVVar          = "HIVELOCITY_NODE_TAGS_POLL_INTERVAL"
+	hardwareLabelsPollIntervalENVVar    = "HIVELOCITY_HARDWARE_LABELS_POLL_INTERVAL"
+	productCacheTTLENVVar               = "HIVELOCITY_PRODUCT_CACHE_TTL"
+	topologyPollIntervalENVVar          = "HIVELOCITY_TOPOLOGY_POLL_INTERVAL"
+	deviceAnnotationsPollIntervalENVVar = "HIVELOCITY_DEVICE_ANNOTATIONS_POLL_INTERVAL"
+	costPollIntervalENVVar              = "HIVELOCITY_COST_POLL_INTERVAL"
+	bandwidthPollIntervalENVVar         = "HIVELOCITY_BANDWIDTH_POLL_INTERVAL"
+	bandwidthInterfacesENVVar           = "HIVELOCITY_BANDWIDTH_INTERFACES"
+	bandwidthMonthlyBudgetGBENVVar      = "HIVELOCITY_BANDWIDTH_MONTHLY_BUDGET_GB"
+	bandwidthBudgetThresholdENVVar      = "HIVELOCITY_BANDWIDTH_BUDGET_THRESHOLD"
+	switchPortsPollIntervalENVVar       = "HIVELOCITY_SWITCH_PORTS_POLL_INTERVAL"
+	switchPortsExpectedMTUENVVar        = "HIVELOCITY_SWITCH_PORTS_EXPECTED_MTU"
+	nodeIPAMPollIntervalENVVar          = "HIVELOCITY_NODE_IPAM_POLL_INTERVAL"
+	nodeIPAMPoolsENVVar                 = "HIVELOCITY_NODE_IPAM_POOLS"
+	nodeIPAMIPv4PrefixLengthENVVar      = "HIVELOCITY_NODE_IPAM_IPV4_PREFIX_LENGTH"
+	nodeIPAMIPv6PrefixLengthENVVar      = "HIVELOCITY_NODE_IPAM_IPV6_PREFIX_LENGTH"
+	privateVLANPollIntervalENVVar       = "HIVELOCITY_PRIVATE_VLAN_POLL_INTERVAL"
+	privateVLANIDENVVar                 = "HIVELOCITY_PRIVATE_VLAN_ID"
+	privateVLANRemoveDeletedNodesENVVar = "HIVELOCITY_PRIVATE_VLAN_REMOVE_DELETED_NODES"
+	loadBalancerModeENVVar              = "HIVELOCITY_LOAD_BALANCER_MODE"
+	loadBalancerPoolsENVVar             = "HIVELOCITY_LOAD_BALANCER_POOLS"
+	loadBalancerDNSZoneENVVar           = "HIVELOCITY_LOAD_BALANCER_DNS_ZONE"
+	loadBalancerDNSTTLENVVar            = "HIVELOCITY_LOAD_BALANCER_DNS_TTL"
+	metalLBPollIntervalENVVar           = "HIVELOCITY_METALLB_POLL_INTERVAL"
+	metalLBDescriptionENVVar            = "HIVELOCITY_METALLB_DESCRIPTION"
+	metalLBNamespaceENVVar              = "HIVELOCITY_METALLB_NAMESPACE"
+	metalLBPoolNameENVVar               = "HIVELOCITY_METALLB_POOL_NAME"
+	controlPlaneVIPAssignmentIDENVVar   = "HIVELOCITY_CONTROL_PLANE_VIP_ASSIGNMENT_ID"
+	controlPlaneVIPCooldownENVVar       = "HIVELOCITY_CONTROL_PLANE_VIP_COOLDOWN"
+	controlPlaneVIPPollIntervalENVVar   = "HIVELOCITY_CONTROL_PLANE_VIP_POLL_INTERVAL"
+	ptrRecordsPollIntervalENVVar        = "HIVELOCITY_PTR_RECORDS_POLL_INTERVAL"
+	ptrRecordsTemplateENVVar            = "HIVELOCITY_PTR_RECORDS_TEMPLATE"
+	nodeNameENVVar                      = "NODE_NAME"
+	clusterNameENVVar                   = "HIVELOCITY_CLUSTER_NAME"
+	facilitiesENVVar                    = "HIVELOCITY_FACILITIES"
+)
+
+var errInvalidEnvVar = errors.New("invalid value of environment variable")
+
+// readConfig reads the configuration from the environment. Unset variables
+// fall back to their defaults.
+func readConfig() (hvConfig, error) {
+	var cfg hvConfig
+	var err error
+
+	cfg.nodeName = os.Getenv(nodeNameENVVar)
+	cfg.clusterName = strings.TrimSpace(os.Getenv(clusterNameENVVar))
+	cfg.facilities = envStringSlice(facilitiesENVVar)
+
+	cfg.ipmi.pollInterval, err = envDuration(ipmiPollIntervalENVVar, 5*time.Minute)
+	if err != nil {
+		return hvConfig{}, err
+	}
+
+	cfg.remediation.pollInterval, err = envDuration(remediationPollIntervalENVVar, 15*time.Second)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	cfg.remediation.minInterval, err = envDuration(remediationMinIntervalENVVar, time.Hour)
+	if err != nil {
+		return hvConfig{}, err
+	}
+
+	cfg.rollingReload.pollInterval, err = envDuration(rollingReloadPollIntervalENVVar, 30*time.Second)
+	if err != nil {
+		return hvConfig{}, err
+	}
+
+	cfg.labelSync.pollInterval, err = envDuration(labelSyncPollIntervalENVVar, 5*time.Minute)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	cfg.labelSync.labels = envStringSlice(labelSyncLabelsENVVar)
+	for _, key := range cfg.labelSync.labels {
+		// The tags of the cluster-api provider must not be overwritten.
+		if strings.HasPrefix(key, "caphv-") {
+			return hvConfig{}, fmt.Errorf("[readConfig] %s contains %q, caphv- tags are reserved: %w",
+				labelSyncLabelsENVVar, key, errInvalidEnvVar)
+		}
+	}
+
+	cfg.nodeTags.pollInterval, err = envDuration(nodeTagsPollIntervalENVVar, 5*time.Minute)
+	if err != nil {
+		return hvConfig{}, err
+	}
+
+	cfg.hardwareLabels.pollInterval, err = envDuration(hardwareLabelsPollIntervalENVVar, 10*time.Minute)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	cfg.hardwareLabels.productCacheTTL, err = envDuration(productCacheTTLENVVar, 24*time.Hour)
+	if err != nil {
+		return hvConfig{}, err
+	}
+
+	cfg.topology.pollInterval, err = envDuration(topologyPollIntervalENVVar, 10*time.Minute)
+	if err != nil {
+		return hvConfig{}, err
+	}
+
+	cfg.deviceAnnotations.pollInterval, err = envDuration(deviceAnnotationsPollIntervalENVVar, 10*time.Minute)
+	if err != nil {
+		return hvConfig{}, err
+	}
+
+	cfg.cost.pollInterval, err = envDuration(costPollIntervalENVVar, time.Hour)
+	if err != nil {
+		return hvConfig{}, err
+	}
+
+	cfg.bandwidth.pollInterval, err = envDuration(bandwidthPollIntervalENVVar, 15*time.Minute)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	cfg.bandwidth.interfaces = envStringSlice(bandwidthInterfacesENVVar)
+	if len(cfg.bandwidth.interfaces) == 0 {
+		cfg.bandwidth.interfaces = []string{"public", "private"}
+	}
+	cfg.bandwidth.monthlyBudgetGB, err = envFloat(bandwidthMonthlyBudgetGBENVVar, 0)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	if cfg.bandwidth.monthlyBudgetGB > 0 && !slices.Contains(cfg.bandwidth.interfaces, budgetInterface) {
+		return hvConfig{}, fmt.Errorf("[readConfig] %s is set, but %s=%s does not contain %q: %w",
+			bandwidthMonthlyBudgetGBENVVar, bandwidthInterfacesENVVar, strings.Join(cfg.bandwidth.interfaces, ","),
+			budgetInterface, errInvalidEnvVar)
+	}
+	cfg.bandwidth.budgetThreshold, err = envFloat(bandwidthBudgetThresholdENVVar, 0.9)
+	if err != nil {
+		return hvConfig{}, err
+	}
+
+	cfg.switchPorts.pollInterval, err = envDuration(switchPortsPollIntervalENVVar, 5*time.Minute)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	cfg.switchPorts.expectedMTU, err = envInt(switchPortsExpectedMTUENVVar, 0)
+	if err != nil {
+		return hvConfig{}, err
+	}
+
+	cfg.nodeIPAM.pollInterval, err = envDuration(nodeIPAMPollIntervalENVVar, time.Minute)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	cfg.nodeIPAM.pools, err = envCIDRs(nodeIPAMPoolsENVVar)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	cfg.nodeIPAM.ipv4PrefixLength, err = envInt(nodeIPAMIPv4PrefixLengthENVVar, 28)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	if cfg.nodeIPAM.ipv4PrefixLength > 32 {
+		return hvConfig{}, fmt.Errorf("[readConfig] %s=%d exceeds 32: %w",
+			nodeIPAMIPv4PrefixLengthENVVar, cfg.nodeIPAM.ipv4PrefixLength, errInvalidEnvVar)
+	}
+	cfg.nodeIPAM.ipv6PrefixLength, err = envInt(nodeIPAMIPv6PrefixLengthENVVar, 64)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	if cfg.nodeIPAM.ipv6PrefixLength > 128 {
+		return hvConfig{}, fmt.Errorf("[readConfig] %s=%d exceeds 128: %w",
+			nodeIPAMIPv6PrefixLengthENVVar, cfg.nodeIPAM.ipv6PrefixLength, errInvalidEnvVar)
+	}
+
+	cfg.privateVLAN.pollInterval, err = envDuration(privateVLANPollIntervalENVVar, time.Minute)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	cfg.privateVLAN.vlanID, err = envInt(privateVLANIDENVVar, 0)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	cfg.privateVLAN.removeDeletedNodes, err = envBool(privateVLANRemoveDeletedNodesENVVar, false)
+	if err != nil {
+		return hvConfig{}, err
+	}
+
+	cfg.loadBalancer.mode = os.Getenv(loadBalancerModeENVVar)
+	switch cfg.loadBalancer.mode {
+	case "":
+		cfg.loadBalancer.mode = loadBalancerModeIPAssignment
+	case loadBalancerModeIPAssignment, loadBalancerModeDNS:
+	default:
+		return hvConfig{}, fmt.Errorf("[readConfig] %s=%q is neither %q nor %q: %w",
+			loadBalancerModeENVVar, cfg.loadBalancer.mode,
+			loadBalancerModeIPAssignment, loadBalancerModeDNS, errInvalidEnvVar)
+	}
+	cfg.loadBalancer.pools, err = envCIDRs(loadBalancerPoolsENVVar)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	cfg.loadBalancer.dnsZone = strings.TrimSuffix(os.Getenv(loadBalancerDNSZoneENVVar), ".")
+	if cfg.loadBalancer.mode == loadBalancerModeDNS && cfg.loadBalancer.dnsZone == "" {
+		return hvConfig{}, fmt.Errorf("[readConfig] %s is required by %s=%s: %w",
+			loadBalancerDNSZoneENVVar, loadBalancerModeENVVar, loadBalancerModeDNS, errInvalidEnvVar)
+	}
+	cfg.loadBalancer.dnsTTL, err = envInt(loadBalancerDNSTTLENVVar, 60)
+	if err != nil {
+		return hvConfig{}, err
+	}
+
+	cfg.metalLB.pollInterval, err = envDuration(metalLBPollIntervalENVVar, 5*time.Minute)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	if value := os.Getenv(metalLBDescriptionENVVar); value != "" {
+		cfg.metalLB.description, err = regexp.Compile(value)
+		if err != nil {
+			return hvConfig{}, fmt.Errorf("[readConfig] %s=%q: %w", metalLBDescriptionENVVar, value, errInvalidEnvVar)
+		}
+	}
+	cfg.metalLB.namespace = envString(metalLBNamespaceENVVar, "metallb-system")
+	cfg.metalLB.poolName = envString(metalLBPoolNameENVVar, "hivelocity")
+
+	cfg.controlPlaneVIP.assignmentID, err = envInt(controlPlaneVIPAssignmentIDENVVar, 0)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	cfg.controlPlaneVIP.cooldown, err = envDuration(controlPlaneVIPCooldownENVVar, time.Minute)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	cfg.controlPlaneVIP.pollInterval, err = envDuration(controlPlaneVIPPollIntervalENVVar, 10*time.Second)
+	if err != nil {
+		return hvConfig{}, err
+	}
+
+	cfg.ptrRecords.pollInterval, err = envDuration(ptrRecordsPollIntervalENVVar, 10*time.Minute)
+	if err != nil {
+		return hvConfig{}, err
+	}
+	cfg.ptrRecords.template = os.Getenv(ptrRecordsTemplateENVVar)
+	if strings.Contains(ptrRecordName(cfg.ptrRecords.template, "node", "cluster"), "{{") {
+		return hvConfig{}, fmt.Errorf("[readConfig] %s=%q contains an unknown placeholder: %w",
+			ptrRecordsTemplateENVVar, cfg.ptrRecords.template, errInvalidEnvVar)
+	}
+
+	return cfg, nil
+}
+
+// envDuration reads a duration like "5m" from the environment variable name.
+func envDuration(name string, defaultValue time.Duration) (time.Duration, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("[envDuration] %s=%q: %w", name, value, errInvalidEnvVar)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("[envDuration] %s=%q must be positive: %w", name, value, errInvalidEnvVar)
+	}
+	return d, nil
+}
+
+// envInt reads a non-negative integer from the environment variable name.
+func envInt(name string, defaultValue int) (int, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return defaultValue, nil
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("[envInt] %s=%q: %w", name, value, errInvalidEnvVar)
+	}
+	return i, nil
+}
+
+// envFloat reads a non-negative number from the environment variable name.
+func envFloat(name string, defaultValue float64) (float64, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return defaultValue, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("[envFloat] %s=%q: %w", name, value, errInvalidEnvVar)
+	}
+	return f, nil
+}
+
+// envBool reads a boolean like "true" or "false" from the environment variable name.
+func envBool(name string, defaultValue bool) (bool, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return defaultValue, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("[envBool] %s=%q: %w", name, value, errInvalidEnvVar)
+	}
+	return b, nil
+}
+
+// envCIDRs reads a comma separated list of CIDRs from the environment variable name.
+func envCIDRs(name string) ([]*net.IPNet, error) {
+	var cidrs []*net.IPNet
+	for _, value := range envStringSlice(name) {
+		_, cidr, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("[envCIDRs] %s contains %q: %w", name, value, errInvalidEnvVar)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
+
+// envString reads a string from the environment variable name.
+func envString(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// envStringSlice reads a comma separated list from the environment variable name.
+// Empty elements are dropped.
+func envStringSlice(name string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
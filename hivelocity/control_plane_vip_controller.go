@@ -0,0 +1,301 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/pkg/networktask"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// controlPlaneVIPMovedAtAnnotation on the node the VIP was moved to contains the time of the move.
+	controlPlaneVIPMovedAtAnnotation = "hivelocity.net/control-plane-vip-moved-at"
+
+	// nodeRoleControlPlaneLabel marks the control-plane nodes.
+	nodeRoleControlPlaneLabel = "node-role.kubernetes.io/control-plane"
+)
+
+// controlPlaneVIPController moves the IP assignment of the API server endpoint
+// to the node the CCM runs on. Like all optional controllers it only runs in
+// the leader of the CCM, so the VIP follows the leader election of the CCM.
+// The controller routes the assignment to the address of the node and
+// verifies the move via the network task. If the node dies, the CCM on
+// another node becomes leader and takes the VIP over.
+type controlPlaneVIPController struct {
+	client       client.Interface
+	kubeClient   kubernetes.Interface
+	nodeLister   corelisters.NodeLister
+	nodesSynced  cache.InformerSynced
+	recorder     record.EventRecorder
+	tracker      *networktask.Tracker
+	assignmentID int32
+	cooldown     time.Duration
+	pollInterval time.Duration
+	nodeName     string
+	now          func() time.Time
+
+	// mu guards the fields below, which are also used by the tracker.
+	mu sync.Mutex
+
+	// electedAt is the time the controller started in the leader. Zero after
+	// the first verified move, so that only failovers are observed.
+	electedAt time.Time
+
+	// nextHop is the next hop of the pending move.
+	nextHop string
+
+	// notControlPlane is true if the node was reported to be no control-plane node.
+	notControlPlane bool
+}
+
+func startControlPlaneVIPController(
+	ctx context.Context,
+	initContext app.ControllerInitContext,
+	_ genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error) {
+	if c.config.controlPlaneVIP.assignmentID == 0 {
+		klog.Warningf("%s is not set, not starting %s", controlPlaneVIPAssignmentIDENVVar, controlPlaneVIPControllerName)
+		return nil, false, nil
+	}
+	if c.config.nodeName == "" {
+		klog.Warningf("%s is empty, not starting %s", nodeNameENVVar, controlPlaneVIPControllerName)
+		return nil, false, nil
+	}
+
+	ctrl := newControlPlaneVIPController(
+		c.client,
+		completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName),
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		c.config.controlPlaneVIP,
+		c.config.nodeName,
+	)
+	go ctrl.Run(ctx)
+	return nil, true, nil
+}
+
+func newControlPlaneVIPController(
+	c client.Interface,
+	kubeClient kubernetes.Interface,
+	nodeInformer coreinformers.NodeInformer,
+	cfg controlPlaneVIPConfig,
+	nodeName string,
+) *controlPlaneVIPController {
+	registerMetrics()
+	ctrl := &controlPlaneVIPController{
+		client:       c,
+		kubeClient:   kubeClient,
+		nodeLister:   nodeInformer.Lister(),
+		nodesSynced:  nodeInformer.Informer().HasSynced,
+		recorder:     newEventRecorder(kubeClient, controlPlaneVIPControllerName),
+		assignmentID: int32(cfg.assignmentID),
+		cooldown:     cfg.cooldown,
+		pollInterval: cfg.pollInterval,
+		nodeName:     nodeName,
+		now:          time.Now,
+	}
+	ctrl.tracker = networktask.New(networktask.Options{
+		Controller: controlPlaneVIPControllerName,
+		Client:     c,
+		Recorder:   ctrl.recorder,
+		Patch:      nodeAnnotationPatcher(kubeClient),
+		OnFinished: ctrl.onTaskFinished,
+	})
+	return ctrl
+}
+
+// Run keeps the VIP on the node until ctx is done.
+func (c *controlPlaneVIPController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.Info("Starting Hivelocity control-plane VIP controller")
+	defer klog.Info("Shutting down Hivelocity control-plane VIP controller")
+
+	if !cache.WaitForNamedCacheSync(controlPlaneVIPControllerName, ctx.Done(), c.nodesSynced) {
+		return
+	}
+
+	c.mu.Lock()
+	c.electedAt = c.now()
+	c.mu.Unlock()
+
+	go c.tracker.Run(ctx)
+	wait.UntilWithContext(ctx, c.reconcile, c.pollInterval)
+}
+
+func (c *controlPlaneVIPController) reconcile(ctx context.Context) {
+	node, err := c.nodeLister.Get(c.nodeName)
+	if err != nil {
+		klog.Errorf("[controlPlaneVIPController] getting node %q failed: %v", c.nodeName, err)
+		return
+	}
+	if _, ok := node.Labels[nodeRoleControlPlaneLabel]; !ok {
+		if !c.notControlPlane {
+			c.recorder.Eventf(node, corev1.EventTypeWarning, "ControlPlaneVIPNotControlPlane",
+				"The leader of the CCM runs on node %q, which is not a control-plane node. The control-plane VIP is not moved",
+				c.nodeName)
+			klog.Warningf("[controlPlaneVIPController] node %q is not a control-plane node, not moving the VIP", c.nodeName)
+			c.notControlPlane = true
+		}
+		return
+	}
+	c.notControlPlane = false
+
+	if err := c.sync(ctx, node); err != nil {
+		klog.Errorf("[controlPlaneVIPController] ip assignment %d: %v", c.assignmentID, err)
+	}
+}
+
+// sync routes the assignment to the node, unless a move is pending or the
+// last move was within the cooldown.
+func (c *controlPlaneVIPController) sync(ctx context.Context, node *corev1.Node) error {
+	c.tracker.Resume(node)
+	if c.tracker.Pending(node) {
+		return nil
+	}
+
+	assignment, err := c.client.GetIPAssignment(ctx, c.assignmentID)
+	if err != nil {
+		return fmt.Errorf("[sync] GetIPAssignment() failed: %w", err)
+	}
+	_, subnet, err := net.ParseCIDR(assignment.Subnet)
+	if err != nil {
+		return fmt.Errorf("[sync] ParseCIDR() failed: %w", err)
+	}
+	nextHop, err := nodeAddress(node, subnet.IP.To4() == nil)
+	if err != nil {
+		return fmt.Errorf("[sync] nodeAddress() failed: %w", err)
+	}
+	if assignment.NextHopIp == nextHop {
+		// Nothing to fail over.
+		c.mu.Lock()
+		c.electedAt = time.Time{}
+		c.mu.Unlock()
+		return nil
+	}
+
+	movedAt, err := c.lastMove()
+	if err != nil {
+		return fmt.Errorf("[sync] lastMove() failed: %w", err)
+	}
+	if !movedAt.IsZero() {
+		if remaining := c.cooldown - c.now().Sub(movedAt); remaining > 0 {
+			klog.Infof("Not moving the control-plane VIP %s to node %q, the cooldown ends in %s",
+				assignment.Subnet, node.Name, remaining.Round(time.Second))
+			return nil
+		}
+	}
+
+	task, err := c.client.SetIPAssignmentNextHop(ctx, c.assignmentID, nextHop)
+	if err != nil {
+		controlPlaneVIPMovesTotal.WithLabelValues("failed").Inc()
+		return fmt.Errorf("[sync] SetIPAssignmentNextHop() failed: %w", err)
+	}
+	klog.Infof("Moving the control-plane VIP %s from %q to node %q (%s). Network task %q",
+		assignment.Subnet, assignment.NextHopIp, node.Name, nextHop, task.TaskId)
+	c.mu.Lock()
+	c.nextHop = nextHop
+	c.mu.Unlock()
+
+	if err := c.tracker.Track(ctx, task.TaskId, node); err != nil {
+		return fmt.Errorf("[sync] Track() failed: %w", err)
+	}
+	return nil
+}
+
+// lastMove returns the time of the last move, which is recorded on the node
+// the VIP was moved to. Zero if there was none.
+func (c *controlPlaneVIPController) lastMove() (time.Time, error) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("[lastMove] listing nodes failed: %w", err)
+	}
+	var last time.Time
+	for _, node := range nodes {
+		movedAt, err := time.Parse(time.RFC3339, node.Annotations[controlPlaneVIPMovedAtAnnotation])
+		if err == nil && movedAt.After(last) {
+			last = movedAt
+		}
+	}
+	return last, nil
+}
+
+// onTaskFinished verifies that the assignment is routed to the node. Only a
+// verified move is recorded on the node, so that a failed move does not start
+// the cooldown.
+func (c *controlPlaneVIPController) onTaskFinished(ctx context.Context, result networktask.Result) {
+	c.mu.Lock()
+	nextHop, electedAt := c.nextHop, c.electedAt
+	c.mu.Unlock()
+
+	verified := false
+	if !result.Failed {
+		assignment, err := c.client.GetIPAssignment(ctx, c.assignmentID)
+		if err != nil {
+			klog.Errorf("[controlPlaneVIPController] verifying the move failed: %v", err)
+		} else {
+			// After a restart nextHop is unknown, the move of the resumed task
+			// is accepted if the VIP is routed somewhere.
+			verified = assignment.NextHopIp != "" && (nextHop == "" || assignment.NextHopIp == nextHop)
+		}
+	}
+
+	if !verified {
+		controlPlaneVIPMovesTotal.WithLabelValues("failed").Inc()
+		for _, obj := range result.Objects {
+			c.recorder.Eventf(obj, corev1.EventTypeWarning, "ControlPlaneVIPMoveFailed",
+				"Moving the control-plane VIP to the node failed. Network task %q", result.TaskID)
+		}
+		return
+	}
+
+	controlPlaneVIPMovesTotal.WithLabelValues("success").Inc()
+	if !electedAt.IsZero() {
+		controlPlaneVIPFailoverDuration.Observe(c.now().Sub(electedAt).Seconds())
+	}
+	c.mu.Lock()
+	c.electedAt = time.Time{}
+	c.mu.Unlock()
+	movedAt := c.now().UTC().Format(time.RFC3339)
+	for _, obj := range result.Objects {
+		if err := nodeAnnotationPatcher(c.kubeClient)(ctx, obj, controlPlaneVIPMovedAtAnnotation, movedAt); err != nil {
+			klog.Errorf("[controlPlaneVIPController] recording the move failed: %v", err)
+		}
+		c.recorder.Event(obj, corev1.EventTypeNormal, "ControlPlaneVIPMoved", "The control-plane VIP was moved to the node")
+	}
+}
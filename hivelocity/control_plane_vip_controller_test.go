@@ -0,0 +1,206 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/pkg/networktask"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+var controlPlaneVIPTestNow = time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newControlPlaneVIPTestController(t *testing.T, m *mocks.Interface, movedAt string) *controlPlaneVIPController {
+	t.Helper()
+	node := newLoadBalancerTestNode("cp-1", "198.51.100.1", true)
+	node.Labels = map[string]string{nodeRoleControlPlaneLabel: ""}
+	// The VIP was moved to the other node before.
+	other := newLoadBalancerTestNode("cp-2", "198.51.100.2", true)
+	if movedAt != "" {
+		other.Annotations = map[string]string{controlPlaneVIPMovedAtAnnotation: movedAt}
+	}
+	kubeClient := fake.NewSimpleClientset(node, other)
+
+	c := &controlPlaneVIPController{
+		client:       m,
+		kubeClient:   kubeClient,
+		nodeLister:   newTestNodeLister(t, node, other),
+		recorder:     record.NewFakeRecorder(10),
+		assignmentID: 9,
+		cooldown:     time.Minute,
+		nodeName:     "cp-1",
+		now:          func() time.Time { return controlPlaneVIPTestNow },
+		electedAt:    controlPlaneVIPTestNow.Add(-20 * time.Second),
+	}
+	c.tracker = networktask.New(networktask.Options{
+		Controller: controlPlaneVIPControllerName,
+		Client:     m,
+		Recorder:   c.recorder,
+		Patch:      nodeAnnotationPatcher(kubeClient),
+		OnFinished: c.onTaskFinished,
+	})
+	return c
+}
+
+func Test_controlPlaneVIPController_sync(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		nextHop  string
+		movedAt  string
+		wantMove bool
+	}{
+		{
+			name:     "moves the VIP to the leader",
+			nextHop:  "198.51.100.2",
+			wantMove: true,
+		},
+		{
+			name:    "VIP is on the leader",
+			nextHop: "198.51.100.1",
+		},
+		{
+			name:    "within the cooldown",
+			nextHop: "198.51.100.2",
+			movedAt: controlPlaneVIPTestNow.Add(-30 * time.Second).Format(time.RFC3339),
+		},
+		{
+			name:     "after the cooldown",
+			nextHop:  "198.51.100.2",
+			movedAt:  controlPlaneVIPTestNow.Add(-2 * time.Minute).Format(time.RFC3339),
+			wantMove: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := mocks.NewInterface(t)
+			m.On("GetIPAssignment", mock.Anything, int32(9)).
+				Return(&hv.IpAssignment{AssignmentId: 9, Subnet: "203.0.113.10/32", NextHopIp: tt.nextHop}, nil)
+			if tt.wantMove {
+				m.On("SetIPAssignmentNextHop", mock.Anything, int32(9), "198.51.100.1").
+					Return(&hv.NetworkTaskDump{TaskId: "task-1"}, nil)
+			}
+			c := newControlPlaneVIPTestController(t, m, tt.movedAt)
+
+			c.reconcile(context.Background())
+
+			node, err := c.kubeClient.CoreV1().Nodes().Get(context.Background(), "cp-1", metav1.GetOptions{})
+			require.NoError(t, err)
+			if tt.wantMove {
+				require.Equal(t, "task-1", node.Annotations[networktask.AnnotationKey])
+				// The move is recorded once it is verified.
+				require.Empty(t, node.Annotations[controlPlaneVIPMovedAtAnnotation])
+			} else {
+				require.Empty(t, node.Annotations[networktask.AnnotationKey])
+			}
+		})
+	}
+}
+
+func Test_controlPlaneVIPController_verify(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		taskResult string
+		nextHop    string
+		wantEvent  string
+		wantMoved  bool
+	}{
+		{
+			name:       "moved",
+			taskResult: networktask.ResultSuccess,
+			nextHop:    "198.51.100.1",
+			wantEvent:  "Normal ControlPlaneVIPMoved",
+			wantMoved:  true,
+		},
+		{
+			name:       "task failed",
+			taskResult: networktask.ResultFailed,
+			wantEvent:  "Warning ControlPlaneVIPMoveFailed",
+		},
+		{
+			name:       "routed elsewhere",
+			taskResult: networktask.ResultSuccess,
+			nextHop:    "198.51.100.2",
+			wantEvent:  "Warning ControlPlaneVIPMoveFailed",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := mocks.NewInterface(t)
+			m.On("GetNetworkTask", mock.Anything, "task-1").
+				Return(&hv.NetworkTaskDump{TaskId: "task-1", Result: tt.taskResult}, nil)
+			if tt.taskResult == networktask.ResultSuccess {
+				m.On("GetIPAssignment", mock.Anything, int32(9)).
+					Return(&hv.IpAssignment{AssignmentId: 9, Subnet: "203.0.113.10/32", NextHopIp: tt.nextHop}, nil)
+			}
+			c := newControlPlaneVIPTestController(t, m, "")
+			c.nextHop = "198.51.100.1"
+			node, err := c.nodeLister.Get("cp-1")
+			require.NoError(t, err)
+			node = node.DeepCopy()
+			node.Annotations = map[string]string{networktask.AnnotationKey: "task-1"}
+			c.tracker.Resume(node)
+
+			c.tracker.Poll(context.Background())
+
+			events := c.recorder.(*record.FakeRecorder).Events
+			require.Contains(t, <-events, "NetworkTask")
+			require.Contains(t, <-events, tt.wantEvent)
+
+			node, err = c.kubeClient.CoreV1().Nodes().Get(context.Background(), "cp-1", metav1.GetOptions{})
+			require.NoError(t, err)
+			if tt.wantMoved {
+				require.Equal(t, controlPlaneVIPTestNow.Format(time.RFC3339),
+					node.Annotations[controlPlaneVIPMovedAtAnnotation])
+			} else {
+				require.Empty(t, node.Annotations[controlPlaneVIPMovedAtAnnotation])
+			}
+		})
+	}
+}
+
+func Test_controlPlaneVIPController_reconcile_notControlPlane(t *testing.T) {
+	t.Parallel()
+	// The VIP is not touched, the mock has no expectations.
+	m := mocks.NewInterface(t)
+	c := newControlPlaneVIPTestController(t, m, "")
+	worker := newNode("hivelocity://2", "worker-1")
+	c.nodeLister = newTestNodeLister(t, worker)
+	c.nodeName = "worker-1"
+
+	c.reconcile(context.Background())
+	events := c.recorder.(*record.FakeRecorder).Events
+	require.Contains(t, <-events, "ControlPlaneVIPNotControlPlane")
+
+	// The node is only reported once.
+	c.reconcile(context.Background())
+	require.Empty(t, events)
+}
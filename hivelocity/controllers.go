@@ -0,0 +1,385 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/pkg/networktask"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	nodeutil "k8s.io/component-helpers/node/util"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+)
+
+// Names of the Hivelocity specific controllers. They can be enabled
+// via the --controllers flag, for example --controllers=*,hivelocity-ipmi.
+const (
+	ipmiControllerName              = "hivelocity-ipmi"
+	remediationControllerName       = "hivelocity-remediation"
+	rollingReloadControllerName     = "hivelocity-rolling-reload"
+	labelSyncControllerName         = "hivelocity-label-sync"
+	nodeTagsControllerName          = "hivelocity-node-tags"
+	hardwareLabelsControllerName    = "hivelocity-hardware-labels"
+	topologyControllerName          = "hivelocity-topology"
+	deviceAnnotationsControllerName = "hivelocity-device-annotations"
+	costControllerName              = "hivelocity-cost"
+	bandwidthControllerName         = "hivelocity-bandwidth"
+	switchPortsControllerName       = "hivelocity-switch-ports"
+	nodeIPAMControllerName          = "hivelocity-node-ipam"
+	privateVLANControllerName       = "hivelocity-private-vlan"
+	metalLBControllerName           = "hivelocity-metallb"
+	controlPlaneVIPControllerName   = "hivelocity-control-plane-vip"
+	ptrRecordsControllerName        = "hivelocity-ptr-records"
+)
+
+// ControllersDisabledByDefault contains the Hivelocity specific controllers
+// which need to be enabled explicitly.
+var ControllersDisabledByDefault = sets.NewString(
+	ipmiControllerName,
+	remediationControllerName,
+	rollingReloadControllerName,
+	labelSyncControllerName,
+	nodeTagsControllerName,
+	hardwareLabelsControllerName,
+	topologyControllerName,
+	deviceAnnotationsControllerName,
+	costControllerName,
+	bandwidthControllerName,
+	switchPortsControllerName,
+	nodeIPAMControllerName,
+	privateVLANControllerName,
+	metalLBControllerName,
+	controlPlaneVIPControllerName,
+	ptrRecordsControllerName,
+)
+
+var errUnexpectedCloud = errors.New("cloud provider is not the Hivelocity cloud provider")
+
+// startFunc starts a Hivelocity specific controller. It is like
+// app.InitFunc, but gets the Hivelocity cloud instead of the generic interface.
+type startFunc func(
+	ctx context.Context,
+	initContext app.ControllerInitContext,
+	controllerContext genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error)
+
+// ControllerInitFuncConstructors returns the Hivelocity specific controllers.
+// They get added to the default controllers of the cloud controller manager.
+func ControllerInitFuncConstructors() map[string]app.ControllerInitFuncConstructor {
+	return map[string]app.ControllerInitFuncConstructor{
+		ipmiControllerName: {
+			InitContext: app.ControllerInitContext{ClientName: "hivelocity-ipmi-controller"},
+			Constructor: newInitFuncConstructor(startIPMIController),
+		},
+		remediationControllerName: {
+			InitContext: app.ControllerInitContext{ClientName: "hivelocity-remediation-controller"},
+			Constructor: newInitFuncConstructor(startRemediationController),
+		},
+		rollingReloadControllerName: {
+			InitContext: app.ControllerInitContext{ClientName: "hivelocity-rolling-reload-controller"},
+			Constructor: newInitFuncConstructor(startRollingReloadController),
+		},
+		labelSyncControllerName: {
+			InitContext: app.ControllerInitContext{ClientName: "hivelocity-label-sync-controller"},
+			Constructor: newInitFuncConstructor(startLabelSyncController),
+		},
+		nodeTagsControllerName: {
+			InitContext: app.ControllerInitContext{ClientName: "hivelocity-node-tags-controller"},
+			Constructor: newInitFuncConstructor(startNodeTagsController),
+		},
+		hardwareLabelsControllerName: {
+			InitContext: app.ControllerInitContext{ClientName: "hivelocity-hardware-labels-controller"},
+			Constructor: newInitFuncConstructor(startHardwareLabelsController),
+		},
+		topologyControllerName: {
+			InitContext: app.ControllerInitContext{ClientName: "hivelocity-topology-controller"},
+			Constructor: newInitFuncConstructor(startTopologyController),
+		},
+		deviceAnnotationsControllerName: {
+			InitContext: app.ControllerInitContext{ClientName: "hivelocity-device-annotations-controller"},
+			Constructor: newInitFuncConstructor(startDeviceAnnotationsController),
+		},
+		costControllerName: {
+			InitContext: app.ControllerInitContext{ClientName: "hivelocity-cost-controller"},
+			Constructor: newInitFuncConstructor(startCostController),
+		},
+		bandwidthControllerName: {
+			InitContext: app.ControllerInitContext{ClientName: "hivelocity-bandwidth-controller"},
+			Constructor: newInitFuncConstructor(startBandwidthController),
+		},
+		switchPortsControllerName: {
+			InitContext: app.ControllerInitContext{ClientName: "hivelocity-switch-ports-controller"},
+			Constructor: newInitFuncConstructor(startSwitchPortsController),
+		},
+		nodeIPAMControllerName: {
+			InitContext: app.ControllerInitContext{ClientName: "hivelocity-node-ipam-controller"},
+			Constructor: newInitFuncConstructor(startNodeIPAMController),
+		},
+		privateVLANControllerName: {
+			InitContext: app.ControllerInitContext{ClientName: "hivelocity-private-vlan-controller"},
+			Constructor: newInitFuncConstructor(startPrivateVLANController),
+		},
+		metalLBControllerName: {
+			InitContext: app.ControllerInitContext{ClientName: "hivelocity-metallb-controller"},
+			Constructor: newInitFuncConstructor(startMetalLBController),
+		},
+		controlPlaneVIPControllerName: {
+			InitContext: app.ControllerInitContext{ClientName: "hivelocity-control-plane-vip-controller"},
+			Constructor: newInitFuncConstructor(startControlPlaneVIPController),
+		},
+		ptrRecordsControllerName: {
+			InitContext: app.ControllerInitContext{ClientName: "hivelocity-ptr-records-controller"},
+			Constructor: newInitFuncConstructor(startPTRRecordsController),
+		},
+	}
+}
+
+// newInitFuncConstructor adapts a startFunc to app.InitFuncConstructor.
+func newInitFuncConstructor(start startFunc) app.InitFuncConstructor {
+	return func(
+		initContext app.ControllerInitContext,
+		completedConfig *cloudcontrollerconfig.CompletedConfig,
+		cloudProvider cloudprovider.Interface,
+	) app.InitFunc {
+		return func(
+			ctx context.Context,
+			controllerContext genericcontrollermanager.ControllerContext,
+		) (controller.Interface, bool, error) {
+			c, ok := cloudProvider.(*cloud)
+			if !ok {
+				return nil, false, fmt.Errorf("[newInitFuncConstructor] %T: %w", cloudProvider, errUnexpectedCloud)
+			}
+			return start(ctx, initContext, controllerContext, completedConfig, c)
+		}
+	}
+}
+
+// newEventRecorder creates an EventRecorder which writes the events of component to the API server.
+func newEventRecorder(kubeClient kubernetes.Interface, component string) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartStructuredLogging(0)
+	broadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	return broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: component})
+}
+
+// setNodeCondition sets the condition on the node. Nothing gets updated if the
+// node already has the condition with the same status, reason and message.
+// Returns true if the status of the condition has changed.
+func setNodeCondition(kubeClient kubernetes.Interface, node *corev1.Node, condition corev1.NodeCondition) (bool, error) {
+	now := metav1.Now()
+	condition.LastHeartbeatTime = now
+	condition.LastTransitionTime = now
+
+	_, current := nodeutil.GetNodeCondition(&node.Status, condition.Type)
+	if current != nil {
+		if current.Status == condition.Status &&
+			current.Reason == condition.Reason &&
+			current.Message == condition.Message {
+			return false, nil
+		}
+		if current.Status == condition.Status {
+			condition.LastTransitionTime = current.LastTransitionTime
+		}
+	}
+
+	if err := nodeutil.SetNodeCondition(kubeClient, types.NodeName(node.Name), condition); err != nil {
+		return false, fmt.Errorf(
+			"[setNodeCondition] SetNodeCondition() failed. node %q, condition %q: %w",
+			node.Name,
+			condition.Type,
+			err,
+		)
+	}
+	return current == nil || current.Status != condition.Status, nil
+}
+
+// updateNodeCondition sets the condition on the node. A Warning event gets
+// emitted when the condition becomes true, a Normal event when it gets
+// resolved. The reason of the condition is used as reason of the event.
+func updateNodeCondition(
+	kubeClient kubernetes.Interface,
+	recorder record.EventRecorder,
+	node *corev1.Node,
+	condition corev1.NodeCondition,
+) error {
+	_, previous := nodeutil.GetNodeCondition(&node.Status, condition.Type)
+	changed, err := setNodeCondition(kubeClient, node, condition)
+	if err != nil {
+		return fmt.Errorf("[updateNodeCondition] %w", err)
+	}
+	if !changed {
+		return nil
+	}
+
+	switch {
+	case condition.Status == corev1.ConditionTrue:
+		recorder.Event(node, corev1.EventTypeWarning, condition.Reason, condition.Message)
+	case previous != nil && previous.Status == corev1.ConditionTrue:
+		recorder.Event(node, corev1.EventTypeNormal, condition.Reason, condition.Message)
+	}
+	return nil
+}
+
+// patchNodeLabels sets the desired labels on the node and removes the managed
+// labels which are not desired. Other labels are not touched. Nothing gets
+// patched if the node already has the desired labels.
+func patchNodeLabels(
+	ctx context.Context,
+	kubeClient kubernetes.Interface,
+	node *corev1.Node,
+	managed []string,
+	desired map[string]string,
+) error {
+	return patchNodeMetadata(ctx, kubeClient, node, "labels", node.Labels, managed, desired)
+}
+
+// patchNodeAnnotations is like patchNodeLabels, but for annotations.
+func patchNodeAnnotations(
+	ctx context.Context,
+	kubeClient kubernetes.Interface,
+	node *corev1.Node,
+	managed []string,
+	desired map[string]string,
+) error {
+	return patchNodeMetadata(ctx, kubeClient, node, "annotations", node.Annotations, managed, desired)
+}
+
+func patchNodeMetadata(
+	ctx context.Context,
+	kubeClient kubernetes.Interface,
+	node *corev1.Node,
+	field string,
+	current map[string]string,
+	managed []string,
+	desired map[string]string,
+) error {
+	// A nil value removes the key in a merge patch.
+	changes := make(map[string]interface{})
+	for _, key := range managed {
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		if _, ok := current[key]; ok {
+			changes[key] = nil
+		}
+	}
+	for key, value := range desired {
+		if currentValue, ok := current[key]; !ok || currentValue != value {
+			changes[key] = value
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{field: changes},
+	})
+	if err != nil {
+		return fmt.Errorf("[patchNodeMetadata] json.Marshal() failed: %w", err)
+	}
+	if _, err := kubeClient.CoreV1().Nodes().Patch(
+		ctx, node.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("[patchNodeMetadata] patching %s of node %q failed: %w", field, node.Name, err)
+	}
+	return nil
+}
+
+// isNodeInitialized returns true if the cloud node controller has set the
+// provider ID of the node. Before, the device and the addresses of the node
+// are not known, so the controllers skip the node.
+func isNodeInitialized(node *corev1.Node) bool {
+	return node.Spec.ProviderID != ""
+}
+
+// nodeInFacility returns false if both the zone of the node and the facility
+// code are known and differ.
+func nodeInFacility(node *corev1.Node, facilityCode string) bool {
+	zone := node.Labels[corev1.LabelTopologyZone]
+	return zone == "" || facilityCode == "" || strings.EqualFold(zone, facilityCode)
+}
+
+// nodeAnnotationPatcher returns a networktask.PatchFunc for nodes. The patch
+// is sent unconditionally, since the node of the tracker may be outdated.
+// Deleted nodes are ignored.
+func nodeAnnotationPatcher(kubeClient kubernetes.Interface) networktask.PatchFunc {
+	return func(ctx context.Context, obj networktask.Object, key, value string) error {
+		patch, err := annotationMergePatch(key, value)
+		if err != nil {
+			return err
+		}
+		_, err = kubeClient.CoreV1().Nodes().Patch(ctx, obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("[nodeAnnotationPatcher] patching annotation %q of node %q failed: %w",
+				key, obj.GetName(), err)
+		}
+		return nil
+	}
+}
+
+// serviceAnnotationPatcher is like nodeAnnotationPatcher, but for services.
+func serviceAnnotationPatcher(kubeClient kubernetes.Interface) networktask.PatchFunc {
+	return func(ctx context.Context, obj networktask.Object, key, value string) error {
+		patch, err := annotationMergePatch(key, value)
+		if err != nil {
+			return err
+		}
+		_, err = kubeClient.CoreV1().Services(obj.GetNamespace()).Patch(
+			ctx, obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("[serviceAnnotationPatcher] patching annotation %q of service %s/%s failed: %w",
+				key, obj.GetNamespace(), obj.GetName(), err)
+		}
+		return nil
+	}
+}
+
+// annotationMergePatch returns a merge patch which sets the annotation key to
+// value. An empty value removes the annotation.
+func annotationMergePatch(key, value string) ([]byte, error) {
+	var annotationValue interface{}
+	if value != "" {
+		annotationValue = value
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{key: annotationValue},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[annotationMergePatch] json.Marshal() failed: %w", err)
+	}
+	return patch, nil
+}
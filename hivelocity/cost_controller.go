@@ -0,0 +1,164 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+var errUnknownBillingPeriod = errors.New("unknown billing period")
+
+// costController exports the costs of the devices of the nodes as metrics.
+type costController struct {
+	client       client.Interface
+	nodeLister   corelisters.NodeLister
+	nodesSynced  cache.InformerSynced
+	pollInterval time.Duration
+	// exportedNodes are the nodes whose series were exported by the last poll.
+	exportedNodes map[string]bool
+}
+
+func startCostController(
+	ctx context.Context,
+	_ app.ControllerInitContext,
+	_ genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error) {
+	ctrl := newCostController(
+		c.client,
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		c.config.cost.pollInterval,
+	)
+	go ctrl.Run(ctx)
+	return nil, true, nil
+}
+
+func newCostController(
+	c client.Interface,
+	nodeInformer coreinformers.NodeInformer,
+	pollInterval time.Duration,
+) *costController {
+	registerMetrics()
+	return &costController{
+		client:       c,
+		nodeLister:   nodeInformer.Lister(),
+		nodesSynced:  nodeInformer.Informer().HasSynced,
+		pollInterval: pollInterval,
+	}
+}
+
+// Run exports the costs until ctx is done.
+func (c *costController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.Info("Starting Hivelocity cost controller")
+	defer klog.Info("Shutting down Hivelocity cost controller")
+
+	if !cache.WaitForNamedCacheSync(costControllerName, ctx.Done(), c.nodesSynced) {
+		return
+	}
+
+	wait.UntilWithContext(ctx, c.reconcileNodes, c.pollInterval)
+}
+
+func (c *costController) reconcileNodes(ctx context.Context) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("[costController] listing nodes failed: %v", err)
+		return
+	}
+
+	for _, node := range nodes {
+		if err := c.reconcileNode(ctx, node); err != nil {
+			klog.Errorf("[costController] node %q: %v", node.Name, err)
+		}
+	}
+
+	c.exportedNodes = deleteSeriesOfDeletedNodes(c.exportedNodes, nodes, nodeMonthlyCost, nodeMonthlyDiscount)
+}
+
+func (c *costController) reconcileNode(ctx context.Context, node *corev1.Node) error {
+	if !isNodeInitialized(node) {
+		return nil
+	}
+
+	deviceID, err := getHivelocityDeviceIDFromNode(node)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] getHivelocityDeviceIDFromNode() failed: %w", err)
+	}
+	device, err := c.client.GetBareMetalDevice(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] GetBareMetalDevice() failed. deviceID %d: %w", deviceID, err)
+	}
+	service, err := c.client.GetService(ctx, device.ServiceId)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] GetService() failed. deviceID %d: %w", deviceID, err)
+	}
+
+	factor, err := monthlyFactor(service.Period)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] serviceID %d: %w", service.ServiceId, err)
+	}
+
+	nodeMonthlyCost.WithLabelValues(node.Name, device.ProductName, device.LocationName).
+		Set(float64(service.BilledPricePerPeriod) * factor)
+	nodeMonthlyDiscount.WithLabelValues(node.Name, device.ProductName, device.LocationName).
+		Set(float64(service.ServiceDiscountPerPeriod) * factor)
+	return nil
+}
+
+// monthlyFactor returns the factor which converts a price per billing period
+// into a price per month. An hourly billed month has 730 hours (8760 / 12).
+func monthlyFactor(period string) (float64, error) {
+	switch strings.ToLower(period) {
+	case "hourly":
+		return 730, nil
+	case "monthly":
+		return 1, nil
+	case "quarterly":
+		return 1.0 / 3, nil
+	case "semi-annually":
+		return 1.0 / 6, nil
+	case "annually":
+		return 1.0 / 12, nil
+	case "biennial":
+		return 1.0 / 24, nil
+	case "triennial":
+		return 1.0 / 36, nil
+	default:
+		return 0, fmt.Errorf("[monthlyFactor] %q: %w", period, errUnknownBillingPeriod)
+	}
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func Test_monthlyFactor(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		period string
+		want   float64
+		err    error
+	}{
+		{period: "hourly", want: 730},
+		{period: "Monthly", want: 1},
+		{period: "quarterly", want: 1.0 / 3},
+		{period: "annually", want: 1.0 / 12},
+		{period: "triennial", want: 1.0 / 36},
+		{period: "weekly", err: errUnknownBillingPeriod},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.period, func(t *testing.T) {
+			t.Parallel()
+			got, err := monthlyFactor(tt.period)
+			require.ErrorIs(t, err, tt.err)
+			require.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
+
+func Test_cost_reconcileNode(t *testing.T) {
+	t.Parallel()
+	registerMetrics()
+	m := mocks.NewInterface(t)
+	m.On("GetBareMetalDevice", mock.Anything, int32(dummyDeviceID)).Return(&hv.BareMetalDevice{
+		DeviceId:     dummyDeviceID,
+		ServiceId:    7,
+		ProductName:  "Tiny",
+		LocationName: "LAX1",
+	}, nil)
+	m.On("GetService", mock.Anything, int32(7)).Return(&hv.Service{
+		ServiceId:                7,
+		Period:                   "annually",
+		BilledPricePerPeriod:     1200,
+		ServiceDiscountPerPeriod: 120,
+	}, nil)
+
+	c := &costController{client: m}
+	node := newNode("hivelocity://12345", "cost-node")
+	require.NoError(t, c.reconcileNode(context.Background(), node))
+
+	cost, err := testutil.GetGaugeMetricValue(nodeMonthlyCost.WithLabelValues("cost-node", "Tiny", "LAX1"))
+	require.NoError(t, err)
+	require.InDelta(t, 100, cost, 1e-9)
+
+	discount, err := testutil.GetGaugeMetricValue(nodeMonthlyDiscount.WithLabelValues("cost-node", "Tiny", "LAX1"))
+	require.NoError(t, err)
+	require.InDelta(t, 10, discount, 1e-9)
+}
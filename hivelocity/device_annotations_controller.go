@@ -0,0 +1,192 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+// Annotations set by the device annotations controller.
+const (
+	annotationDeviceID     = "hivelocity.net/device-id"
+	annotationServiceID    = "hivelocity.net/service-id"
+	annotationOrderID      = "hivelocity.net/order-id"
+	annotationProductID    = "hivelocity.net/product-id"
+	annotationProductName  = "hivelocity.net/product-name"
+	annotationOSName       = "hivelocity.net/os-name"
+	annotationPeriod       = "hivelocity.net/period"
+	annotationLocationName = "hivelocity.net/location-name"
+)
+
+// deviceAnnotations contains all annotations which are managed by the device annotations controller.
+var deviceAnnotations = []string{
+	annotationDeviceID,
+	annotationServiceID,
+	annotationOrderID,
+	annotationProductID,
+	annotationProductName,
+	annotationOSName,
+	annotationPeriod,
+	annotationLocationName,
+}
+
+// deviceAnnotationsController annotates the nodes with the identity of their
+// device, like device ID, service ID and order ID.
+type deviceAnnotationsController struct {
+	client       client.Interface
+	kubeClient   kubernetes.Interface
+	nodeLister   corelisters.NodeLister
+	nodesSynced  cache.InformerSynced
+	pollInterval time.Duration
+}
+
+func startDeviceAnnotationsController(
+	ctx context.Context,
+	initContext app.ControllerInitContext,
+	_ genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error) {
+	ctrl := newDeviceAnnotationsController(
+		c.client,
+		completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName),
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		c.config.deviceAnnotations.pollInterval,
+	)
+	go ctrl.Run(ctx)
+	return nil, true, nil
+}
+
+func newDeviceAnnotationsController(
+	c client.Interface,
+	kubeClient kubernetes.Interface,
+	nodeInformer coreinformers.NodeInformer,
+	pollInterval time.Duration,
+) *deviceAnnotationsController {
+	return &deviceAnnotationsController{
+		client:       c,
+		kubeClient:   kubeClient,
+		nodeLister:   nodeInformer.Lister(),
+		nodesSynced:  nodeInformer.Informer().HasSynced,
+		pollInterval: pollInterval,
+	}
+}
+
+// Run annotates the nodes until ctx is done.
+func (c *deviceAnnotationsController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.Info("Starting Hivelocity device annotations controller")
+	defer klog.Info("Shutting down Hivelocity device annotations controller")
+
+	if !cache.WaitForNamedCacheSync(deviceAnnotationsControllerName, ctx.Done(), c.nodesSynced) {
+		return
+	}
+
+	wait.UntilWithContext(ctx, c.reconcileNodes, c.pollInterval)
+}
+
+func (c *deviceAnnotationsController) reconcileNodes(ctx context.Context) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("[deviceAnnotationsController] listing nodes failed: %v", err)
+		return
+	}
+
+	devices, err := c.client.ListDevices(ctx)
+	if err != nil {
+		klog.Errorf("[deviceAnnotationsController] ListDevices() failed: %v", err)
+		return
+	}
+	devicesByID := make(map[int32]*hv.BareMetalDevice, len(devices))
+	for i := range devices {
+		devicesByID[devices[i].DeviceId] = &devices[i]
+	}
+
+	for _, node := range nodes {
+		if err := c.reconcileNode(ctx, node, devicesByID); err != nil {
+			klog.Errorf("[deviceAnnotationsController] node %q: %v", node.Name, err)
+		}
+	}
+}
+
+func (c *deviceAnnotationsController) reconcileNode(
+	ctx context.Context,
+	node *corev1.Node,
+	devicesByID map[int32]*hv.BareMetalDevice,
+) error {
+	if !isNodeInitialized(node) {
+		return nil
+	}
+
+	deviceID, err := getHivelocityDeviceIDFromNode(node)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] getHivelocityDeviceIDFromNode() failed: %w", err)
+	}
+
+	// The annotations of a device which does not exist anymore get removed.
+	var desired map[string]string
+	if device, ok := devicesByID[deviceID]; ok {
+		desired = deviceAnnotationsFromDevice(device)
+	}
+	return patchNodeAnnotations(ctx, c.kubeClient, node, deviceAnnotations, desired)
+}
+
+// deviceAnnotationsFromDevice returns the annotations of the device. Fields
+// which are not set are omitted, so that stale values get removed.
+func deviceAnnotationsFromDevice(device *hv.BareMetalDevice) map[string]string {
+	result := make(map[string]string)
+	setID := func(key string, id int32) {
+		if id != 0 {
+			result[key] = strconv.Itoa(int(id))
+		}
+	}
+	setString := func(key, value string) {
+		if value != "" {
+			result[key] = value
+		}
+	}
+
+	setID(annotationDeviceID, device.DeviceId)
+	setID(annotationServiceID, device.ServiceId)
+	setID(annotationOrderID, device.OrderId)
+	setID(annotationProductID, device.ProductId)
+	setString(annotationProductName, device.ProductName)
+	setString(annotationOSName, device.OsName)
+	setString(annotationPeriod, device.Period)
+	setString(annotationLocationName, device.LocationName)
+	return result
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_deviceAnnotations_reconcileNode(t *testing.T) {
+	t.Parallel()
+	node := newNode("hivelocity://12345", nodeName)
+	node.Annotations = map[string]string{
+		annotationOSName: "Ubuntu 20.x",
+		annotationPeriod: "monthly",
+		"other":          "x",
+	}
+	kubeClient := fake.NewSimpleClientset(node)
+	c := &deviceAnnotationsController{kubeClient: kubeClient}
+
+	devicesByID := map[int32]*hv.BareMetalDevice{
+		dummyDeviceID: {
+			DeviceId:     dummyDeviceID,
+			ServiceId:    2,
+			OrderId:      3,
+			ProductId:    4,
+			ProductName:  "Tiny",
+			OsName:       "Ubuntu 22.x",
+			LocationName: "LAX1",
+		},
+	}
+	require.NoError(t, c.reconcileNode(context.Background(), node, devicesByID))
+
+	updatedNode, err := kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		annotationDeviceID:     "12345",
+		annotationServiceID:    "2",
+		annotationOrderID:      "3",
+		annotationProductID:    "4",
+		annotationProductName:  "Tiny",
+		annotationOSName:       "Ubuntu 22.x",
+		annotationLocationName: "LAX1",
+		"other":                "x",
+	}, updatedNode.Annotations)
+
+	// All annotations get removed if the device does not exist anymore.
+	require.NoError(t, c.reconcileNode(context.Background(), updatedNode, nil))
+	updatedNode, err = kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"other": "x"}, updatedNode.Annotations)
+}
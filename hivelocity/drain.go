@@ -0,0 +1,103 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// setNodeUnschedulable cordons or uncordons the node.
+func setNodeUnschedulable(ctx context.Context, kubeClient kubernetes.Interface, nodeName string, unschedulable bool) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"unschedulable": unschedulable,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("[setNodeUnschedulable] json.Marshal() failed: %w", err)
+	}
+	if _, err := kubeClient.CoreV1().Nodes().Patch(
+		ctx, nodeName, types.StrategicMergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("[setNodeUnschedulable] patching node %q failed: %w", nodeName, err)
+	}
+	return nil
+}
+
+// evictPods requests the eviction of all pods of the node which would be deleted
+// by "kubectl drain --ignore-daemonsets". The eviction API respects
+// PodDisruptionBudgets, so evictions may be refused. Returns the number of pods
+// which still need to be evicted. Call it again until it returns zero.
+func evictPods(ctx context.Context, kubeClient kubernetes.Interface, nodeName string) (int, error) {
+	pods, err := kubeClient.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String(),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("[evictPods] listing pods of node %q failed: %w", nodeName, err)
+	}
+
+	remaining := 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !podNeedsEviction(pod) {
+			continue
+		}
+		remaining++
+
+		if pod.DeletionTimestamp != nil {
+			// The eviction was already accepted. Wait for the pod to terminate.
+			continue
+		}
+
+		err := kubeClient.PolicyV1().Evictions(pod.Namespace).Evict(ctx, &policyv1.Eviction{
+			ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+		})
+		switch {
+		case err == nil, apierrors.IsNotFound(err):
+		case apierrors.IsTooManyRequests(err):
+			klog.V(2).Infof("[evictPods] eviction of pod %s/%s refused by disruption budget", pod.Namespace, pod.Name)
+		default:
+			return 0, fmt.Errorf("[evictPods] evicting pod %s/%s failed: %w", pod.Namespace, pod.Name, err)
+		}
+	}
+	return remaining, nil
+}
+
+// podNeedsEviction returns false for pods which are not affected by a drain:
+// finished pods, mirror pods and pods of DaemonSets.
+func podNeedsEviction(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+		return false
+	}
+	if controllerRef := metav1.GetControllerOf(pod); controllerRef != nil && controllerRef.Kind == "DaemonSet" {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+)
+
+var errFacilityNotAllowed = errors.New("facility of the device is not allowed")
+
+// facilityAllowed returns true if the facilities are empty or contain the location.
+func facilityAllowed(facilities []string, location string) bool {
+	if len(facilities) == 0 {
+		return true
+	}
+	for _, facility := range facilities {
+		if strings.EqualFold(facility, location) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFacility returns errFacilityNotAllowed if the location of the device is not one of the facilities.
+func checkFacility(facilities []string, device *hv.BareMetalDevice) error {
+	if facilityAllowed(facilities, device.LocationName) {
+		return nil
+	}
+	return fmt.Errorf("device %d is in facility %q, allowed are %s: %w",
+		device.DeviceId, device.LocationName, strings.Join(facilities, ","), errFacilityNotAllowed)
+}
+
+// facilityClient is a client.Interface which hides the devices outside of the
+// facilities from the controllers. Fetching such a device fails with
+// errFacilityNotAllowed, so that it is not mistaken for a deleted device.
+type facilityClient struct {
+	client.Interface
+	facilities []string
+}
+
+// newFacilityClient returns a client which is restricted to the facilities.
+// No facilities return c, which sees the devices of all facilities.
+func newFacilityClient(c client.Interface, facilities []string) client.Interface {
+	if len(facilities) == 0 {
+		return c
+	}
+	return &facilityClient{Interface: c, facilities: facilities}
+}
+
+// GetBareMetalDevice returns errFacilityNotAllowed for devices outside of the facilities.
+func (c *facilityClient) GetBareMetalDevice(ctx context.Context, deviceID int32) (*hv.BareMetalDevice, error) {
+	device, err := c.Interface.GetBareMetalDevice(ctx, deviceID)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the errors of the client are passed through.
+	}
+	if err := checkFacility(c.facilities, device); err != nil {
+		return nil, fmt.Errorf("[GetBareMetalDevice] %w", err)
+	}
+	return device, nil
+}
+
+// ListDevices returns the devices in the facilities.
+func (c *facilityClient) ListDevices(ctx context.Context) ([]hv.BareMetalDevice, error) {
+	devices, err := c.Interface.ListDevices(ctx)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the errors of the client are passed through.
+	}
+	allowed := make([]hv.BareMetalDevice, 0, len(devices))
+	for _, device := range devices {
+		if facilityAllowed(c.facilities, device.LocationName) {
+			allowed = append(allowed, device)
+		}
+	}
+	return allowed, nil
+}
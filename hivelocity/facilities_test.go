@@ -0,0 +1,102 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+func newFacilityTestClient(t *testing.T) *mocks.Interface {
+	t.Helper()
+	m := mocks.NewInterface(t)
+	lax := hv.BareMetalDevice{DeviceId: 1, LocationName: "LAX1", PowerStatus: "ON",
+		Tags: []string{"caphv-machine-name=worker-0", "caphv-device-type=x"}}
+	dal := hv.BareMetalDevice{DeviceId: 2, LocationName: "DAL1", PowerStatus: "ON",
+		Tags: []string{"caphv-machine-name=worker-1", "caphv-device-type=x"}}
+	m.On("ListDevices", mock.Anything).Return([]hv.BareMetalDevice{lax, dal}, nil).Maybe()
+	m.On("GetBareMetalDevice", mock.Anything, int32(1)).Return(&lax, nil).Maybe()
+	m.On("GetBareMetalDevice", mock.Anything, int32(2)).Return(&dal, nil).Maybe()
+	return m
+}
+
+func Test_facilityAllowed(t *testing.T) {
+	t.Parallel()
+	require.True(t, facilityAllowed(nil, "DAL1"))
+	require.True(t, facilityAllowed([]string{"lax1", "TPA1"}, "LAX1"))
+	require.False(t, facilityAllowed([]string{"LAX1", "TPA1"}, "DAL1"))
+}
+
+func Test_facilityClient(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	m := newFacilityTestClient(t)
+	require.Same(t, m, newFacilityClient(m, nil))
+
+	c := newFacilityClient(m, []string{"LAX1"})
+	devices, err := c.ListDevices(ctx)
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	require.Equal(t, int32(1), devices[0].DeviceId)
+
+	_, err = c.GetBareMetalDevice(ctx, 1)
+	require.NoError(t, err)
+
+	_, err = c.GetBareMetalDevice(ctx, 2)
+	require.ErrorIs(t, err, errFacilityNotAllowed)
+}
+
+func Test_HVInstancesV2_facilities(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	recorder := record.NewFakeRecorder(10)
+	i2 := newHVInstanceV2(newFacilityTestClient(t))
+	i2.facilities = []string{"LAX1"}
+	i2.recorder = recorder
+
+	exists, err := i2.InstanceExists(ctx, newNode("hivelocity://1", "worker-0"))
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	for _, node := range []struct{ providerID, name string }{
+		{"hivelocity://2", "worker-1"},
+		{"", "worker-1"},
+	} {
+		node := newNode(node.providerID, node.name)
+		node.UID = types.UID("uid-" + node.Name)
+
+		// The node is neither reported as deleted nor initialized.
+		_, err = i2.InstanceExists(ctx, node)
+		require.ErrorIs(t, err, errFacilityNotAllowed)
+		_, err = i2.InstanceMetadata(ctx, node)
+		require.ErrorIs(t, err, errFacilityNotAllowed)
+
+		require.Contains(t, <-recorder.Events, "FacilityNotAllowed Rejected device 2 in facility \"DAL1\"")
+		require.Contains(t, <-recorder.Events, "FacilityNotAllowed")
+	}
+
+	_, err = newZones(i2, "").GetZoneByNodeName(ctx, "worker-1")
+	require.ErrorIs(t, err, errFacilityNotAllowed)
+	require.Empty(t, recorder.Events)
+}
@@ -0,0 +1,307 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+// Labels set by the hardware labels controller.
+const (
+	labelCPUModel   = "hivelocity.net/cpu-model"
+	labelCPUCores   = "hivelocity.net/cpu-cores"
+	labelCPUThreads = "hivelocity.net/cpu-threads"
+	labelMemoryGB   = "hivelocity.net/memory-gb"
+	labelDriveType  = "hivelocity.net/drive-type"
+	labelGPU        = "hivelocity.net/gpu"
+	labelNICGbps    = "hivelocity.net/nic-gbps"
+)
+
+// hardwareLabels contains all labels which are managed by the hardware labels controller.
+var hardwareLabels = []string{
+	labelCPUModel, labelCPUCores, labelCPUThreads, labelMemoryGB, labelDriveType, labelGPU, labelNICGbps,
+}
+
+var (
+	coresRegexp     = regexp.MustCompile(`(?i)(\d+)\s*cores?`)
+	threadsRegexp   = regexp.MustCompile(`(?i)(\d+)\s*threads?`)
+	memoryRegexp    = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(GB|TB)`)
+	nicRegexp       = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(Gbps|Mbps)`)
+	nonLabelRegexp  = regexp.MustCompile(`[^A-Za-z0-9]+`)
+	htmlTagRegexp   = regexp.MustCompile(`<[^>]*>`)
+	noGPUDescriptor = map[string]bool{"": true, "none": true, "n/a": true, "no": true}
+)
+
+// hardwareLabelsController labels the nodes with the hardware specs of the
+// product of their device.
+type hardwareLabelsController struct {
+	client       client.Interface
+	kubeClient   kubernetes.Interface
+	nodeLister   corelisters.NodeLister
+	nodesSynced  cache.InformerSynced
+	products     *productCache
+	pollInterval time.Duration
+}
+
+// productCache caches the stock information of products. Products rarely change.
+type productCache struct {
+	client client.Interface
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	entries map[int32]productCacheEntry
+}
+
+type productCacheEntry struct {
+	stock   *hv.Stock
+	expires time.Time
+}
+
+func newProductCache(c client.Interface, ttl time.Duration) *productCache {
+	return &productCache{
+		client:  c,
+		ttl:     ttl,
+		entries: make(map[int32]productCacheEntry),
+	}
+}
+
+// get returns the stock information of the product. It gets fetched from the
+// API if it is not cached or expired.
+func (p *productCache) get(ctx context.Context, productID int32) (*hv.Stock, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if entry, ok := p.entries[productID]; ok && now.Before(entry.expires) {
+		return entry.stock, nil
+	}
+
+	stock, err := p.client.GetProductStock(ctx, productID)
+	if err != nil {
+		return nil, fmt.Errorf("[productCache.get] GetProductStock() failed: %w", err)
+	}
+	p.entries[productID] = productCacheEntry{stock: stock, expires: now.Add(p.ttl)}
+	return stock, nil
+}
+
+func startHardwareLabelsController(
+	ctx context.Context,
+	initContext app.ControllerInitContext,
+	_ genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error) {
+	ctrl := newHardwareLabelsController(
+		c.client,
+		completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName),
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		c.config.hardwareLabels,
+	)
+	go ctrl.Run(ctx)
+	return nil, true, nil
+}
+
+func newHardwareLabelsController(
+	c client.Interface,
+	kubeClient kubernetes.Interface,
+	nodeInformer coreinformers.NodeInformer,
+	cfg hardwareLabelsConfig,
+) *hardwareLabelsController {
+	return &hardwareLabelsController{
+		client:       c,
+		kubeClient:   kubeClient,
+		nodeLister:   nodeInformer.Lister(),
+		nodesSynced:  nodeInformer.Informer().HasSynced,
+		products:     newProductCache(c, cfg.productCacheTTL),
+		pollInterval: cfg.pollInterval,
+	}
+}
+
+// Run labels the nodes until ctx is done.
+func (c *hardwareLabelsController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.Info("Starting Hivelocity hardware labels controller")
+	defer klog.Info("Shutting down Hivelocity hardware labels controller")
+
+	if !cache.WaitForNamedCacheSync(hardwareLabelsControllerName, ctx.Done(), c.nodesSynced) {
+		return
+	}
+
+	wait.UntilWithContext(ctx, c.reconcileNodes, c.pollInterval)
+}
+
+func (c *hardwareLabelsController) reconcileNodes(ctx context.Context) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("[hardwareLabelsController] listing nodes failed: %v", err)
+		return
+	}
+
+	for _, node := range nodes {
+		if err := c.reconcileNode(ctx, node); err != nil {
+			klog.Errorf("[hardwareLabelsController] node %q: %v", node.Name, err)
+		}
+	}
+}
+
+func (c *hardwareLabelsController) reconcileNode(ctx context.Context, node *corev1.Node) error {
+	if !isNodeInitialized(node) {
+		return nil
+	}
+
+	deviceID, err := getHivelocityDeviceIDFromNode(node)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] getHivelocityDeviceIDFromNode() failed: %w", err)
+	}
+	device, err := c.client.GetBareMetalDevice(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] GetBareMetalDevice() failed. deviceID %d: %w", deviceID, err)
+	}
+	stock, err := c.products.get(ctx, device.ProductId)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] productID %d: %w", device.ProductId, err)
+	}
+
+	return patchNodeLabels(ctx, c.kubeClient, node, hardwareLabels, hardwareLabelsFromStock(stock))
+}
+
+// hardwareLabelsFromStock converts the human readable specs of a product into
+// label values. Specs which can't be parsed are skipped.
+func hardwareLabelsFromStock(stock *hv.Stock) map[string]string {
+	result := make(map[string]string)
+
+	if model := toLabelValue(stock.ProductCpu); model != "" {
+		result[labelCPUModel] = model
+	}
+
+	cores, threads := parseProcessorInfo(stock.ProcessorInfo)
+	cpuCores := htmlTagRegexp.ReplaceAllString(stock.ProductCpuCores, " ")
+	if cores == 0 {
+		cores = firstInt(coresRegexp, cpuCores)
+	}
+	if threads == 0 {
+		threads = firstInt(threadsRegexp, cpuCores)
+	}
+	if cores > 0 {
+		result[labelCPUCores] = strconv.Itoa(cores)
+	}
+	if threads > 0 {
+		result[labelCPUThreads] = strconv.Itoa(threads)
+	}
+
+	if match := memoryRegexp.FindStringSubmatch(stock.ProductMemory); match != nil {
+		gb, _ := strconv.ParseFloat(match[1], 64)
+		if strings.EqualFold(match[2], "TB") {
+			gb *= 1024
+		}
+		result[labelMemoryGB] = strconv.Itoa(int(gb))
+	}
+
+	drive := strings.ToLower(stock.ProductDrive)
+	switch {
+	case strings.Contains(drive, "nvme"):
+		result[labelDriveType] = "nvme"
+	case strings.Contains(drive, "ssd"):
+		result[labelDriveType] = "ssd"
+	case strings.Contains(drive, "hdd"), strings.Contains(drive, "sata"), strings.Contains(drive, "sas"):
+		result[labelDriveType] = "hdd"
+	}
+
+	result[labelGPU] = strconv.FormatBool(!noGPUDescriptor[strings.ToLower(strings.TrimSpace(stock.ProductGpu))])
+
+	// The format is "Free Outbound Transfer / NIC Size", for example "20TB / 1Gbps".
+	if match := nicRegexp.FindStringSubmatch(stock.ProductBandwidth); match != nil {
+		speed, _ := strconv.ParseFloat(match[1], 64)
+		if strings.EqualFold(match[2], "Mbps") {
+			speed /= 1000
+		}
+		result[labelNICGbps] = strconv.FormatFloat(speed, 'f', -1, 64)
+	}
+	return result
+}
+
+// parseProcessorInfo reads the cores and threads from the JSON CPU info of a
+// product. Zero means that the value is unknown.
+func parseProcessorInfo(raw interface{}) (cores, threads int) {
+	info, ok := raw.(map[string]interface{})
+	if !ok {
+		return 0, 0
+	}
+	for key, value := range info {
+		f, ok := toFloat(value)
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "cores":
+			cores = int(f)
+		case "threads":
+			threads = int(f)
+		}
+	}
+	return cores, threads
+}
+
+func firstInt(re *regexp.Regexp, s string) int {
+	match := re.FindStringSubmatch(s)
+	if match == nil {
+		return 0
+	}
+	i, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0
+	}
+	return i
+}
+
+// toLabelValue converts s into a valid label value, for example
+// "Intel Xeon E-2136 (6 cores)" into "Intel-Xeon-E-2136-6-cores".
+// Returns an empty string if that is not possible.
+func toLabelValue(s string) string {
+	value := strings.Trim(nonLabelRegexp.ReplaceAllString(htmlTagRegexp.ReplaceAllString(s, " "), "-"), "-")
+	if len(value) > validation.LabelValueMaxLength {
+		value = strings.Trim(value[:validation.LabelValueMaxLength], "-")
+	}
+	if len(validation.IsValidLabelValue(value)) != 0 {
+		return ""
+	}
+	return value
+}
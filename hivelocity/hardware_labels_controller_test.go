@@ -0,0 +1,118 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_hardwareLabelsFromStock(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		stock hv.Stock
+		want  map[string]string
+	}{
+		{
+			name: "processor info and specs",
+			stock: hv.Stock{
+				ProductCpu:       "Intel Xeon E-2136",
+				ProcessorInfo:    map[string]interface{}{"cores": float64(6), "threads": float64(12), "sockets": float64(1)},
+				ProductMemory:    "32GB DDR4",
+				ProductDrive:     "2 x 480GB SSD",
+				ProductGpu:       "NVIDIA T4",
+				ProductBandwidth: "20TB / 10Gbps",
+			},
+			want: map[string]string{
+				labelCPUModel:   "Intel-Xeon-E-2136",
+				labelCPUCores:   "6",
+				labelCPUThreads: "12",
+				labelMemoryGB:   "32",
+				labelDriveType:  "ssd",
+				labelGPU:        "true",
+				labelNICGbps:    "10",
+			},
+		},
+		{
+			name: "cores from html",
+			stock: hv.Stock{
+				ProductCpuCores:  "<b>16 Cores</b> / 32 Threads",
+				ProductMemory:    "1TB",
+				ProductDrive:     "1TB NVMe",
+				ProductGpu:       "None",
+				ProductBandwidth: "Unmetered / 500Mbps",
+			},
+			want: map[string]string{
+				labelCPUCores:   "16",
+				labelCPUThreads: "32",
+				labelMemoryGB:   "1024",
+				labelDriveType:  "nvme",
+				labelGPU:        "false",
+				labelNICGbps:    "0.5",
+			},
+		},
+		{
+			name:  "nothing known",
+			stock: hv.Stock{},
+			want:  map[string]string{labelGPU: "false"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tt.want, hardwareLabelsFromStock(&tt.stock))
+		})
+	}
+}
+
+func Test_hardwareLabels_reconcileNode(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("GetBareMetalDevice", mock.Anything, int32(dummyDeviceID)).Return(
+		&hv.BareMetalDevice{DeviceId: dummyDeviceID, ProductId: 7}, nil)
+	// The product gets fetched only once.
+	m.On("GetProductStock", mock.Anything, int32(7)).Return(&hv.Stock{ProductMemory: "64GB"}, nil).Once()
+
+	node := newNode("hivelocity://12345", nodeName)
+	node.Labels = map[string]string{labelDriveType: "hdd", "other": "x"}
+	kubeClient := fake.NewSimpleClientset(node)
+	c := &hardwareLabelsController{
+		client:     m,
+		kubeClient: kubeClient,
+		products:   newProductCache(m, time.Hour),
+	}
+	require.NoError(t, c.reconcileNode(context.Background(), node))
+	require.NoError(t, c.reconcileNode(context.Background(), node))
+
+	updatedNode, err := kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		labelMemoryGB: "64",
+		labelGPU:      "false",
+		"other":       "x",
+	}, updatedNode.Labels)
+}
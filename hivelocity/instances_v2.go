@@ -27,12 +27,31 @@ import (
 	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
 	"github.com/hivelocity/hivelocity-cloud-controller-manager/pkg/hvutils"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 	cloudprovider "k8s.io/cloud-provider"
+	cloudproviderapi "k8s.io/cloud-provider/api"
 )
 
 // HVInstancesV2 implements cloudprovider.InstanceV2.
 type HVInstancesV2 struct {
 	client client.Interface
+
+	// clusterName is the value of the caphv-cluster-name tag of the devices of
+	// the cluster. Devices of all clusters are allowed if it is empty.
+	clusterName string
+
+	// facilities are the locations of the devices which may become nodes. All
+	// locations are allowed if it is empty.
+	facilities []string
+
+	// initNode gets called by InstanceMetadata before the node gets
+	// initialized, while it still has the uninitialized taint. It is nil
+	// unless an optional controller sets it.
+	initNode func(ctx context.Context, node *corev1.Node, device *hv.BareMetalDevice) error
+
+	// recorder reports nodes whose device is outside of the facilities. It is
+	// nil until the cloud is initialized.
+	recorder record.EventRecorder
 }
 
 var _ cloudprovider.InstancesV2 = &HVInstancesV2{}
@@ -87,9 +106,39 @@ func getHivelocityDeviceIDFromNode(node *corev1.Node) (int32, error) {
 	return int32(deviceID), nil
 }
 
-// lookUpDevice looks for device via Hivelocity API if provider ID is present otherwise look for machine name label
-// present in the devices (caphv-machine-name=foo).
-func (i2 *HVInstancesV2) lookUpDevice(ctx context.Context, node *corev1.Node) (device *hv.BareMetalDevice, err error) {
+// lookUpDevice looks up the device of the node with findDevice. A device of
+// another cluster is rejected with errDeviceNotInCluster, a device outside of
+// the facilities with errFacilityNotAllowed, so that the node is neither
+// initialized nor deleted.
+func (i2 *HVInstancesV2) lookUpDevice(ctx context.Context, node *corev1.Node) (*hv.BareMetalDevice, error) {
+	device, err := i2.findDevice(ctx, node)
+	if err != nil || device == nil {
+		return device, err
+	}
+	if i2.clusterName != "" && !hvutils.HasClusterNameTag(device.Tags, i2.clusterName) {
+		if i2.recorder != nil && node.UID != "" {
+			i2.recorder.Eventf(node, corev1.EventTypeWarning, "DeviceNotInCluster",
+				"Rejected device %d without the tag %s%s",
+				device.DeviceId, hvutils.ClusterNameTagPrefix, i2.clusterName)
+		}
+		return nil, fmt.Errorf("[lookUpDevice] node %q, device %d, cluster %q: %w",
+			node.GetName(), device.DeviceId, i2.clusterName, errDeviceNotInCluster)
+	}
+	if err := checkFacility(i2.facilities, device); err != nil {
+		if i2.recorder != nil && node.UID != "" {
+			i2.recorder.Eventf(node, corev1.EventTypeWarning, "FacilityNotAllowed",
+				"Rejected device %d in facility %q, allowed facilities are %s",
+				device.DeviceId, device.LocationName, strings.Join(i2.facilities, ","))
+		}
+		return nil, fmt.Errorf("[lookUpDevice] node %q: %w", node.GetName(), err)
+	}
+	return device, nil
+}
+
+// findDevice looks for device via Hivelocity API if provider ID is present otherwise look for machine name label
+// present in the devices (caphv-machine-name=foo). Devices of the cluster are preferred if several devices have
+// the machine name.
+func (i2 *HVInstancesV2) findDevice(ctx context.Context, node *corev1.Node) (device *hv.BareMetalDevice, err error) {
 	if node.Spec.ProviderID != "" {
 		deviceID, err := getHivelocityDeviceIDFromNode(node)
 		if err != nil {
@@ -122,14 +171,19 @@ func (i2 *HVInstancesV2) lookUpDevice(ctx context.Context, node *corev1.Node) (d
 		}
 
 		for i := range devices {
-			device := devices[i]
-			name, err := hvutils.GetMachineNameFromTags(device.Tags)
+			name, err := hvutils.GetMachineNameFromTags(devices[i].Tags)
 			if err != nil {
 				continue
 			}
 
-			if name == node.GetName() {
-				return &device, nil
+			if name != node.GetName() {
+				continue
+			}
+			if i2.clusterName == "" || hvutils.HasClusterNameTag(devices[i].Tags, i2.clusterName) {
+				return &devices[i], nil
+			}
+			if device == nil {
+				device = &devices[i]
 			}
 		}
 	}
@@ -224,6 +278,12 @@ func (i2 *HVInstancesV2) InstanceMetadata(
 		return nil, errNoDeviceFound
 	}
 
+	if i2.initNode != nil && nodeUninitialized(node) {
+		if err := i2.initNode(ctx, node, device); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
 	// HV tag. Example "caphv-device-type=abc".
 	instanceType, err := hvutils.GetInstanceTypeFromTags(device.Tags)
 	if err != nil {
@@ -235,6 +295,7 @@ func (i2 *HVInstancesV2) InstanceMetadata(
 		)
 	}
 
+	zone := deviceZone(device)
 	metaData := cloudprovider.InstanceMetadata{
 		ProviderID:   strconv.Itoa(int(device.DeviceId)),
 		InstanceType: instanceType,
@@ -242,8 +303,19 @@ func (i2 *HVInstancesV2) InstanceMetadata(
 			Type:    "ExternalIP",
 			Address: device.PrimaryIp,
 		}},
-		Zone:   device.LocationName, // for example LAX1
-		Region: device.LocationName, // for example LAX1
+		Zone:   zone.FailureDomain,
+		Region: zone.Region,
 	}
 	return &metaData, nil
 }
+
+// nodeUninitialized returns true if the node still has the taint which the cloud node controller removes on
+// initialization.
+func nodeUninitialized(node *corev1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == cloudproviderapi.TaintExternalCloudProvider {
+			return true
+		}
+	}
+	return false
+}
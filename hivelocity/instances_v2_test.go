@@ -27,6 +27,8 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 	cloudprovider "k8s.io/cloud-provider"
 )
 
@@ -85,6 +87,16 @@ func newNode(providerID, nodeName string) *corev1.Node {
 	return &node
 }
 
+// newTestNodeLister returns a lister which contains the nodes.
+func newTestNodeLister(t *testing.T, nodes ...*corev1.Node) corelisters.NodeLister {
+	t.Helper()
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	for _, node := range nodes {
+		require.NoError(t, indexer.Add(node))
+	}
+	return corelisters.NewNodeLister(indexer)
+}
+
 func standardMocks(m *mocks.Interface) {
 	m.On("GetBareMetalDevice", mock.Anything, int32(dummyDeviceID)).Return(
 		&hv.BareMetalDevice{
@@ -0,0 +1,140 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+)
+
+var (
+	errNoFreeSubnet = errors.New("no free IP assignment in the pools")
+
+	errUnexpectedSplit = errors.New("split returned no smaller IP assignment")
+)
+
+// allocateFromPools returns a free assignment of the pools with the IP family
+// and the prefix length. Assignments are only used if usable returns true.
+// The smallest fitting assignment is preferred, so that large assignments
+// stay available. If it is larger than the prefix length, it gets split.
+func allocateFromPools(
+	ctx context.Context,
+	c client.Interface,
+	assignments []hv.IpAssignment,
+	pools []*net.IPNet,
+	ipv6 bool,
+	prefixLength int,
+	usable func(*hv.IpAssignment) bool,
+) (*hv.IpAssignment, *net.IPNet, error) {
+	var candidate *hv.IpAssignment
+	var candidateNet *net.IPNet
+	for i := range assignments {
+		assignment := &assignments[i]
+		subnet, ok := poolSubnet(pools, assignment.Subnet, ipv6)
+		if !ok {
+			continue
+		}
+		ones, _ := subnet.Mask.Size()
+		if ones > prefixLength || !isFreeIPAssignment(assignment) || !usable(assignment) {
+			continue
+		}
+		if candidateNet != nil {
+			candidateOnes, _ := candidateNet.Mask.Size()
+			if ones < candidateOnes || (ones == candidateOnes && assignment.AssignmentId > candidate.AssignmentId) {
+				continue
+			}
+		}
+		candidate, candidateNet = assignment, subnet
+	}
+	if candidate == nil {
+		return nil, nil, fmt.Errorf("[allocateFromPools] prefix length %d, ipv6 %t: %w",
+			prefixLength, ipv6, errNoFreeSubnet)
+	}
+
+	for ones, _ := candidateNet.Mask.Size(); ones < prefixLength; ones, _ = candidateNet.Mask.Size() {
+		parts, err := c.SplitIPAssignment(ctx, candidate.AssignmentId)
+		if err != nil {
+			return nil, nil, fmt.Errorf("[allocateFromPools] SplitIPAssignment() failed: %w", err)
+		}
+		parent := candidateNet
+		candidate, candidateNet = smallestPart(parts, ones, prefixLength)
+		if candidate == nil {
+			return nil, nil, fmt.Errorf("[allocateFromPools] splitting %s: %w", parent, errUnexpectedSplit)
+		}
+	}
+	return candidate, candidateNet, nil
+}
+
+// smallestPart returns the part of a split with the longest prefix which is
+// longer than parentOnes and not longer than prefixLength.
+func smallestPart(parts []hv.IpAssignment, parentOnes, prefixLength int) (*hv.IpAssignment, *net.IPNet) {
+	var part *hv.IpAssignment
+	var partNet *net.IPNet
+	partOnes := parentOnes
+	for i := range parts {
+		_, subnet, err := net.ParseCIDR(parts[i].Subnet)
+		if err != nil {
+			continue
+		}
+		ones, _ := subnet.Mask.Size()
+		if ones > partOnes && ones <= prefixLength {
+			part, partNet, partOnes = &parts[i], subnet, ones
+		}
+	}
+	return part, partNet
+}
+
+// poolSubnet parses the subnet of an assignment and returns it if it has the
+// IP family and is part of one of the pools.
+func poolSubnet(pools []*net.IPNet, subnet string, ipv6 bool) (*net.IPNet, bool) {
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil || (ipNet.IP.To4() == nil) != ipv6 {
+		return nil, false
+	}
+	ones, _ := ipNet.Mask.Size()
+	for _, pool := range pools {
+		poolOnes, _ := pool.Mask.Size()
+		if pool.Contains(ipNet.IP) && poolOnes <= ones {
+			return ipNet, true
+		}
+	}
+	return nil, false
+}
+
+// poolFamilies returns the IP families of the pools in the order of the pools.
+// True means IPv6.
+func poolFamilies(pools []*net.IPNet) []bool {
+	var families []bool
+	for _, pool := range pools {
+		ipv6 := pool.IP.To4() == nil
+		if len(families) == 0 || (len(families) == 1 && families[0] != ipv6) {
+			families = append(families, ipv6)
+		}
+	}
+	return families
+}
+
+// isFreeIPAssignment returns true if the assignment is not used by a device, port, VLAN or route.
+func isFreeIPAssignment(assignment *hv.IpAssignment) bool {
+	return assignment.NextHopIp == "" && assignment.DeviceId == 0 &&
+		assignment.PortId == 0 && assignment.VlanId == 0
+}
@@ -0,0 +1,329 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+// Node conditions set by the IPMI controller.
+const (
+	// nodeConditionHardwareThermalPressure is true if a temperature sensor is above its upper threshold.
+	nodeConditionHardwareThermalPressure corev1.NodeConditionType = "HardwareThermalPressure"
+
+	// nodeConditionFanFailure is true if a fan sensor is below its lower threshold.
+	nodeConditionFanFailure corev1.NodeConditionType = "FanFailure"
+)
+
+// ipmiController polls the IPMI sensors of the devices of all nodes. The readings
+// get exported as metrics and are compared with the thresholds of the device.
+type ipmiController struct {
+	client       client.Interface
+	kubeClient   kubernetes.Interface
+	nodeLister   corelisters.NodeLister
+	nodesSynced  cache.InformerSynced
+	recorder     record.EventRecorder
+	pollInterval time.Duration
+	// exportedNodes are the nodes whose series were exported by the last poll.
+	exportedNodes map[string]bool
+}
+
+// ipmiThreshold contains the alert bounds of an IPMI sensor. Nil means that there is no bound.
+type ipmiThreshold struct {
+	lower *float64
+	upper *float64
+}
+
+func startIPMIController(
+	ctx context.Context,
+	initContext app.ControllerInitContext,
+	_ genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error) {
+	kubeClient := completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName)
+	ctrl := newIPMIController(
+		c.client,
+		kubeClient,
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		c.config.ipmi.pollInterval,
+	)
+	go ctrl.Run(ctx)
+	return nil, true, nil
+}
+
+func newIPMIController(
+	c client.Interface,
+	kubeClient kubernetes.Interface,
+	nodeInformer coreinformers.NodeInformer,
+	pollInterval time.Duration,
+) *ipmiController {
+	registerMetrics()
+	return &ipmiController{
+		client:       c,
+		kubeClient:   kubeClient,
+		nodeLister:   nodeInformer.Lister(),
+		nodesSynced:  nodeInformer.Informer().HasSynced,
+		recorder:     newEventRecorder(kubeClient, ipmiControllerName),
+		pollInterval: pollInterval,
+	}
+}
+
+// Run polls the IPMI sensors until ctx is done.
+func (c *ipmiController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.Info("Starting Hivelocity IPMI controller")
+	defer klog.Info("Shutting down Hivelocity IPMI controller")
+
+	if !cache.WaitForNamedCacheSync(ipmiControllerName, ctx.Done(), c.nodesSynced) {
+		return
+	}
+
+	wait.UntilWithContext(ctx, c.reconcileNodes, c.pollInterval)
+}
+
+func (c *ipmiController) reconcileNodes(ctx context.Context) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("[ipmiController] listing nodes failed: %v", err)
+		return
+	}
+
+	for _, node := range nodes {
+		if err := c.reconcileNode(ctx, node); err != nil {
+			klog.Errorf("[ipmiController] node %q: %v", node.Name, err)
+		}
+	}
+
+	c.exportedNodes = deleteSeriesOfDeletedNodes(c.exportedNodes, nodes, ipmiSensorReading, ipmiSensorThreshold)
+}
+
+func (c *ipmiController) reconcileNode(ctx context.Context, node *corev1.Node) error {
+	if !isNodeInitialized(node) {
+		return nil
+	}
+
+	deviceID, err := getHivelocityDeviceIDFromNode(node)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] getHivelocityDeviceIDFromNode() failed: %w", err)
+	}
+
+	info, err := c.client.GetIPMIInfo(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] GetIPMIInfo() failed. deviceID %d: %w", deviceID, err)
+	}
+
+	rawThresholds, err := c.client.GetIPMIThresholds(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] GetIPMIThresholds() failed. deviceID %d: %w", deviceID, err)
+	}
+	thresholds := parseIPMIThresholds(rawThresholds.Thresholds)
+
+	for _, sensor := range info.Sensors {
+		name := ipmiSensorName(sensor)
+		ipmiSensorReading.WithLabelValues(node.Name, name, sensor.Group, sensor.Units).Set(float64(sensor.Reading))
+
+		threshold, ok := lookUpIPMIThreshold(thresholds, sensor)
+		if !ok {
+			continue
+		}
+		if threshold.lower != nil {
+			ipmiSensorThreshold.WithLabelValues(node.Name, name, "lower").Set(*threshold.lower)
+		}
+		if threshold.upper != nil {
+			ipmiSensorThreshold.WithLabelValues(node.Name, name, "upper").Set(*threshold.upper)
+		}
+	}
+
+	hot, failedFans := ipmiViolations(info.Sensors, thresholds)
+
+	if err := c.updateCondition(node, nodeConditionHardwareThermalPressure, hot,
+		"TemperatureAboveThreshold", "TemperatureWithinThreshold"); err != nil {
+		return err
+	}
+	return c.updateCondition(node, nodeConditionFanFailure, failedFans,
+		"FanBelowThreshold", "FanWithinThreshold")
+}
+
+// updateCondition sets the condition to true if there are violations.
+func (c *ipmiController) updateCondition(
+	node *corev1.Node,
+	conditionType corev1.NodeConditionType,
+	violations []string,
+	reasonTrue, reasonFalse string,
+) error {
+	condition := corev1.NodeCondition{
+		Type:    conditionType,
+		Status:  corev1.ConditionFalse,
+		Reason:  reasonFalse,
+		Message: "All IPMI sensors are within their thresholds",
+	}
+	if len(violations) > 0 {
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = reasonTrue
+		condition.Message = "IPMI sensors outside of their thresholds: " + strings.Join(violations, ", ")
+	}
+
+	return updateNodeCondition(c.kubeClient, c.recorder, node, condition)
+}
+
+// ipmiViolations returns the temperature sensors which are above their upper
+// threshold and the fan sensors which are below their lower threshold.
+// Sensors without thresholds are never violations.
+func ipmiViolations(sensors []hv.IpmiSensor, thresholds map[string]ipmiThreshold) (hot, failedFans []string) {
+	for _, sensor := range sensors {
+		threshold, ok := lookUpIPMIThreshold(thresholds, sensor)
+		if !ok {
+			continue
+		}
+		reading := float64(sensor.Reading)
+		switch {
+		case isThermalSensor(sensor) && threshold.upper != nil && reading > *threshold.upper:
+			hot = append(hot, fmt.Sprintf("%s=%v%s (upper threshold %v)",
+				ipmiSensorName(sensor), reading, unitSuffix(sensor.Units), *threshold.upper))
+		case isFanSensor(sensor) && threshold.lower != nil && reading < *threshold.lower:
+			failedFans = append(failedFans, fmt.Sprintf("%s=%v%s (lower threshold %v)",
+				ipmiSensorName(sensor), reading, unitSuffix(sensor.Units), *threshold.lower))
+		}
+	}
+	sort.Strings(hot)
+	sort.Strings(failedFans)
+	return hot, failedFans
+}
+
+func isThermalSensor(sensor hv.IpmiSensor) bool {
+	return containsFold(sensor.Group, "temp") ||
+		containsFold(sensor.Units, "degrees") ||
+		containsFold(sensor.Name, "temp")
+}
+
+func isFanSensor(sensor hv.IpmiSensor) bool {
+	return containsFold(sensor.Group, "fan") || containsFold(sensor.Name, "fan")
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), substr)
+}
+
+func unitSuffix(units string) string {
+	if units == "" {
+		return ""
+	}
+	return " " + units
+}
+
+// ipmiSensorName returns the name of the sensor, or the sensor ID if the name is empty.
+func ipmiSensorName(sensor hv.IpmiSensor) string {
+	if sensor.Name != "" {
+		return sensor.Name
+	}
+	return sensor.SensorId
+}
+
+// lookUpIPMIThreshold looks up the threshold by sensor name and falls back to the sensor ID.
+func lookUpIPMIThreshold(thresholds map[string]ipmiThreshold, sensor hv.IpmiSensor) (ipmiThreshold, bool) {
+	if threshold, ok := thresholds[sensor.Name]; ok {
+		return threshold, true
+	}
+	threshold, ok := thresholds[sensor.SensorId]
+	return threshold, ok
+}
+
+// parseIPMIThresholds converts the thresholds of the Hivelocity API into a map
+// from sensor name to bounds. The API does not define a schema, so both
+// {"CPU Temp": 80} (upper bound only) and {"CPU Temp": {"min": 5, "max": 80}}
+// are supported. "lower" and "upper" are accepted instead of "min" and "max".
+// Values which can't be parsed are skipped.
+func parseIPMIThresholds(raw interface{}) map[string]ipmiThreshold {
+	thresholds := make(map[string]ipmiThreshold)
+	sensors, ok := raw.(map[string]interface{})
+	if !ok {
+		return thresholds
+	}
+
+	for name, value := range sensors {
+		if upper, ok := toFloat(value); ok {
+			thresholds[name] = ipmiThreshold{upper: &upper}
+			continue
+		}
+
+		bounds, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		var threshold ipmiThreshold
+		for key, bound := range bounds {
+			f, ok := toFloat(bound)
+			if !ok {
+				continue
+			}
+			switch strings.ToLower(key) {
+			case "min", "lower":
+				threshold.lower = &f
+			case "max", "upper":
+				threshold.upper = &f
+			}
+		}
+		if threshold.lower != nil || threshold.upper != nil {
+			thresholds[name] = threshold
+		}
+	}
+	return thresholds
+}
+
+// toFloat converts a number which was decoded from JSON. Numbers in strings are supported, too.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, false
+		}
+		return f, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"encoding/json"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/stretchr/testify/require"
+)
+
+func float64Ptr(f float64) *float64 {
+	return &f
+}
+
+func Test_parseIPMIThresholds(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		raw  string
+		want map[string]ipmiThreshold
+	}{
+		{
+			name: "null",
+			raw:  `null`,
+			want: map[string]ipmiThreshold{},
+		},
+		{
+			name: "plain number is upper bound",
+			raw:  `{"CPU Temp": 80}`,
+			want: map[string]ipmiThreshold{
+				"CPU Temp": {upper: float64Ptr(80)},
+			},
+		},
+		{
+			name: "min and max",
+			raw:  `{"FAN1": {"min": 500, "max": "9000"}}`,
+			want: map[string]ipmiThreshold{
+				"FAN1": {lower: float64Ptr(500), upper: float64Ptr(9000)},
+			},
+		},
+		{
+			name: "lower and upper, invalid values get skipped",
+			raw:  `{"FAN1": {"lower": 500}, "FAN2": {"lower": "abc"}, "FAN3": true}`,
+			want: map[string]ipmiThreshold{
+				"FAN1": {lower: float64Ptr(500)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var raw interface{}
+			require.NoError(t, json.Unmarshal([]byte(tt.raw), &raw))
+			require.Equal(t, tt.want, parseIPMIThresholds(raw))
+		})
+	}
+}
+
+func Test_ipmiViolations(t *testing.T) {
+	t.Parallel()
+	sensors := []hv.IpmiSensor{
+		{Name: "CPU Temp", Group: "Temperature", Units: "degrees C", Reading: 85},
+		{Name: "System Temp", Group: "Temperature", Units: "degrees C", Reading: 30},
+		{Name: "PCH Temp", Group: "Temperature", Units: "degrees C", Reading: 99},
+		{Name: "FAN1", Group: "Fan", Units: "RPM", Reading: 0},
+		{SensorId: "42", Group: "Fan", Units: "RPM", Reading: 3000},
+		{Name: "12V", Group: "Voltage", Units: "Volts", Reading: 14},
+	}
+	thresholds := map[string]ipmiThreshold{
+		"CPU Temp":    {upper: float64Ptr(80)},
+		"System Temp": {upper: float64Ptr(80)},
+		"FAN1":        {lower: float64Ptr(500)},
+		"42":          {lower: float64Ptr(500)},
+		"12V":         {upper: float64Ptr(13)},
+	}
+
+	hot, failedFans := ipmiViolations(sensors, thresholds)
+	require.Equal(t, []string{"CPU Temp=85 degrees C (upper threshold 80)"}, hot)
+	require.Equal(t, []string{"FAN1=0 RPM (lower threshold 500)"}, failedFans)
+}
@@ -0,0 +1,193 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+// labelSyncController mirrors an allow-list of node labels onto the tags of
+// the devices. A label "team=db" becomes the tag "team=db". Tags with other
+// keys, like the caphv- tags, are preserved.
+type labelSyncController struct {
+	client       client.Interface
+	nodeLister   corelisters.NodeLister
+	nodesSynced  cache.InformerSynced
+	recorder     record.EventRecorder
+	pollInterval time.Duration
+	labels       []string
+}
+
+func startLabelSyncController(
+	ctx context.Context,
+	initContext app.ControllerInitContext,
+	_ genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error) {
+	if len(c.config.labelSync.labels) == 0 {
+		klog.Warningf("%s is empty, not starting %s", labelSyncLabelsENVVar, labelSyncControllerName)
+		return nil, false, nil
+	}
+
+	ctrl := newLabelSyncController(
+		c.client,
+		completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName),
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		c.config.labelSync,
+	)
+	go ctrl.Run(ctx)
+	return nil, true, nil
+}
+
+func newLabelSyncController(
+	c client.Interface,
+	kubeClient kubernetes.Interface,
+	nodeInformer coreinformers.NodeInformer,
+	cfg labelSyncConfig,
+) *labelSyncController {
+	return &labelSyncController{
+		client:       c,
+		nodeLister:   nodeInformer.Lister(),
+		nodesSynced:  nodeInformer.Informer().HasSynced,
+		recorder:     newEventRecorder(kubeClient, labelSyncControllerName),
+		pollInterval: cfg.pollInterval,
+		labels:       cfg.labels,
+	}
+}
+
+// Run syncs the labels of all nodes until ctx is done.
+func (c *labelSyncController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.Info("Starting Hivelocity label sync controller")
+	defer klog.Info("Shutting down Hivelocity label sync controller")
+
+	if !cache.WaitForNamedCacheSync(labelSyncControllerName, ctx.Done(), c.nodesSynced) {
+		return
+	}
+
+	wait.UntilWithContext(ctx, c.reconcileNodes, c.pollInterval)
+}
+
+func (c *labelSyncController) reconcileNodes(ctx context.Context) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("[labelSyncController] listing nodes failed: %v", err)
+		return
+	}
+
+	// One request for all devices is cheaper than one request per node.
+	devices, err := c.client.ListDevices(ctx)
+	if err != nil {
+		klog.Errorf("[labelSyncController] ListDevices() failed: %v", err)
+		return
+	}
+	devicesByID := make(map[int32]*hv.BareMetalDevice, len(devices))
+	for i := range devices {
+		devicesByID[devices[i].DeviceId] = &devices[i]
+	}
+
+	for _, node := range nodes {
+		if err := c.reconcileNode(ctx, node, devicesByID); err != nil {
+			klog.Errorf("[labelSyncController] node %q: %v", node.Name, err)
+		}
+	}
+}
+
+func (c *labelSyncController) reconcileNode(
+	ctx context.Context,
+	node *corev1.Node,
+	devicesByID map[int32]*hv.BareMetalDevice,
+) error {
+	if !isNodeInitialized(node) {
+		return nil
+	}
+
+	deviceID, err := getHivelocityDeviceIDFromNode(node)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] getHivelocityDeviceIDFromNode() failed: %w", err)
+	}
+	device, ok := devicesByID[deviceID]
+	if !ok {
+		return fmt.Errorf("[reconcileNode] deviceID %d: %w", deviceID, client.ErrNoSuchDevice)
+	}
+
+	tags, changed := mergeLabelTags(device.Tags, node.Labels, c.labels)
+	if !changed {
+		return nil
+	}
+
+	if err := c.client.SetDeviceTags(ctx, deviceID, tags); err != nil {
+		return fmt.Errorf("[reconcileNode] SetDeviceTags() failed: %w", err)
+	}
+	c.recorder.Eventf(node, corev1.EventTypeNormal, "DeviceTagsUpdated",
+		"Mirrored node labels onto the tags of device %d", deviceID)
+	return nil
+}
+
+// mergeLabelTags returns the tags of the device with the allow-listed node
+// labels as "key=value". Tags with keys which are not allow-listed are kept.
+// Tags of allow-listed keys which the node does not have get removed.
+// The returned bool is false if the tags are the same as before, ignoring the order.
+func mergeLabelTags(tags []string, nodeLabels map[string]string, allowList []string) ([]string, bool) {
+	allowed := sets.NewString(allowList...)
+
+	merged := make([]string, 0, len(tags)+len(allowList))
+	for _, tag := range tags {
+		key, _, _ := strings.Cut(tag, "=")
+		if !allowed.Has(key) {
+			merged = append(merged, tag)
+		}
+	}
+
+	labelTags := make([]string, 0, len(allowList))
+	for key := range allowed {
+		if value, ok := nodeLabels[key]; ok {
+			labelTags = append(labelTags, key+"="+value)
+		}
+	}
+	sort.Strings(labelTags)
+	merged = append(merged, labelTags...)
+
+	if len(merged) == len(tags) && sets.NewString(merged...).Equal(sets.NewString(tags...)) {
+		return tags, false
+	}
+	return merged, true
+}
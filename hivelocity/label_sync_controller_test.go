@@ -0,0 +1,103 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func Test_mergeLabelTags(t *testing.T) {
+	t.Parallel()
+	allowList := []string{"team", "example.com/pool"}
+
+	tests := []struct {
+		name        string
+		tags        []string
+		labels      map[string]string
+		wantTags    []string
+		wantChanged bool
+	}{
+		{
+			name:        "add labels, keep other tags",
+			tags:        []string{"caphv-machine-name=myNode", "billing"},
+			labels:      map[string]string{"team": "db", "example.com/pool": "big", "other": "x"},
+			wantTags:    []string{"caphv-machine-name=myNode", "billing", "example.com/pool=big", "team=db"},
+			wantChanged: true,
+		},
+		{
+			name:        "update value",
+			tags:        []string{"team=web", "caphv-machine-name=myNode"},
+			labels:      map[string]string{"team": "db"},
+			wantTags:    []string{"caphv-machine-name=myNode", "team=db"},
+			wantChanged: true,
+		},
+		{
+			name:        "remove tag of removed label",
+			tags:        []string{"team=db", "caphv-machine-name=myNode"},
+			labels:      map[string]string{},
+			wantTags:    []string{"caphv-machine-name=myNode"},
+			wantChanged: true,
+		},
+		{
+			name:        "unchanged in different order",
+			tags:        []string{"team=db", "caphv-machine-name=myNode"},
+			labels:      map[string]string{"team": "db"},
+			wantTags:    []string{"team=db", "caphv-machine-name=myNode"},
+			wantChanged: false,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tags, changed := mergeLabelTags(tt.tags, tt.labels, allowList)
+			require.Equal(t, tt.wantChanged, changed)
+			require.Equal(t, tt.wantTags, tags)
+		})
+	}
+}
+
+func Test_labelSync_reconcileNode(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("SetDeviceTags", mock.Anything, int32(dummyDeviceID),
+		[]string{"caphv-machine-name=myNode", "team=db"}).Return(nil)
+
+	node := newNode("hivelocity://12345", nodeName)
+	node.Labels = map[string]string{"team": "db"}
+	c := &labelSyncController{
+		client:   m,
+		recorder: record.NewFakeRecorder(10),
+		labels:   []string{"team"},
+	}
+
+	devicesByID := map[int32]*hv.BareMetalDevice{
+		dummyDeviceID: {DeviceId: dummyDeviceID, Tags: []string{"caphv-machine-name=myNode"}},
+	}
+	require.NoError(t, c.reconcileNode(context.Background(), node, devicesByID))
+
+	// The node without provider ID gets skipped.
+	require.NoError(t, c.reconcileNode(context.Background(), &corev1.Node{}, devicesByID))
+}
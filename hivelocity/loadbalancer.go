@@ -0,0 +1,359 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/pkg/networktask"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+)
+
+// loadBalancerAssignmentAnnotation contains the ID of the IP assignment of the virtual IP of a service.
+const loadBalancerAssignmentAnnotation = "hivelocity.net/load-balancer-ip-assignment"
+
+var (
+	errNoLoadBalancerNode = errors.New("no ready node with an address of the IP family")
+
+	errLoadBalancerTaskPending = errors.New("network task of the load balancer is pending")
+
+	errLoadBalancerIPNotSupported = errors.New("spec.loadBalancerIP is not supported")
+)
+
+// loadBalancer implements cloudprovider.LoadBalancer with IP assignments.
+// Each service gets a single IP assignment of the pools as virtual IP. The
+// assignment is routed to one ready node, where kube-proxy or the CNI
+// terminates the traffic. If the node goes away, the assignment gets routed
+// to another node.
+type loadBalancer struct {
+	client     client.Interface
+	kubeClient kubernetes.Interface
+	tracker    *networktask.Tracker
+	pools      []*net.IPNet
+
+	// mu serializes the allocation of assignments.
+	mu sync.Mutex
+
+	// allocated maps the UIDs of the services to the IDs of their assignments,
+	// until the annotation of the service is visible in the informer cache.
+	allocated map[types.UID]int32
+}
+
+var _ cloudprovider.LoadBalancer = (*loadBalancer)(nil)
+
+func newLoadBalancer(c client.Interface, kubeClient kubernetes.Interface, cfg loadBalancerConfig) *loadBalancer {
+	return &loadBalancer{
+		client:     c,
+		kubeClient: kubeClient,
+		tracker: networktask.New(networktask.Options{
+			Controller: "hivelocity-load-balancer",
+			Client:     c,
+			Recorder:   newEventRecorder(kubeClient, "hivelocity-load-balancer"),
+			Patch:      serviceAnnotationPatcher(kubeClient),
+		}),
+		pools:     cfg.pools,
+		allocated: make(map[types.UID]int32),
+	}
+}
+
+// GetLoadBalancer implements cloudprovider.LoadBalancer.GetLoadBalancer.
+func (lb *loadBalancer) GetLoadBalancer(
+	ctx context.Context,
+	_ string,
+	service *corev1.Service,
+) (*corev1.LoadBalancerStatus, bool, error) {
+	assignments, err := lb.client.ListIPAssignments(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("[GetLoadBalancer] ListIPAssignments() failed: %w", err)
+	}
+	lb.mu.Lock()
+	assignment := lb.findAssignment(service, assignments)
+	lb.mu.Unlock()
+	if assignment == nil {
+		return nil, false, nil
+	}
+	return loadBalancerStatus(assignment), true, nil
+}
+
+// GetLoadBalancerName implements cloudprovider.LoadBalancer.GetLoadBalancerName.
+func (*loadBalancer) GetLoadBalancerName(_ context.Context, _ string, service *corev1.Service) string {
+	return cloudprovider.DefaultLoadBalancerName(service)
+}
+
+// EnsureLoadBalancer implements cloudprovider.LoadBalancer.EnsureLoadBalancer.
+// It allocates an assignment for the service if it has none, and routes it to a ready node.
+func (lb *loadBalancer) EnsureLoadBalancer(
+	ctx context.Context,
+	_ string,
+	service *corev1.Service,
+	nodes []*corev1.Node,
+) (*corev1.LoadBalancerStatus, error) {
+	if service.Spec.LoadBalancerIP != "" {
+		return nil, fmt.Errorf("[EnsureLoadBalancer] service %s/%s: %w",
+			service.Namespace, service.Name, errLoadBalancerIPNotSupported)
+	}
+	lb.tracker.Resume(service)
+
+	assignments, err := lb.client.ListIPAssignments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[EnsureLoadBalancer] ListIPAssignments() failed: %w", err)
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	assignment := lb.findAssignment(service, assignments)
+	if assignment == nil {
+		assignment, err = lb.allocate(ctx, service, assignments)
+		if err != nil {
+			return nil, fmt.Errorf("[EnsureLoadBalancer] allocate() failed: %w", err)
+		}
+	}
+
+	if err := lb.route(ctx, service, assignment, nodes); err != nil {
+		return nil, fmt.Errorf("[EnsureLoadBalancer] route() failed: %w", err)
+	}
+	return loadBalancerStatus(assignment), nil
+}
+
+// UpdateLoadBalancer implements cloudprovider.LoadBalancer.UpdateLoadBalancer.
+// The assignment gets routed to another node if its node is not in nodes or not ready.
+func (lb *loadBalancer) UpdateLoadBalancer(
+	ctx context.Context,
+	clusterName string,
+	service *corev1.Service,
+	nodes []*corev1.Node,
+) error {
+	_, err := lb.EnsureLoadBalancer(ctx, clusterName, service, nodes)
+	return err
+}
+
+// EnsureLoadBalancerDeleted implements cloudprovider.LoadBalancer.EnsureLoadBalancerDeleted.
+// The routing of the assignment gets cleared. The assignment stays in the
+// pools and can be allocated by another service.
+func (lb *loadBalancer) EnsureLoadBalancerDeleted(ctx context.Context, _ string, service *corev1.Service) error {
+	assignments, err := lb.client.ListIPAssignments(ctx)
+	if err != nil {
+		return fmt.Errorf("[EnsureLoadBalancerDeleted] ListIPAssignments() failed: %w", err)
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	assignment := lb.findAssignment(service, assignments)
+	if assignment != nil && assignment.NextHopIp != "" {
+		task, err := lb.client.ClearIPAssignment(ctx, assignment.AssignmentId)
+		if err != nil {
+			return fmt.Errorf("[EnsureLoadBalancerDeleted] ClearIPAssignment() failed: %w", err)
+		}
+		klog.Infof("Removed route of load balancer %s of service %s/%s. Network task %q",
+			assignment.Subnet, service.Namespace, service.Name, task.TaskId)
+		// The service is usually deleted, so the task is only tracked in memory.
+		if err := lb.tracker.Track(ctx, task.TaskId); err != nil {
+			return fmt.Errorf("[EnsureLoadBalancerDeleted] Track() failed: %w", err)
+		}
+	}
+
+	if err := lb.patchAssignmentAnnotation(ctx, service, ""); err != nil {
+		return fmt.Errorf("[EnsureLoadBalancerDeleted] patchAssignmentAnnotation() failed: %w", err)
+	}
+	delete(lb.allocated, service.UID)
+	return nil
+}
+
+// findAssignment returns the assignment of the service, nil if it has none.
+func (lb *loadBalancer) findAssignment(service *corev1.Service, assignments []hv.IpAssignment) *hv.IpAssignment {
+	id, ok := lb.allocated[service.UID]
+	if !ok {
+		parsed, err := strconv.ParseInt(service.Annotations[loadBalancerAssignmentAnnotation], 10, 32)
+		if err != nil {
+			return nil
+		}
+		id = int32(parsed)
+	}
+	for i := range assignments {
+		if assignments[i].AssignmentId == id {
+			return &assignments[i]
+		}
+	}
+	return nil
+}
+
+// allocate reserves a single IP of the pools for the service. The ID of the
+// assignment gets stored in an annotation of the service before the
+// assignment gets routed.
+func (lb *loadBalancer) allocate(
+	ctx context.Context,
+	service *corev1.Service,
+	assignments []hv.IpAssignment,
+) (*hv.IpAssignment, error) {
+	referenced, err := lb.referencedAssignments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[allocate] referencedAssignments() failed: %w", err)
+	}
+
+	ipv6 := len(service.Spec.IPFamilies) > 0 && service.Spec.IPFamilies[0] == corev1.IPv6Protocol
+	prefixLength := net.IPv4len * 8
+	if ipv6 {
+		prefixLength = net.IPv6len * 8
+	}
+	assignment, _, err := allocateFromPools(ctx, lb.client, assignments, lb.pools, ipv6, prefixLength,
+		func(assignment *hv.IpAssignment) bool {
+			return !referenced[assignment.AssignmentId]
+		})
+	if err != nil {
+		return nil, fmt.Errorf("[allocate] allocateFromPools() failed: %w", err)
+	}
+
+	if err := lb.patchAssignmentAnnotation(ctx, service, strconv.Itoa(int(assignment.AssignmentId))); err != nil {
+		return nil, fmt.Errorf("[allocate] patchAssignmentAnnotation() failed: %w", err)
+	}
+	lb.allocated[service.UID] = assignment.AssignmentId
+	klog.Infof("Allocated load balancer %s for service %s/%s", assignment.Subnet, service.Namespace, service.Name)
+	return assignment, nil
+}
+
+// referencedAssignments returns the IDs of the assignments of all services.
+func (lb *loadBalancer) referencedAssignments(ctx context.Context) (map[int32]bool, error) {
+	services, err := lb.kubeClient.CoreV1().Services(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("[referencedAssignments] listing services failed: %w", err)
+	}
+	referenced := make(map[int32]bool)
+	for _, id := range lb.allocated {
+		referenced[id] = true
+	}
+	for _, service := range services.Items {
+		id, err := strconv.ParseInt(service.Annotations[loadBalancerAssignmentAnnotation], 10, 32)
+		if err == nil {
+			referenced[int32(id)] = true
+		}
+	}
+	return referenced, nil
+}
+
+// route routes the assignment to a ready node. The current node is kept while it is ready.
+func (lb *loadBalancer) route(
+	ctx context.Context,
+	service *corev1.Service,
+	assignment *hv.IpAssignment,
+	nodes []*corev1.Node,
+) error {
+	_, subnet, err := net.ParseCIDR(assignment.Subnet)
+	if err != nil {
+		return fmt.Errorf("[route] ParseCIDR() failed: %w", err)
+	}
+	node, nextHop, err := pickLoadBalancerNode(service, nodes, assignment.NextHopIp, subnet.IP.To4() == nil)
+	if err != nil {
+		return fmt.Errorf("[route] pickLoadBalancerNode() failed: %w", err)
+	}
+	if assignment.NextHopIp == nextHop {
+		return nil
+	}
+	if lb.tracker.Pending(service) {
+		// Retried by the service controller when the task has finished.
+		return fmt.Errorf("[route] service %s/%s: %w", service.Namespace, service.Name, errLoadBalancerTaskPending)
+	}
+
+	task, err := lb.client.SetIPAssignmentNextHop(ctx, assignment.AssignmentId, nextHop)
+	if err != nil {
+		return fmt.Errorf("[route] SetIPAssignmentNextHop() failed: %w", err)
+	}
+	klog.Infof("Routing load balancer %s of service %s/%s to node %q (%s). Network task %q",
+		assignment.Subnet, service.Namespace, service.Name, node.Name, nextHop, task.TaskId)
+	if err := lb.tracker.Track(ctx, task.TaskId, service); err != nil {
+		return fmt.Errorf("[route] Track() failed: %w", err)
+	}
+	return nil
+}
+
+func (lb *loadBalancer) patchAssignmentAnnotation(ctx context.Context, service *corev1.Service, value string) error {
+	return serviceAnnotationPatcher(lb.kubeClient)(ctx, service, loadBalancerAssignmentAnnotation, value)
+}
+
+// pickLoadBalancerNode returns the ready node the virtual IP of the service
+// gets routed to. The node of the current next hop is kept. Otherwise the
+// services are spread over the nodes by their UID.
+func pickLoadBalancerNode(
+	service *corev1.Service,
+	nodes []*corev1.Node,
+	currentNextHop string,
+	ipv6 bool,
+) (*corev1.Node, string, error) {
+	type candidate struct {
+		node    *corev1.Node
+		address string
+	}
+	var candidates []candidate
+	for _, node := range nodes {
+		if !isNodeReady(node) || !node.DeletionTimestamp.IsZero() {
+			continue
+		}
+		address, err := nodeAddress(node, ipv6)
+		if err != nil {
+			continue
+		}
+		if address == currentNextHop {
+			return node, address, nil
+		}
+		candidates = append(candidates, candidate{node: node, address: address})
+	}
+	if len(candidates) == 0 {
+		return nil, "", fmt.Errorf("[pickLoadBalancerNode] service %s/%s, ipv6 %t: %w",
+			service.Namespace, service.Name, ipv6, errNoLoadBalancerNode)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].node.Name < candidates[j].node.Name })
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(service.UID))
+	picked := candidates[h.Sum32()%uint32(len(candidates))]
+	return picked.node, picked.address, nil
+}
+
+func isNodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// loadBalancerStatus returns the status with the virtual IP of the assignment.
+func loadBalancerStatus(assignment *hv.IpAssignment) *corev1.LoadBalancerStatus {
+	ip, _, err := net.ParseCIDR(assignment.Subnet)
+	if err != nil {
+		return &corev1.LoadBalancerStatus{}
+	}
+	return &corev1.LoadBalancerStatus{
+		Ingress: []corev1.LoadBalancerIngress{{IP: ip.String()}},
+	}
+}
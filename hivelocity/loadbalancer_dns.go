@@ -0,0 +1,347 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	corev1 "k8s.io/api/core/v1"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+)
+
+var (
+	errNoSuchDomain = errors.New("no such domain")
+
+	errDNSLoadBalancerLocalPolicy = errors.New(
+		"externalTrafficPolicy Local is not supported, the records contain all ready nodes")
+
+	errDNSLoadBalancerNoNodePorts = errors.New(
+		"allocateLoadBalancerNodePorts false is not supported, the service is only reachable via its node ports")
+)
+
+// dnsLoadBalancer implements cloudprovider.LoadBalancer with DNS round-robin.
+// Each service gets the name <service>.<namespace>.<zone>, which resolves to
+// the external addresses of all ready nodes. Nothing listens on the port of
+// the service on the nodes: clients connect to the node ports, which
+// kube-proxy or the CNI forwards to the pods of any node.
+type dnsLoadBalancer struct {
+	client client.Interface
+	zone   string
+	ttl    int32
+
+	// mu serializes the changes of the records and guards domainID.
+	mu sync.Mutex
+
+	// domainID is the ID of the zone. Zero until it has been looked up.
+	domainID int32
+}
+
+var _ cloudprovider.LoadBalancer = (*dnsLoadBalancer)(nil)
+
+func newDNSLoadBalancer(c client.Interface, cfg loadBalancerConfig) *dnsLoadBalancer {
+	return &dnsLoadBalancer{
+		client: c,
+		zone:   cfg.dnsZone,
+		ttl:    int32(cfg.dnsTTL),
+	}
+}
+
+// GetLoadBalancer implements cloudprovider.LoadBalancer.GetLoadBalancer.
+func (lb *dnsLoadBalancer) GetLoadBalancer(
+	ctx context.Context,
+	_ string,
+	service *corev1.Service,
+) (*corev1.LoadBalancerStatus, bool, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	domainID, err := lb.getDomainID(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("[GetLoadBalancer] getDomainID() failed: %w", err)
+	}
+	name := lb.hostname(service)
+	aRecord, aaaaRecords, err := lb.listRecords(ctx, domainID, name)
+	if err != nil {
+		return nil, false, fmt.Errorf("[GetLoadBalancer] listRecords() failed: %w", err)
+	}
+	if aRecord == nil && len(aaaaRecords) == 0 {
+		return nil, false, nil
+	}
+	return dnsLoadBalancerStatus(name), true, nil
+}
+
+// GetLoadBalancerName implements cloudprovider.LoadBalancer.GetLoadBalancerName.
+func (*dnsLoadBalancer) GetLoadBalancerName(_ context.Context, _ string, service *corev1.Service) string {
+	return cloudprovider.DefaultLoadBalancerName(service)
+}
+
+// EnsureLoadBalancer implements cloudprovider.LoadBalancer.EnsureLoadBalancer.
+// The records of the service get created or updated, so that they contain
+// the addresses of all ready nodes.
+func (lb *dnsLoadBalancer) EnsureLoadBalancer(
+	ctx context.Context,
+	_ string,
+	service *corev1.Service,
+	nodes []*corev1.Node,
+) (*corev1.LoadBalancerStatus, error) {
+	if service.Spec.LoadBalancerIP != "" {
+		return nil, fmt.Errorf("[EnsureLoadBalancer] service %s/%s: %w",
+			service.Namespace, service.Name, errLoadBalancerIPNotSupported)
+	}
+	if service.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyTypeLocal {
+		return nil, fmt.Errorf("[EnsureLoadBalancer] service %s/%s: %w",
+			service.Namespace, service.Name, errDNSLoadBalancerLocalPolicy)
+	}
+	if allocate := service.Spec.AllocateLoadBalancerNodePorts; allocate != nil && !*allocate {
+		return nil, fmt.Errorf("[EnsureLoadBalancer] service %s/%s: %w",
+			service.Namespace, service.Name, errDNSLoadBalancerNoNodePorts)
+	}
+	ipv4, ipv6 := dnsLoadBalancerAddresses(service, nodes)
+	if len(ipv4) == 0 && len(ipv6) == 0 {
+		return nil, fmt.Errorf("[EnsureLoadBalancer] service %s/%s: %w",
+			service.Namespace, service.Name, errNoLoadBalancerNode)
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	domainID, err := lb.getDomainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[EnsureLoadBalancer] getDomainID() failed: %w", err)
+	}
+	name := lb.hostname(service)
+	if err := lb.syncRecords(ctx, domainID, name, ipv4, ipv6); err != nil {
+		return nil, fmt.Errorf("[EnsureLoadBalancer] syncRecords() failed: %w", err)
+	}
+	return dnsLoadBalancerStatus(name), nil
+}
+
+// UpdateLoadBalancer implements cloudprovider.LoadBalancer.UpdateLoadBalancer.
+func (lb *dnsLoadBalancer) UpdateLoadBalancer(
+	ctx context.Context,
+	clusterName string,
+	service *corev1.Service,
+	nodes []*corev1.Node,
+) error {
+	_, err := lb.EnsureLoadBalancer(ctx, clusterName, service, nodes)
+	return err
+}
+
+// EnsureLoadBalancerDeleted implements cloudprovider.LoadBalancer.EnsureLoadBalancerDeleted.
+// All records of the service get deleted.
+func (lb *dnsLoadBalancer) EnsureLoadBalancerDeleted(ctx context.Context, _ string, service *corev1.Service) error {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	domainID, err := lb.getDomainID(ctx)
+	if err != nil {
+		return fmt.Errorf("[EnsureLoadBalancerDeleted] getDomainID() failed: %w", err)
+	}
+	if err := lb.syncRecords(ctx, domainID, lb.hostname(service), nil, nil); err != nil {
+		return fmt.Errorf("[EnsureLoadBalancerDeleted] syncRecords() failed: %w", err)
+	}
+	return nil
+}
+
+// hostname returns the name of the records of the service.
+func (lb *dnsLoadBalancer) hostname(service *corev1.Service) string {
+	return strings.ToLower(fmt.Sprintf("%s.%s.%s", service.Name, service.Namespace, lb.zone))
+}
+
+// getDomainID returns the ID of the zone. It is looked up once.
+func (lb *dnsLoadBalancer) getDomainID(ctx context.Context) (int32, error) {
+	if lb.domainID != 0 {
+		return lb.domainID, nil
+	}
+	domains, err := lb.client.ListDomains(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("[getDomainID] ListDomains() failed: %w", err)
+	}
+	for _, domain := range domains {
+		if sameDNSName(domain.Name, lb.zone) {
+			lb.domainID = domain.DomainId
+			return lb.domainID, nil
+		}
+	}
+	return 0, fmt.Errorf("[getDomainID] zone %q: %w", lb.zone, errNoSuchDomain)
+}
+
+// listRecords returns the A record and the AAAA records of name.
+func (lb *dnsLoadBalancer) listRecords(
+	ctx context.Context,
+	domainID int32,
+	name string,
+) (*hv.ARecord, []hv.AaaaRecordReturn, error) {
+	aRecords, err := lb.client.ListARecords(ctx, domainID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("[listRecords] ListARecords() failed: %w", err)
+	}
+	var aRecord *hv.ARecord
+	for i := range aRecords {
+		if sameDNSName(aRecords[i].Name, name) {
+			aRecord = &aRecords[i]
+			break
+		}
+	}
+
+	allAAAARecords, err := lb.client.ListAAAARecords(ctx, domainID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("[listRecords] ListAAAARecords() failed: %w", err)
+	}
+	var aaaaRecords []hv.AaaaRecordReturn
+	for _, record := range allAAAARecords {
+		if sameDNSName(record.Name, name) {
+			aaaaRecords = append(aaaaRecords, record)
+		}
+	}
+	return aRecord, aaaaRecords, nil
+}
+
+// syncRecords changes the records of name, so that they contain exactly the
+// given addresses. Empty addresses delete the records.
+func (lb *dnsLoadBalancer) syncRecords(ctx context.Context, domainID int32, name string, ipv4, ipv6 []string) error {
+	aRecord, aaaaRecords, err := lb.listRecords(ctx, domainID, name)
+	if err != nil {
+		return fmt.Errorf("[syncRecords] listRecords() failed: %w", err)
+	}
+
+	desired := hv.ARecord{Name: name, Addresses: ipv4, Ttl: lb.ttl}
+	switch {
+	case aRecord == nil && len(ipv4) > 0:
+		if err := lb.client.CreateARecord(ctx, domainID, desired); err != nil {
+			return fmt.Errorf("[syncRecords] CreateARecord() failed: %w", err)
+		}
+		klog.Infof("Created A record %s: %s", name, strings.Join(ipv4, ","))
+	case aRecord != nil && len(ipv4) == 0:
+		if err := lb.client.DeleteARecord(ctx, domainID, aRecord.Name); err != nil {
+			return fmt.Errorf("[syncRecords] DeleteARecord() failed: %w", err)
+		}
+		klog.Infof("Deleted A record %s", name)
+	case aRecord != nil && (aRecord.Ttl != lb.ttl || !sameAddresses(aRecord.Addresses, ipv4)):
+		desired.Name = aRecord.Name
+		if err := lb.client.UpdateARecord(ctx, domainID, desired); err != nil {
+			return fmt.Errorf("[syncRecords] UpdateARecord() failed: %w", err)
+		}
+		klog.Infof("Updated A record %s: %s", name, strings.Join(ipv4, ","))
+	}
+
+	wanted := make(map[string]bool, len(ipv6))
+	for _, address := range ipv6 {
+		wanted[address] = true
+	}
+	for _, record := range aaaaRecords {
+		address := net.ParseIP(record.Address).String()
+		if wanted[address] && record.Ttl == lb.ttl {
+			delete(wanted, address)
+			continue
+		}
+		if err := lb.client.DeleteAAAARecord(ctx, domainID, record.Id); err != nil {
+			return fmt.Errorf("[syncRecords] DeleteAAAARecord() failed: %w", err)
+		}
+		klog.Infof("Deleted AAAA record %s: %s", name, record.Address)
+	}
+	for _, address := range ipv6 {
+		if !wanted[address] {
+			continue
+		}
+		record := hv.AaaaRecordCreate{Name: name, Address: address, Ttl: lb.ttl}
+		if err := lb.client.CreateAAAARecord(ctx, domainID, record); err != nil {
+			return fmt.Errorf("[syncRecords] CreateAAAARecord() failed: %w", err)
+		}
+		klog.Infof("Created AAAA record %s: %s", name, address)
+	}
+	return nil
+}
+
+// dnsLoadBalancerAddresses returns the sorted external addresses of the ready
+// nodes. Only the IP families of the service are returned, IPv4 if it has none.
+func dnsLoadBalancerAddresses(service *corev1.Service, nodes []*corev1.Node) (ipv4, ipv6 []string) {
+	families := service.Spec.IPFamilies
+	if len(families) == 0 {
+		families = []corev1.IPFamily{corev1.IPv4Protocol}
+	}
+	wantIPv4, wantIPv6 := false, false
+	for _, family := range families {
+		wantIPv4 = wantIPv4 || family == corev1.IPv4Protocol
+		wantIPv6 = wantIPv6 || family == corev1.IPv6Protocol
+	}
+
+	for _, node := range nodes {
+		if !isNodeReady(node) || !node.DeletionTimestamp.IsZero() {
+			continue
+		}
+		for _, address := range node.Status.Addresses {
+			if address.Type != corev1.NodeExternalIP {
+				continue
+			}
+			ip := net.ParseIP(address.Address)
+			switch {
+			case ip == nil:
+			case ip.To4() != nil:
+				if wantIPv4 {
+					ipv4 = append(ipv4, ip.String())
+				}
+			case wantIPv6:
+				ipv6 = append(ipv6, ip.String())
+			}
+		}
+	}
+	sort.Strings(ipv4)
+	sort.Strings(ipv6)
+	return ipv4, ipv6
+}
+
+// dnsLoadBalancerStatus returns the status with the name of the records.
+func dnsLoadBalancerStatus(name string) *corev1.LoadBalancerStatus {
+	return &corev1.LoadBalancerStatus{
+		Ingress: []corev1.LoadBalancerIngress{{Hostname: name}},
+	}
+}
+
+// sameAddresses returns true if a and b contain the same IPs, in any order.
+func sameAddresses(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	normalized := make(map[string]int, len(a))
+	for _, address := range a {
+		normalized[net.ParseIP(address).String()]++
+	}
+	for _, address := range b {
+		normalized[net.ParseIP(address).String()]--
+	}
+	for _, count := range normalized {
+		if count != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// sameDNSName compares two names, ignoring the case and the trailing dot.
+func sameDNSName(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
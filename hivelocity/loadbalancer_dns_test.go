@@ -0,0 +1,179 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func newTestDNSLoadBalancer(m *mocks.Interface) *dnsLoadBalancer {
+	m.On("ListDomains", mock.Anything).Return([]hv.DomainReturn{
+		{DomainId: 7, Name: "other.example.com"},
+		{DomainId: 42, Name: "lb.example.com."},
+	}, nil).Maybe()
+	return newDNSLoadBalancer(m, loadBalancerConfig{dnsZone: "lb.example.com", dnsTTL: 60})
+}
+
+func Test_dnsLoadBalancer_EnsureLoadBalancer(t *testing.T) {
+	t.Parallel()
+	const name = "web.default.lb.example.com"
+	tests := []struct {
+		name        string
+		families    []corev1.IPFamily
+		aRecords    []hv.ARecord
+		aaaaRecords []hv.AaaaRecordReturn
+		setup       func(m *mocks.Interface)
+	}{
+		{
+			name: "create A record",
+			setup: func(m *mocks.Interface) {
+				m.On("CreateARecord", mock.Anything, int32(42), hv.ARecord{
+					Name: name, Addresses: []string{"198.51.100.1", "198.51.100.2"}, Ttl: 60,
+				}).Return(nil)
+			},
+		},
+		{
+			name:     "A record is up to date",
+			aRecords: []hv.ARecord{{Name: name + ".", Addresses: []string{"198.51.100.2", "198.51.100.1"}, Ttl: 60}},
+		},
+		{
+			name:     "update A record",
+			aRecords: []hv.ARecord{{Name: name, Addresses: []string{"198.51.100.1", "198.51.100.3"}, Ttl: 60}},
+			setup: func(m *mocks.Interface) {
+				m.On("UpdateARecord", mock.Anything, int32(42), hv.ARecord{
+					Name: name, Addresses: []string{"198.51.100.1", "198.51.100.2"}, Ttl: 60,
+				}).Return(nil)
+			},
+		},
+		{
+			name:     "dual stack",
+			families: []corev1.IPFamily{corev1.IPv6Protocol, corev1.IPv4Protocol},
+			aRecords: []hv.ARecord{{Name: name, Addresses: []string{"198.51.100.1", "198.51.100.2"}, Ttl: 60}},
+			aaaaRecords: []hv.AaaaRecordReturn{
+				{Id: 1, Name: name, Address: "2001:db8::1", Ttl: 60},
+				{Id: 2, Name: name, Address: "2001:db8::3", Ttl: 60},
+				{Id: 3, Name: "other.default.lb.example.com", Address: "2001:db8::9", Ttl: 60},
+			},
+			setup: func(m *mocks.Interface) {
+				m.On("DeleteAAAARecord", mock.Anything, int32(42), int32(2)).Return(nil)
+				m.On("CreateAAAARecord", mock.Anything, int32(42), hv.AaaaRecordCreate{
+					Name: name, Address: "2001:db8::2", Ttl: 60,
+				}).Return(nil)
+			},
+		},
+		{
+			name:     "IPv6 only",
+			families: []corev1.IPFamily{corev1.IPv6Protocol},
+			aRecords: []hv.ARecord{{Name: name, Addresses: []string{"198.51.100.1"}, Ttl: 60}},
+			aaaaRecords: []hv.AaaaRecordReturn{
+				{Id: 1, Name: name, Address: "2001:db8::1", Ttl: 60},
+				{Id: 2, Name: name, Address: "2001:0db8::2", Ttl: 60},
+			},
+			setup: func(m *mocks.Interface) {
+				m.On("DeleteARecord", mock.Anything, int32(42), name).Return(nil)
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := mocks.NewInterface(t)
+			m.On("ListARecords", mock.Anything, int32(42)).Return(tt.aRecords, nil)
+			m.On("ListAAAARecords", mock.Anything, int32(42)).Return(tt.aaaaRecords, nil)
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+
+			service := newLoadBalancerTestService(nil)
+			service.Spec.IPFamilies = tt.families
+			node1 := newLoadBalancerTestNode("node-1", "198.51.100.1", true)
+			node1.Status.Addresses = append(node1.Status.Addresses,
+				corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "2001:db8::1"},
+				corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "10.0.0.1"})
+			node2 := newLoadBalancerTestNode("node-2", "198.51.100.2", true)
+			node2.Status.Addresses = append(node2.Status.Addresses,
+				corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "2001:db8::2"})
+			notReady := newLoadBalancerTestNode("node-3", "198.51.100.3", false)
+
+			lb := newTestDNSLoadBalancer(m)
+			status, err := lb.EnsureLoadBalancer(context.Background(), "prod", service,
+				[]*corev1.Node{node2, notReady, node1})
+			require.NoError(t, err)
+			require.Equal(t, []corev1.LoadBalancerIngress{{Hostname: name}}, status.Ingress)
+		})
+	}
+}
+
+func Test_dnsLoadBalancer_EnsureLoadBalancer_noNode(t *testing.T) {
+	t.Parallel()
+	lb := newDNSLoadBalancer(mocks.NewInterface(t), loadBalancerConfig{dnsZone: "lb.example.com", dnsTTL: 60})
+	_, err := lb.EnsureLoadBalancer(context.Background(), "prod", newLoadBalancerTestService(nil),
+		[]*corev1.Node{newLoadBalancerTestNode("node-1", "198.51.100.1", false)})
+	require.ErrorIs(t, err, errNoLoadBalancerNode)
+}
+
+func Test_dnsLoadBalancer_EnsureLoadBalancer_unsupported(t *testing.T) {
+	t.Parallel()
+	lb := newDNSLoadBalancer(mocks.NewInterface(t), loadBalancerConfig{dnsZone: "lb.example.com", dnsTTL: 60})
+	nodes := []*corev1.Node{newLoadBalancerTestNode("node-1", "198.51.100.1", true)}
+
+	local := newLoadBalancerTestService(nil)
+	local.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyTypeLocal
+	_, err := lb.EnsureLoadBalancer(context.Background(), "prod", local, nodes)
+	require.ErrorIs(t, err, errDNSLoadBalancerLocalPolicy)
+
+	noNodePorts := newLoadBalancerTestService(nil)
+	allocate := false
+	noNodePorts.Spec.AllocateLoadBalancerNodePorts = &allocate
+	_, err = lb.EnsureLoadBalancer(context.Background(), "prod", noNodePorts, nodes)
+	require.ErrorIs(t, err, errDNSLoadBalancerNoNodePorts)
+}
+
+func Test_dnsLoadBalancer_noSuchDomain(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ListDomains", mock.Anything).Return([]hv.DomainReturn{{DomainId: 7, Name: "example.com"}}, nil)
+	lb := newDNSLoadBalancer(m, loadBalancerConfig{dnsZone: "lb.example.com", dnsTTL: 60})
+	_, _, err := lb.GetLoadBalancer(context.Background(), "prod", newLoadBalancerTestService(nil))
+	require.ErrorIs(t, err, errNoSuchDomain)
+}
+
+func Test_dnsLoadBalancer_EnsureLoadBalancerDeleted(t *testing.T) {
+	t.Parallel()
+	const name = "web.default.lb.example.com"
+	m := mocks.NewInterface(t)
+	m.On("ListARecords", mock.Anything, int32(42)).Return([]hv.ARecord{
+		{Name: "other.default.lb.example.com", Addresses: []string{"198.51.100.1"}, Ttl: 60},
+		{Name: name, Addresses: []string{"198.51.100.1"}, Ttl: 60},
+	}, nil)
+	m.On("ListAAAARecords", mock.Anything, int32(42)).Return([]hv.AaaaRecordReturn{
+		{Id: 1, Name: name, Address: "2001:db8::1", Ttl: 60},
+	}, nil)
+	m.On("DeleteARecord", mock.Anything, int32(42), name).Return(nil)
+	m.On("DeleteAAAARecord", mock.Anything, int32(42), int32(1)).Return(nil)
+
+	lb := newTestDNSLoadBalancer(m)
+	require.NoError(t, lb.EnsureLoadBalancerDeleted(context.Background(), "prod", newLoadBalancerTestService(nil)))
+}
@@ -0,0 +1,218 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/pkg/networktask"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newLoadBalancerTestService(annotations map[string]string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web",
+			Namespace:   "default",
+			UID:         "5a3c7c1e-0000-0000-0000-000000000000",
+			Annotations: annotations,
+		},
+		Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+	}
+}
+
+func newLoadBalancerTestNode(name, address string, ready bool) *corev1.Node {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	node := newNode("hivelocity://12345", name)
+	node.Status.Addresses = []corev1.NodeAddress{{Type: corev1.NodeExternalIP, Address: address}}
+	node.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}}
+	return node
+}
+
+func newTestLoadBalancer(t *testing.T, m *mocks.Interface, service *corev1.Service) *loadBalancer {
+	t.Helper()
+	return newLoadBalancer(m, fake.NewSimpleClientset(service), loadBalancerConfig{
+		pools: mustParseCIDRs(t, "192.0.2.0/24"),
+	})
+}
+
+func Test_loadBalancer_EnsureLoadBalancer_allocate(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{
+		{AssignmentId: 1, Subnet: "198.51.100.7/32"},
+		{AssignmentId: 2, Subnet: "192.0.2.0/30"},
+	}, nil)
+	m.On("SplitIPAssignment", mock.Anything, int32(2)).Return([]hv.IpAssignment{
+		{AssignmentId: 3, Subnet: "192.0.2.0/31"},
+		{AssignmentId: 4, Subnet: "192.0.2.2/31"},
+	}, nil)
+	m.On("SplitIPAssignment", mock.Anything, int32(3)).Return([]hv.IpAssignment{
+		{AssignmentId: 5, Subnet: "192.0.2.0/32"},
+		{AssignmentId: 6, Subnet: "192.0.2.1/32"},
+	}, nil)
+	m.On("SetIPAssignmentNextHop", mock.Anything, int32(5), "10.0.0.1").
+		Return(&hv.NetworkTaskDump{TaskId: "task-1"}, nil)
+
+	service := newLoadBalancerTestService(nil)
+	lb := newTestLoadBalancer(t, m, service)
+	nodes := []*corev1.Node{
+		newLoadBalancerTestNode("node-1", "10.0.0.1", true),
+		newLoadBalancerTestNode("node-2", "10.0.0.2", false),
+	}
+
+	status, err := lb.EnsureLoadBalancer(context.Background(), "prod", service, nodes)
+	require.NoError(t, err)
+	require.Equal(t, []corev1.LoadBalancerIngress{{IP: "192.0.2.0"}}, status.Ingress)
+
+	updated, err := lb.kubeClient.CoreV1().Services("default").Get(context.Background(), "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "5", updated.Annotations[loadBalancerAssignmentAnnotation])
+	require.Equal(t, "task-1", updated.Annotations[networktask.AnnotationKey])
+}
+
+func Test_loadBalancer_EnsureLoadBalancer_failover(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		nodes       []*corev1.Node
+		wantNextHop string
+		wantErr     error
+	}{
+		{
+			name: "current node is ready",
+			nodes: []*corev1.Node{
+				newLoadBalancerTestNode("node-1", "10.0.0.1", true),
+				newLoadBalancerTestNode("node-2", "10.0.0.2", true),
+			},
+		},
+		{
+			name: "current node is not ready",
+			nodes: []*corev1.Node{
+				newLoadBalancerTestNode("node-1", "10.0.0.1", false),
+				newLoadBalancerTestNode("node-2", "10.0.0.2", true),
+			},
+			wantNextHop: "10.0.0.2",
+		},
+		{
+			name:        "current node is gone",
+			nodes:       []*corev1.Node{newLoadBalancerTestNode("node-2", "10.0.0.2", true)},
+			wantNextHop: "10.0.0.2",
+		},
+		{
+			name:    "no ready node",
+			nodes:   []*corev1.Node{newLoadBalancerTestNode("node-1", "10.0.0.1", false)},
+			wantErr: errNoLoadBalancerNode,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := mocks.NewInterface(t)
+			m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{
+				{AssignmentId: 5, Subnet: "192.0.2.0/32", NextHopIp: "10.0.0.1"},
+			}, nil)
+			if tt.wantNextHop != "" {
+				m.On("SetIPAssignmentNextHop", mock.Anything, int32(5), tt.wantNextHop).
+					Return(&hv.NetworkTaskDump{TaskId: "task-1"}, nil)
+			}
+
+			service := newLoadBalancerTestService(map[string]string{loadBalancerAssignmentAnnotation: "5"})
+			lb := newTestLoadBalancer(t, m, service)
+			status, err := lb.EnsureLoadBalancer(context.Background(), "prod", service, tt.nodes)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, []corev1.LoadBalancerIngress{{IP: "192.0.2.0"}}, status.Ingress)
+		})
+	}
+}
+
+func Test_loadBalancer_EnsureLoadBalancer_pendingTask(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{
+		{AssignmentId: 5, Subnet: "192.0.2.0/32", NextHopIp: "10.0.0.1"},
+	}, nil)
+
+	service := newLoadBalancerTestService(map[string]string{
+		loadBalancerAssignmentAnnotation: "5",
+		networktask.AnnotationKey:        "task-1",
+	})
+	lb := newTestLoadBalancer(t, m, service)
+	_, err := lb.EnsureLoadBalancer(context.Background(), "prod", service,
+		[]*corev1.Node{newLoadBalancerTestNode("node-2", "10.0.0.2", true)})
+	require.ErrorIs(t, err, errLoadBalancerTaskPending)
+}
+
+func Test_loadBalancer_allocate_referenced(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{
+		{AssignmentId: 5, Subnet: "192.0.2.0/32"},
+		{AssignmentId: 6, Subnet: "192.0.2.1/32"},
+	}, nil)
+	m.On("SetIPAssignmentNextHop", mock.Anything, int32(6), "10.0.0.1").
+		Return(&hv.NetworkTaskDump{TaskId: "task-1"}, nil)
+
+	// The route of the other service has not been set up yet.
+	other := newLoadBalancerTestService(map[string]string{loadBalancerAssignmentAnnotation: "5"})
+	other.Name, other.UID = "other", "other-uid"
+	service := newLoadBalancerTestService(nil)
+	lb := newLoadBalancer(m, fake.NewSimpleClientset(service, other), loadBalancerConfig{
+		pools: mustParseCIDRs(t, "192.0.2.0/24"),
+	})
+
+	status, err := lb.EnsureLoadBalancer(context.Background(), "prod", service,
+		[]*corev1.Node{newLoadBalancerTestNode("node-1", "10.0.0.1", true)})
+	require.NoError(t, err)
+	require.Equal(t, []corev1.LoadBalancerIngress{{IP: "192.0.2.1"}}, status.Ingress)
+}
+
+func Test_loadBalancer_EnsureLoadBalancerDeleted(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{
+		{AssignmentId: 5, Subnet: "192.0.2.0/32", NextHopIp: "10.0.0.1"},
+	}, nil)
+	m.On("ClearIPAssignment", mock.Anything, int32(5)).Return(&hv.NetworkTaskDump{TaskId: "task-1"}, nil)
+
+	service := newLoadBalancerTestService(map[string]string{loadBalancerAssignmentAnnotation: "5"})
+	lb := newTestLoadBalancer(t, m, service)
+	require.NoError(t, lb.EnsureLoadBalancerDeleted(context.Background(), "prod", service))
+
+	updated, err := lb.kubeClient.CoreV1().Services("default").Get(context.Background(), "web", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotContains(t, updated.Annotations, loadBalancerAssignmentAnnotation)
+
+	_, exists, err := lb.GetLoadBalancer(context.Background(), "prod", newLoadBalancerTestService(nil))
+	require.NoError(t, err)
+	require.False(t, exists)
+}
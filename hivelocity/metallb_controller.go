@@ -0,0 +1,335 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+// ipAddressPoolResource is the resource of the IPAddressPools of MetalLB.
+var ipAddressPoolResource = schema.GroupVersionResource{
+	Group:    "metallb.io",
+	Version:  "v1beta1",
+	Resource: "ipaddresspools",
+}
+
+// managedByLabel marks the IPAddressPools which are managed by the CCM.
+// Pools without the label are never modified.
+const (
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "hivelocity-ccm"
+)
+
+// metalLBController syncs the IP assignments of the facilities of the nodes
+// into a MetalLB IPAddressPool. MetalLB in L2 mode answers ARP and NDP
+// requests for the addresses, so only assignments on a VLAN which contains a
+// port of a node are used.
+//
+// The assignments are selected by their description: the API has tags only
+// for devices, an IP assignment has no tags.
+type metalLBController struct {
+	client        client.Interface
+	kubeClient    kubernetes.Interface
+	dynamicClient dynamic.Interface
+	nodeLister    corelisters.NodeLister
+	nodesSynced   cache.InformerSynced
+	recorder      record.EventRecorder
+	pollInterval  time.Duration
+	description   *regexp.Regexp
+	namespace     string
+	poolName      string
+
+	// notOnNodeVLAN are the IDs of the matching assignments which were not
+	// on a VLAN of a node in the last sync, so that they are reported once.
+	notOnNodeVLAN sets.Set[int32]
+}
+
+func startMetalLBController(
+	ctx context.Context,
+	initContext app.ControllerInitContext,
+	_ genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error) {
+	if c.config.metalLB.description == nil {
+		klog.Warningf("%s is empty, not starting %s", metalLBDescriptionENVVar, metalLBControllerName)
+		return nil, false, nil
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(completedConfig.ClientBuilder.ConfigOrDie(initContext.ClientName))
+	if err != nil {
+		return nil, false, fmt.Errorf("[startMetalLBController] dynamic.NewForConfig() failed: %w", err)
+	}
+	ctrl := newMetalLBController(
+		c.client,
+		completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName),
+		dynamicClient,
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		c.config.metalLB,
+	)
+	go ctrl.Run(ctx)
+	return nil, true, nil
+}
+
+func newMetalLBController(
+	c client.Interface,
+	kubeClient kubernetes.Interface,
+	dynamicClient dynamic.Interface,
+	nodeInformer coreinformers.NodeInformer,
+	cfg metalLBConfig,
+) *metalLBController {
+	return &metalLBController{
+		client:        c,
+		kubeClient:    kubeClient,
+		dynamicClient: dynamicClient,
+		nodeLister:    nodeInformer.Lister(),
+		nodesSynced:   nodeInformer.Informer().HasSynced,
+		recorder:      newEventRecorder(kubeClient, metalLBControllerName),
+		pollInterval:  cfg.pollInterval,
+		description:   cfg.description,
+		namespace:     cfg.namespace,
+		poolName:      cfg.poolName,
+		notOnNodeVLAN: sets.New[int32](),
+	}
+}
+
+// Run syncs the IPAddressPool until ctx is done.
+func (c *metalLBController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.Info("Starting Hivelocity MetalLB controller")
+	defer klog.Info("Shutting down Hivelocity MetalLB controller")
+
+	if !cache.WaitForNamedCacheSync(metalLBControllerName, ctx.Done(), c.nodesSynced) {
+		return
+	}
+
+	wait.UntilWithContext(ctx, c.reconcile, c.pollInterval)
+}
+
+func (c *metalLBController) reconcile(ctx context.Context) {
+	if err := c.sync(ctx); err != nil {
+		klog.Errorf("[metalLBController] IPAddressPool %s/%s: %v", c.namespace, c.poolName, err)
+	}
+}
+
+func (c *metalLBController) sync(ctx context.Context) error {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("[sync] listing nodes failed: %w", err)
+	}
+	facilities := sets.New[string]()
+	nodeDevices := sets.New[int32]()
+	for _, node := range nodes {
+		if zone := node.Labels[corev1.LabelTopologyZone]; zone != "" {
+			facilities.Insert(strings.ToUpper(zone))
+		}
+		if !isNodeInitialized(node) {
+			continue
+		}
+		deviceID, err := getHivelocityDeviceIDFromNode(node)
+		if err != nil {
+			klog.Errorf("[metalLBController] node %q: %v", node.Name, err)
+			continue
+		}
+		nodeDevices.Insert(deviceID)
+	}
+
+	assignments, err := c.client.ListIPAssignments(ctx)
+	if err != nil {
+		return fmt.Errorf("[sync] ListIPAssignments() failed: %w", err)
+	}
+	ports, err := c.client.ListDevicePorts(ctx)
+	if err != nil {
+		return fmt.Errorf("[sync] ListDevicePorts() failed: %w", err)
+	}
+	nodePorts := sets.New[int32]()
+	for _, port := range ports {
+		if nodeDevices.Has(port.DeviceId) {
+			nodePorts.Insert(port.PortId)
+		}
+	}
+
+	pool, err := c.getPool(ctx)
+	if err != nil {
+		return fmt.Errorf("[sync] getPool() failed: %w", err)
+	}
+
+	var addresses []string
+	nodeVLANs := make(map[int32]bool)
+	notOnNodeVLAN := sets.New[int32]()
+	for i := range assignments {
+		assignment := &assignments[i]
+		if !c.description.MatchString(assignment.Description) ||
+			!facilities.Has(strings.ToUpper(assignment.FacilityCode)) {
+			continue
+		}
+		onNodeVLAN, err := c.isOnNodeVLAN(ctx, assignment, nodePorts, nodeVLANs)
+		if err != nil {
+			return fmt.Errorf("[sync] isOnNodeVLAN() failed: %w", err)
+		}
+		if !onNodeVLAN {
+			notOnNodeVLAN.Insert(assignment.AssignmentId)
+			if c.notOnNodeVLAN.Has(assignment.AssignmentId) {
+				continue
+			}
+			if pool != nil {
+				c.recorder.Eventf(pool, corev1.EventTypeWarning, "IPAssignmentNotOnNodeVLAN",
+					"IP assignment %d (%s) is not routed to a VLAN of a node, it is not used",
+					assignment.AssignmentId, assignment.Subnet)
+			}
+			klog.Warningf("[metalLBController] IP assignment %d (%s) is not routed to a VLAN of a node",
+				assignment.AssignmentId, assignment.Subnet)
+			continue
+		}
+		addresses = append(addresses, metalLBAddresses(assignment))
+	}
+	sort.Strings(addresses)
+	c.notOnNodeVLAN = notOnNodeVLAN
+
+	return c.applyPool(ctx, pool, addresses)
+}
+
+// isOnNodeVLAN returns true if the assignment is routed to a VLAN which
+// contains a port of a node. The results per VLAN are cached in nodeVLANs.
+func (c *metalLBController) isOnNodeVLAN(
+	ctx context.Context,
+	assignment *hv.IpAssignment,
+	nodePorts sets.Set[int32],
+	nodeVLANs map[int32]bool,
+) (bool, error) {
+	if assignment.VlanId == 0 {
+		return false, nil
+	}
+	if onNodeVLAN, ok := nodeVLANs[assignment.VlanId]; ok {
+		return onNodeVLAN, nil
+	}
+	vlan, err := c.client.GetVLAN(ctx, assignment.VlanId)
+	if err != nil {
+		return false, fmt.Errorf("[isOnNodeVLAN] GetVLAN() failed: %w", err)
+	}
+	nodeVLANs[assignment.VlanId] = nodePorts.HasAny(vlan.PortIds...)
+	return nodeVLANs[assignment.VlanId], nil
+}
+
+// getPool returns the IPAddressPool, nil if it does not exist.
+func (c *metalLBController) getPool(ctx context.Context) (*unstructured.Unstructured, error) {
+	pool, err := c.dynamicClient.Resource(ipAddressPoolResource).Namespace(c.namespace).
+		Get(ctx, c.poolName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[getPool] getting IPAddressPool failed: %w", err)
+	}
+	return pool, nil
+}
+
+// applyPool creates or updates the IPAddressPool, so that it contains the
+// addresses. The pool is never deleted: if there are no addresses it stays
+// unchanged, since services may still use its IPs.
+func (c *metalLBController) applyPool(ctx context.Context, pool *unstructured.Unstructured, addresses []string) error {
+	pools := c.dynamicClient.Resource(ipAddressPoolResource).Namespace(c.namespace)
+	if pool != nil && pool.GetLabels()[managedByLabel] != managedByValue {
+		c.recorder.Eventf(pool, corev1.EventTypeWarning, "IPAddressPoolNotManaged",
+			"The IPAddressPool has no label %s=%s, it is not modified", managedByLabel, managedByValue)
+		return nil
+	}
+
+	switch {
+	case pool == nil && len(addresses) == 0:
+		return nil
+
+	case pool == nil:
+		pool = &unstructured.Unstructured{}
+		pool.SetAPIVersion(ipAddressPoolResource.GroupVersion().String())
+		pool.SetKind("IPAddressPool")
+		pool.SetNamespace(c.namespace)
+		pool.SetName(c.poolName)
+		pool.SetLabels(map[string]string{managedByLabel: managedByValue})
+		if err := setPoolAddresses(pool, addresses); err != nil {
+			return fmt.Errorf("[applyPool] setPoolAddresses() failed: %w", err)
+		}
+		if _, err := pools.Create(ctx, pool, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("[applyPool] creating IPAddressPool failed: %w", err)
+		}
+		klog.Infof("Created IPAddressPool %s/%s with %s", c.namespace, c.poolName, strings.Join(addresses, ","))
+
+	case len(addresses) == 0:
+		c.recorder.Event(pool, corev1.EventTypeWarning, "IPAddressPoolEmpty",
+			"No IP assignment matches, the IPAddressPool is not modified")
+
+	default:
+		current, _, err := unstructured.NestedStringSlice(pool.Object, "spec", "addresses")
+		if err != nil {
+			return fmt.Errorf("[applyPool] reading spec.addresses failed: %w", err)
+		}
+		if sets.New[string](current...).Equal(sets.New[string](addresses...)) {
+			return nil
+		}
+		if err := setPoolAddresses(pool, addresses); err != nil {
+			return fmt.Errorf("[applyPool] setPoolAddresses() failed: %w", err)
+		}
+		if _, err := pools.Update(ctx, pool, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("[applyPool] updating IPAddressPool failed: %w", err)
+		}
+		klog.Infof("Updated IPAddressPool %s/%s to %s", c.namespace, c.poolName, strings.Join(addresses, ","))
+	}
+	return nil
+}
+
+func setPoolAddresses(pool *unstructured.Unstructured, addresses []string) error {
+	return unstructured.SetNestedStringSlice(pool.Object, addresses, "spec", "addresses")
+}
+
+// metalLBAddresses returns the usable range of the assignment, which excludes
+// the network, gateway and broadcast addresses of IPv4 subnets. The subnet is
+// returned if the range is unknown.
+func metalLBAddresses(assignment *hv.IpAssignment) string {
+	if assignment.FirstUsableIp != "" && assignment.LastUsableIp != "" {
+		return assignment.FirstUsableIp + "-" + assignment.LastUsableIp
+	}
+	return assignment.Subnet
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func newMetalLBTestController(t *testing.T, m *mocks.Interface, objs ...runtime.Object) *metalLBController {
+	t.Helper()
+	node := newNode("hivelocity://12345", "node-1")
+	node.Labels = map[string]string{corev1.LabelTopologyZone: "tpa1"}
+	return &metalLBController{
+		client:     m,
+		kubeClient: fake.NewSimpleClientset(),
+		dynamicClient: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+			map[schema.GroupVersionResource]string{ipAddressPoolResource: "IPAddressPoolList"}, objs...),
+		nodeLister:    newTestNodeLister(t, node),
+		recorder:      record.NewFakeRecorder(10),
+		description:   regexp.MustCompile("^metallb"),
+		namespace:     "metallb-system",
+		poolName:      "hivelocity",
+		notOnNodeVLAN: sets.New[int32](),
+	}
+}
+
+func newMetalLBTestPool(labels map[string]string, addresses ...string) *unstructured.Unstructured {
+	pool := &unstructured.Unstructured{}
+	pool.SetAPIVersion("metallb.io/v1beta1")
+	pool.SetKind("IPAddressPool")
+	pool.SetNamespace("metallb-system")
+	pool.SetName("hivelocity")
+	pool.SetLabels(labels)
+	_ = unstructured.SetNestedStringSlice(pool.Object, addresses, "spec", "addresses")
+	return pool
+}
+
+func setupMetalLBTestMocks(m *mocks.Interface) {
+	m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{
+		{
+			AssignmentId: 1, Subnet: "192.0.2.0/29", FirstUsableIp: "192.0.2.2", LastUsableIp: "192.0.2.6",
+			FacilityCode: "TPA1", Description: "metallb web", VlanId: 7,
+		},
+		{AssignmentId: 2, Subnet: "2001:db8::/64", FacilityCode: "TPA1", Description: "metallb", VlanId: 7},
+		{AssignmentId: 3, Subnet: "198.51.100.0/29", FacilityCode: "TPA1", Description: "metallb", VlanId: 8},
+		{AssignmentId: 4, Subnet: "198.51.100.8/29", FacilityCode: "TPA1", Description: "metallb"},
+		{AssignmentId: 5, Subnet: "198.51.100.16/29", FacilityCode: "LAX2", Description: "metallb", VlanId: 7},
+		{AssignmentId: 6, Subnet: "198.51.100.24/29", FacilityCode: "TPA1", Description: "other", VlanId: 7},
+	}, nil)
+	m.On("ListDevicePorts", mock.Anything).Return([]hv.DevicePort{
+		{PortId: 101, DeviceId: 12345},
+		{PortId: 201, DeviceId: 2},
+	}, nil)
+	m.On("GetVLAN", mock.Anything, int32(7)).Return(&hv.Vlan{VlanId: 7, PortIds: []int32{101}}, nil).Once()
+	m.On("GetVLAN", mock.Anything, int32(8)).Return(&hv.Vlan{VlanId: 8, PortIds: []int32{201}}, nil).Once()
+}
+
+func Test_metalLBController_sync(t *testing.T) {
+	t.Parallel()
+	managed := map[string]string{managedByLabel: managedByValue}
+	tests := []struct {
+		name          string
+		pool          *unstructured.Unstructured
+		wantAddresses []string
+	}{
+		{
+			name:          "creates the pool",
+			wantAddresses: []string{"192.0.2.2-192.0.2.6", "2001:db8::/64"},
+		},
+		{
+			name:          "updates the pool",
+			pool:          newMetalLBTestPool(managed, "198.51.100.0/29"),
+			wantAddresses: []string{"192.0.2.2-192.0.2.6", "2001:db8::/64"},
+		},
+		{
+			name:          "does not modify pools of others",
+			pool:          newMetalLBTestPool(nil, "198.51.100.0/29"),
+			wantAddresses: []string{"198.51.100.0/29"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := mocks.NewInterface(t)
+			setupMetalLBTestMocks(m)
+			var objs []runtime.Object
+			if tt.pool != nil {
+				objs = append(objs, tt.pool)
+			}
+			c := newMetalLBTestController(t, m, objs...)
+
+			require.NoError(t, c.sync(context.Background()))
+
+			pool, err := c.dynamicClient.Resource(ipAddressPoolResource).Namespace("metallb-system").
+				Get(context.Background(), "hivelocity", metav1.GetOptions{})
+			require.NoError(t, err)
+			addresses, _, err := unstructured.NestedStringSlice(pool.Object, "spec", "addresses")
+			require.NoError(t, err)
+			require.Equal(t, tt.wantAddresses, addresses)
+		})
+	}
+}
+
+func Test_metalLBController_sync_reportsOnce(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{
+		{AssignmentId: 1, Subnet: "192.0.2.0/29", FacilityCode: "TPA1", Description: "metallb", VlanId: 7},
+		{AssignmentId: 2, Subnet: "198.51.100.0/29", FacilityCode: "TPA1", Description: "metallb"},
+	}, nil)
+	m.On("ListDevicePorts", mock.Anything).Return([]hv.DevicePort{{PortId: 101, DeviceId: 12345}}, nil)
+	m.On("GetVLAN", mock.Anything, int32(7)).Return(&hv.Vlan{VlanId: 7, PortIds: []int32{101}}, nil)
+	c := newMetalLBTestController(t, m,
+		newMetalLBTestPool(map[string]string{managedByLabel: managedByValue}, "192.0.2.0/29"))
+	recorder := c.recorder.(*record.FakeRecorder)
+
+	require.NoError(t, c.sync(context.Background()))
+	require.Contains(t, <-recorder.Events, "IPAssignmentNotOnNodeVLAN")
+
+	// The assignment is not reported again while it is not on a VLAN of a node.
+	require.NoError(t, c.sync(context.Background()))
+	require.Empty(t, recorder.Events)
+	require.Equal(t, sets.New[int32](2), c.notOnNodeVLAN)
+}
+
+func Test_metalLBController_sync_keepsEmptyPool(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{
+		{AssignmentId: 4, Subnet: "198.51.100.8/29", FacilityCode: "TPA1", Description: "metallb"},
+	}, nil)
+	m.On("ListDevicePorts", mock.Anything).Return([]hv.DevicePort{{PortId: 101, DeviceId: 12345}}, nil)
+	c := newMetalLBTestController(t, m,
+		newMetalLBTestPool(map[string]string{managedByLabel: managedByValue}, "198.51.100.8/29"))
+
+	require.NoError(t, c.sync(context.Background()))
+
+	pool, err := c.dynamicClient.Resource(ipAddressPoolResource).Namespace("metallb-system").
+		Get(context.Background(), "hivelocity", metav1.GetOptions{})
+	require.NoError(t, err)
+	addresses, _, err := unstructured.NestedStringSlice(pool.Object, "spec", "addresses")
+	require.NoError(t, err)
+	require.Equal(t, []string{"198.51.100.8/29"}, addresses)
+	events := c.recorder.(*record.FakeRecorder).Events
+	require.Contains(t, <-events, "IPAssignmentNotOnNodeVLAN")
+	require.Contains(t, <-events, "IPAddressPoolEmpty")
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// The metrics get served by the cloud controller manager on /metrics.
+
+const metricsNamespace = "hivelocity"
+
+var (
+	ipmiSensorReading = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      "ipmi",
+			Name:           "sensor_reading",
+			Help:           "Current reading of an IPMI sensor of the device of a node.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"node", "sensor", "group", "units"},
+	)
+
+	ipmiSensorThreshold = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      "ipmi",
+			Name:           "sensor_threshold",
+			Help:           "Alert threshold of an IPMI sensor of the device of a node. Bound is lower or upper.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"node", "sensor", "bound"},
+	)
+
+	nodeMonthlyCost = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Name:           "node_monthly_cost",
+			Help:           "Billed price of the service of the device of a node, normalized to one month.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"node", "product", "location"},
+	)
+
+	nodeMonthlyDiscount = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Name:           "node_monthly_discount",
+			Help:           "Discount of the service of the device of a node, normalized to one month.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"node", "product", "location"},
+	)
+
+	nodeBandwidthRate = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      "node_bandwidth",
+			Name:           "bits_per_second",
+			Help:           "Latest traffic rate of an interface of the device of a node. Direction is in or out.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"node", "interface", "direction"},
+	)
+
+	nodeBandwidthMonthBytes = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      "node_bandwidth",
+			Name:           "month_bytes",
+			Help:           "Traffic of an interface of the device of a node in the current calendar month (UTC).",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"node", "interface", "direction"},
+	)
+
+	nodeBandwidthBytesTotal = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      "node_bandwidth",
+			Name:           "bytes_total",
+			Help:           "Traffic of an interface of the device of a node since the first poll of the controller.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"node", "interface", "direction"},
+	)
+
+	switchPortEnabled = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      "switch_port",
+			Name:           "enabled",
+			Help:           "1 if the switch port of the device of a node is enabled, 0 if it is disabled or unknown.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"node", "port", "private"},
+	)
+
+	switchPortMTU = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      "switch_port",
+			Name:           "mtu",
+			Help:           "MTU of the switch port of the device of a node.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"node", "port", "private"},
+	)
+
+	controlPlaneVIPMovesTotal = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      "control_plane_vip",
+			Name:           "moves_total",
+			Help:           "Moves of the control-plane VIP to a node. Result is success or failed.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"result"},
+	)
+
+	controlPlaneVIPFailoverDuration = metrics.NewHistogram(
+		&metrics.HistogramOpts{
+			Namespace:      metricsNamespace,
+			Subsystem:      "control_plane_vip",
+			Name:           "failover_duration_seconds",
+			Help:           "Time from the start of the control-plane VIP controller in a new leader of the CCM until the verified move to the node.",
+			Buckets:        []float64{5, 10, 20, 30, 60, 120, 300, 600},
+			StabilityLevel: metrics.ALPHA,
+		},
+	)
+)
+
+var registerMetricsOnce sync.Once
+
+// registerMetrics registers all metrics of the Hivelocity controllers.
+func registerMetrics() {
+	registerMetricsOnce.Do(func() {
+		legacyregistry.MustRegister(
+			ipmiSensorReading,
+			ipmiSensorThreshold,
+			nodeMonthlyCost,
+			nodeMonthlyDiscount,
+			nodeBandwidthRate,
+			nodeBandwidthMonthBytes,
+			nodeBandwidthBytesTotal,
+			switchPortEnabled,
+			switchPortMTU,
+			controlPlaneVIPMovesTotal,
+			controlPlaneVIPFailoverDuration,
+		)
+	})
+}
+
+// nodeMetricVec is a metric vector with a "node" label.
+type nodeMetricVec interface {
+	IsCreated() bool
+	DeletePartialMatch(labels prometheus.Labels) int
+}
+
+// deleteSeriesOfDeletedNodes deletes the series of the nodes which were exported
+// by the last poll but do not exist any more. Returns the nodes of this poll.
+func deleteSeriesOfDeletedNodes(
+	exported map[string]bool,
+	nodes []*corev1.Node,
+	vecs ...nodeMetricVec,
+) map[string]bool {
+	current := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		current[node.Name] = true
+	}
+	for name := range exported {
+		if current[name] {
+			continue
+		}
+		for _, vec := range vecs {
+			if vec.IsCreated() {
+				vec.DeletePartialMatch(prometheus.Labels{"node": name})
+			}
+		}
+	}
+	return current
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func Test_deleteSeriesOfDeletedNodes(t *testing.T) {
+	t.Parallel()
+	registerMetrics()
+	ipmiSensorReading.WithLabelValues("series-kept", "CPU Temp", "Temperature", "degrees C").Set(40)
+	ipmiSensorReading.WithLabelValues("series-deleted", "CPU Temp", "Temperature", "degrees C").Set(50)
+	ipmiSensorReading.WithLabelValues("series-deleted", "FAN1", "Fan", "RPM").Set(3000)
+
+	exported := map[string]bool{"series-kept": true, "series-deleted": true}
+	nodes := []*corev1.Node{newNode("hivelocity://12345", "series-kept")}
+	require.Equal(t, map[string]bool{"series-kept": true},
+		deleteSeriesOfDeletedNodes(exported, nodes, ipmiSensorReading))
+
+	reading, err := testutil.GetGaugeMetricValue(
+		ipmiSensorReading.WithLabelValues("series-kept", "CPU Temp", "Temperature", "degrees C"))
+	require.NoError(t, err)
+	require.Equal(t, float64(40), reading)
+	require.Zero(t, ipmiSensorReading.DeletePartialMatch(map[string]string{"node": "series-deleted"}))
+}
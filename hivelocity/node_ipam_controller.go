@@ -0,0 +1,366 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+// nodeIPAMReleaseKey is the queue key which releases the subnets of deleted
+// nodes. Node names are never empty.
+const nodeIPAMReleaseKey = ""
+
+// nodeIPAMController allocates the pod CIDRs of the nodes from the IP
+// assignments of the account. Each node gets a subnet per IP family which is
+// routed to the address of the node. Larger assignments get split.
+//
+// The routing is the record of the allocation: after a restart the assignment
+// which is routed to the node is found again, so that a node never gets two
+// subnets of the same family. The subnets of deleted nodes get released by
+// clearing their routing.
+type nodeIPAMController struct {
+	client      client.Interface
+	kubeClient  kubernetes.Interface
+	nodeLister  corelisters.NodeLister
+	nodesSynced cache.InformerSynced
+	queue       workqueue.RateLimitingInterface
+	recorder    record.EventRecorder
+	config      nodeIPAMConfig
+
+	// reserved maps the IDs of the assignments which were routed to a node
+	// to the node name, until the listing of the assignments contains the
+	// next hop. It is only accessed by the single worker.
+	reserved map[int32]string
+}
+
+func startNodeIPAMController(
+	ctx context.Context,
+	initContext app.ControllerInitContext,
+	_ genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error) {
+	if len(c.config.nodeIPAM.pools) == 0 {
+		klog.Warningf("%s is empty, not starting %s", nodeIPAMPoolsENVVar, nodeIPAMControllerName)
+		return nil, false, nil
+	}
+	if completedConfig.ComponentConfig.KubeCloudShared.AllocateNodeCIDRs {
+		klog.Warningf("--allocate-node-cidrs is enabled, not starting %s", nodeIPAMControllerName)
+		return nil, false, nil
+	}
+
+	ctrl := newNodeIPAMController(
+		c.client,
+		completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName),
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		c.config.nodeIPAM,
+	)
+	go ctrl.Run(ctx)
+	return nil, true, nil
+}
+
+func newNodeIPAMController(
+	c client.Interface,
+	kubeClient kubernetes.Interface,
+	nodeInformer coreinformers.NodeInformer,
+	cfg nodeIPAMConfig,
+) *nodeIPAMController {
+	ctrl := &nodeIPAMController{
+		client:      c,
+		kubeClient:  kubeClient,
+		nodeLister:  nodeInformer.Lister(),
+		nodesSynced: nodeInformer.Informer().HasSynced,
+		queue:       workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), nodeIPAMControllerName),
+		recorder:    newEventRecorder(kubeClient, nodeIPAMControllerName),
+		config:      cfg,
+		reserved:    make(map[int32]string),
+	}
+
+	_, _ = nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: ctrl.enqueue,
+		UpdateFunc: func(_, newObj interface{}) {
+			ctrl.enqueue(newObj)
+		},
+		DeleteFunc: func(interface{}) {
+			ctrl.queue.Add(nodeIPAMReleaseKey)
+		},
+	})
+	return ctrl
+}
+
+func (c *nodeIPAMController) enqueue(obj interface{}) {
+	if node, ok := obj.(*corev1.Node); ok && len(node.Spec.PodCIDRs) == 0 {
+		c.queue.Add(node.Name)
+	}
+}
+
+// Run allocates pod CIDRs until ctx is done.
+func (c *nodeIPAMController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.Info("Starting Hivelocity node IPAM controller")
+	defer klog.Info("Shutting down Hivelocity node IPAM controller")
+
+	if !cache.WaitForNamedCacheSync(nodeIPAMControllerName, ctx.Done(), c.nodesSynced) {
+		return
+	}
+
+	go wait.UntilWithContext(ctx, c.runWorker, time.Second)
+	wait.UntilWithContext(ctx, c.enqueueAll, c.config.pollInterval)
+}
+
+func (c *nodeIPAMController) enqueueAll(context.Context) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("[nodeIPAMController] listing nodes failed: %v", err)
+		return
+	}
+	for _, node := range nodes {
+		c.enqueue(node)
+	}
+	// Nodes which were deleted while the controller was not running have no
+	// delete event.
+	c.queue.Add(nodeIPAMReleaseKey)
+}
+
+func (c *nodeIPAMController) runWorker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+	}
+}
+
+func (c *nodeIPAMController) processNextItem(ctx context.Context) bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	nodeName, ok := key.(string)
+	if !ok {
+		c.queue.Forget(key)
+		return true
+	}
+
+	if nodeName == nodeIPAMReleaseKey {
+		if err := c.releaseDeletedNodes(ctx); err != nil {
+			klog.Errorf("[nodeIPAMController] releasing subnets of deleted nodes failed: %v", err)
+			c.queue.AddRateLimited(key)
+			return true
+		}
+		c.queue.Forget(key)
+		return true
+	}
+
+	if err := c.reconcileNode(ctx, nodeName); err != nil {
+		klog.Errorf("[nodeIPAMController] node %q: %v", nodeName, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *nodeIPAMController) reconcileNode(ctx context.Context, nodeName string) error {
+	node, err := c.nodeLister.Get(nodeName)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] getting node failed: %w", err)
+	}
+	if len(node.Spec.PodCIDRs) > 0 {
+		return nil
+	}
+	if !isNodeInitialized(node) {
+		return nil
+	}
+
+	assignments, err := c.client.ListIPAssignments(ctx)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] ListIPAssignments() failed: %w", err)
+	}
+	c.releaseReservations(assignments)
+
+	cidrs := make([]string, 0, 2)
+	for _, ipv6 := range poolFamilies(c.config.pools) {
+		cidr, err := c.allocate(ctx, node, ipv6, assignments)
+		if err != nil {
+			c.recorder.Eventf(node, corev1.EventTypeWarning, "PodCIDRAllocationFailed",
+				"Allocating the pod CIDR failed: %v", err)
+			return fmt.Errorf("[reconcileNode] allocate() failed: %w", err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"podCIDR": cidrs[0], "podCIDRs": cidrs},
+	})
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] json.Marshal() failed: %w", err)
+	}
+	if _, err := c.kubeClient.CoreV1().Nodes().Patch(
+		ctx, node.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("[reconcileNode] patching pod CIDRs failed: %w", err)
+	}
+	c.recorder.Eventf(node, corev1.EventTypeNormal, "PodCIDRAllocated",
+		"Allocated pod CIDRs %s", strings.Join(cidrs, ","))
+	return nil
+}
+
+// allocate returns the subnet of the IP family which is routed to the node.
+// If there is none, a free assignment of the pools gets split to the prefix
+// length and routed to the node.
+func (c *nodeIPAMController) allocate(
+	ctx context.Context,
+	node *corev1.Node,
+	ipv6 bool,
+	assignments []hv.IpAssignment,
+) (string, error) {
+	nextHop, err := nodeAddress(node, ipv6)
+	if err != nil {
+		return "", fmt.Errorf("[allocate] nodeAddress() failed: %w", err)
+	}
+	prefixLength := c.config.ipv4PrefixLength
+	if ipv6 {
+		prefixLength = c.config.ipv6PrefixLength
+	}
+
+	for i := range assignments {
+		assignment := &assignments[i]
+		subnet, ok := poolSubnet(c.config.pools, assignment.Subnet, ipv6)
+		if !ok {
+			continue
+		}
+		ones, _ := subnet.Mask.Size()
+		if ones == prefixLength &&
+			(assignment.NextHopIp == nextHop || c.reserved[assignment.AssignmentId] == node.Name) {
+			// The subnet was allocated before.
+			return subnet.String(), nil
+		}
+	}
+
+	candidate, candidateNet, err := allocateFromPools(ctx, c.client, assignments, c.config.pools, ipv6, prefixLength,
+		func(assignment *hv.IpAssignment) bool {
+			return c.reserved[assignment.AssignmentId] == "" && nodeInFacility(node, assignment.FacilityCode)
+		})
+	if err != nil {
+		return "", fmt.Errorf("[allocate] allocateFromPools() failed: %w", err)
+	}
+
+	c.reserved[candidate.AssignmentId] = node.Name
+	task, err := c.client.SetIPAssignmentNextHop(ctx, candidate.AssignmentId, nextHop)
+	if err != nil {
+		delete(c.reserved, candidate.AssignmentId)
+		return "", fmt.Errorf("[allocate] SetIPAssignmentNextHop() failed: %w", err)
+	}
+	klog.Infof("Allocated %s for node %q, routed to %s. Network task %q",
+		candidateNet, node.Name, nextHop, task.TaskId)
+	return candidateNet.String(), nil
+}
+
+// releaseDeletedNodes clears the routing of the subnets of the pools whose next
+// hop is no address of a node, so that they can be allocated again.
+func (c *nodeIPAMController) releaseDeletedNodes(ctx context.Context) error {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("[releaseDeletedNodes] listing nodes failed: %w", err)
+	}
+	nodeAddresses := make(map[string]bool)
+	for _, node := range nodes {
+		for _, address := range node.Status.Addresses {
+			if ip := net.ParseIP(address.Address); ip != nil {
+				nodeAddresses[ip.String()] = true
+			}
+		}
+	}
+
+	assignments, err := c.client.ListIPAssignments(ctx)
+	if err != nil {
+		return fmt.Errorf("[releaseDeletedNodes] ListIPAssignments() failed: %w", err)
+	}
+	c.releaseReservations(assignments)
+
+	for _, assignment := range assignments {
+		nextHop := net.ParseIP(assignment.NextHopIp)
+		if nextHop == nil || nodeAddresses[nextHop.String()] || !c.isNodeSubnet(assignment.Subnet) {
+			continue
+		}
+		task, err := c.client.ClearIPAssignment(ctx, assignment.AssignmentId)
+		if err != nil {
+			return fmt.Errorf("[releaseDeletedNodes] ClearIPAssignment() failed: %w", err)
+		}
+		klog.Infof("Released %s of a deleted node, which was routed to %s. Network task %q",
+			assignment.Subnet, assignment.NextHopIp, task.TaskId)
+	}
+	return nil
+}
+
+// isNodeSubnet returns true if the subnet is in the pools and has the prefix
+// length of the pod CIDRs of its IP family.
+func (c *nodeIPAMController) isNodeSubnet(subnet string) bool {
+	for _, ipv6 := range poolFamilies(c.config.pools) {
+		prefixLength := c.config.ipv4PrefixLength
+		if ipv6 {
+			prefixLength = c.config.ipv6PrefixLength
+		}
+		if ipNet, ok := poolSubnet(c.config.pools, subnet, ipv6); ok {
+			ones, _ := ipNet.Mask.Size()
+			return ones == prefixLength
+		}
+	}
+	return false
+}
+
+// releaseReservations drops the reservations which are visible in the assignments.
+func (c *nodeIPAMController) releaseReservations(assignments []hv.IpAssignment) {
+	routed := make(map[int32]bool, len(assignments))
+	for _, assignment := range assignments {
+		routed[assignment.AssignmentId] = assignment.NextHopIp != ""
+	}
+	for id := range c.reserved {
+		if done, ok := routed[id]; !ok || done {
+			delete(c.reserved, id)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func mustParseCIDRs(t *testing.T, cidrs ...string) []*net.IPNet {
+	t.Helper()
+	var result []*net.IPNet
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		require.NoError(t, err)
+		result = append(result, ipNet)
+	}
+	return result
+}
+
+func newNodeIPAMTestController(t *testing.T, m *mocks.Interface, node *corev1.Node, pools ...string) *nodeIPAMController {
+	t.Helper()
+	return &nodeIPAMController{
+		client:     m,
+		kubeClient: fake.NewSimpleClientset(node),
+		nodeLister: newTestNodeLister(t, node),
+		recorder:   record.NewFakeRecorder(10),
+		config: nodeIPAMConfig{
+			pools:            mustParseCIDRs(t, pools...),
+			ipv4PrefixLength: 28,
+			ipv6PrefixLength: 64,
+		},
+		reserved: make(map[int32]string),
+	}
+}
+
+func newNodeIPAMTestNode() *corev1.Node {
+	node := newNode("hivelocity://12345", nodeName)
+	node.Labels = map[string]string{corev1.LabelTopologyZone: "LAX1"}
+	node.Status.Addresses = []corev1.NodeAddress{
+		{Type: corev1.NodeExternalIP, Address: "10.0.0.1"},
+		{Type: corev1.NodeInternalIP, Address: "2001:db8:ffff::1"},
+	}
+	return node
+}
+
+func requirePodCIDRs(t *testing.T, c *nodeIPAMController, want ...string) {
+	t.Helper()
+	node, err := c.kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, want, node.Spec.PodCIDRs)
+	require.Equal(t, want[0], node.Spec.PodCIDR)
+}
+
+func Test_nodeIPAMController_reconcileNode_split(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{
+		// Not in the pool.
+		{AssignmentId: 1, Subnet: "2001:db8:1::/64"},
+		// In use.
+		{AssignmentId: 2, Subnet: "2001:db8:0:10::/64", DeviceId: 99},
+		// Other facility.
+		{AssignmentId: 3, Subnet: "2001:db8:0:20::/64", FacilityCode: "NYC1"},
+		// Larger than the free /62, gets skipped.
+		{AssignmentId: 4, Subnet: "2001:db8:0:100::/56", FacilityCode: "LAX1"},
+		{AssignmentId: 5, Subnet: "2001:db8:0:30::/62", FacilityCode: "LAX1"},
+	}, nil)
+	m.On("SplitIPAssignment", mock.Anything, int32(5)).Return([]hv.IpAssignment{
+		{AssignmentId: 6, Subnet: "2001:db8:0:30::/63"},
+		{AssignmentId: 7, Subnet: "2001:db8:0:32::/63"},
+	}, nil)
+	m.On("SplitIPAssignment", mock.Anything, int32(6)).Return([]hv.IpAssignment{
+		{AssignmentId: 8, Subnet: "2001:db8:0:30::/64"},
+		{AssignmentId: 9, Subnet: "2001:db8:0:31::/64"},
+	}, nil)
+	m.On("SetIPAssignmentNextHop", mock.Anything, int32(8), "2001:db8:ffff::1").
+		Return(&hv.NetworkTaskDump{TaskId: "task-1"}, nil)
+
+	c := newNodeIPAMTestController(t, m, newNodeIPAMTestNode(), "2001:db8::/48")
+	require.NoError(t, c.reconcileNode(context.Background(), nodeName))
+	requirePodCIDRs(t, c, "2001:db8:0:30::/64")
+	require.Equal(t, map[int32]string{8: nodeName}, c.reserved)
+}
+
+func Test_nodeIPAMController_reconcileNode_restart(t *testing.T) {
+	t.Parallel()
+	// The CCM was restarted after routing the subnets, before patching the node.
+	m := mocks.NewInterface(t)
+	m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{
+		{AssignmentId: 1, Subnet: "192.0.2.0/28"},
+		{AssignmentId: 2, Subnet: "192.0.2.16/28", NextHopIp: "10.0.0.1"},
+		{AssignmentId: 3, Subnet: "2001:db8:0:1::/64", NextHopIp: "2001:db8:ffff::1"},
+	}, nil)
+
+	c := newNodeIPAMTestController(t, m, newNodeIPAMTestNode(), "192.0.2.0/24", "2001:db8::/48")
+	require.NoError(t, c.reconcileNode(context.Background(), nodeName))
+	requirePodCIDRs(t, c, "192.0.2.16/28", "2001:db8:0:1::/64")
+}
+
+func Test_nodeIPAMController_reconcileNode_reserved(t *testing.T) {
+	t.Parallel()
+	// The listing does not contain the next hop of the running network task yet.
+	m := mocks.NewInterface(t)
+	m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{
+		{AssignmentId: 1, Subnet: "192.0.2.0/28"},
+		{AssignmentId: 2, Subnet: "192.0.2.16/28"},
+	}, nil)
+	m.On("SetIPAssignmentNextHop", mock.Anything, int32(2), "10.0.0.1").
+		Return(&hv.NetworkTaskDump{TaskId: "task-1"}, nil)
+
+	c := newNodeIPAMTestController(t, m, newNodeIPAMTestNode(), "192.0.2.0/24")
+	c.reserved[1] = "other-node"
+	require.NoError(t, c.reconcileNode(context.Background(), nodeName))
+	requirePodCIDRs(t, c, "192.0.2.16/28")
+}
+
+func Test_nodeIPAMController_reconcileNode_noFreeSubnet(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{
+		{AssignmentId: 1, Subnet: "192.0.2.0/28", NextHopIp: "10.0.0.2"},
+	}, nil)
+
+	c := newNodeIPAMTestController(t, m, newNodeIPAMTestNode(), "192.0.2.0/24")
+	err := c.reconcileNode(context.Background(), nodeName)
+	require.ErrorIs(t, err, errNoFreeSubnet)
+	require.Contains(t, <-c.recorder.(*record.FakeRecorder).Events, "PodCIDRAllocationFailed")
+}
+
+func Test_nodeIPAMController_releaseDeletedNodes(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{
+		// Routed to the node.
+		{AssignmentId: 1, Subnet: "192.0.2.0/28", NextHopIp: "10.0.0.1"},
+		{AssignmentId: 2, Subnet: "2001:db8:0:1::/64", NextHopIp: "2001:db8:ffff:0::1"},
+		// Routed to a deleted node.
+		{AssignmentId: 3, Subnet: "192.0.2.16/28", NextHopIp: "10.0.0.2"},
+		// Not in the pools.
+		{AssignmentId: 4, Subnet: "198.51.100.0/28", NextHopIp: "10.0.0.2"},
+		// Not a pod CIDR.
+		{AssignmentId: 5, Subnet: "192.0.2.128/25", NextHopIp: "10.0.0.2"},
+		// Free.
+		{AssignmentId: 6, Subnet: "192.0.2.32/28"},
+	}, nil)
+	m.On("ClearIPAssignment", mock.Anything, int32(3)).Return(&hv.NetworkTaskDump{TaskId: "task-1"}, nil)
+
+	c := newNodeIPAMTestController(t, m, newNodeIPAMTestNode(), "192.0.2.0/24", "2001:db8::/48")
+	require.NoError(t, c.releaseDeletedNodes(context.Background()))
+}
+
+func Test_nodeIPAMController_releaseReservations(t *testing.T) {
+	t.Parallel()
+	c := &nodeIPAMController{reserved: map[int32]string{1: "a", 2: "b", 3: "c"}}
+	c.releaseReservations([]hv.IpAssignment{
+		{AssignmentId: 1},
+		{AssignmentId: 2, NextHopIp: "10.0.0.1"},
+	})
+	require.Equal(t, map[int32]string{1: "a"}, c.reserved)
+}
+
+func Test_poolFamilies(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, []bool{true, false},
+		poolFamilies(mustParseCIDRs(t, "2001:db8::/48", "192.0.2.0/24", "2001:db8:1::/48")))
+	require.Equal(t, []bool{false}, poolFamilies(mustParseCIDRs(t, "192.0.2.0/24", "198.51.100.0/24")))
+}
@@ -0,0 +1,355 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/pkg/hvutils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// nodeTagLabelsAnnotation contains the keys of the labels which were set from device tags.
+	// Labels which are not listed are never removed by the controller.
+	nodeTagLabelsAnnotation = "hivelocity.net/tag-labels"
+
+	// nodeTagTaintsAnnotation contains the taints ("key:Effect") which were set from device tags.
+	nodeTagTaintsAnnotation = "hivelocity.net/tag-taints"
+)
+
+// nodeTagsController applies the k8s-label/ and k8s-taint/ tags of the devices
+// to the nodes. New nodes get them by initNode, before the cloud node
+// controller removes the uninitialized taint, so that no pod gets scheduled
+// without the taints. All nodes are reconciled periodically.
+type nodeTagsController struct {
+	client       client.Interface
+	kubeClient   kubernetes.Interface
+	nodeLister   corelisters.NodeLister
+	nodesSynced  cache.InformerSynced
+	queue        workqueue.RateLimitingInterface
+	recorder     record.EventRecorder
+	pollInterval time.Duration
+
+	// invalidTags maps the node names to the invalid tags of their device
+	// which were reported last, so that the events are only emitted when the
+	// tags change.
+	invalidTagsMu sync.Mutex
+	invalidTags   map[string]string
+}
+
+func startNodeTagsController(
+	ctx context.Context,
+	initContext app.ControllerInitContext,
+	_ genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error) {
+	ctrl := newNodeTagsController(
+		c.client,
+		completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName),
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		c.config.nodeTags.pollInterval,
+	)
+	// The informers are started after all controllers, so the hook is set
+	// before the first node gets initialized.
+	c.instancesV2.initNode = ctrl.initNode
+	go ctrl.Run(ctx)
+	return nil, true, nil
+}
+
+func newNodeTagsController(
+	c client.Interface,
+	kubeClient kubernetes.Interface,
+	nodeInformer coreinformers.NodeInformer,
+	pollInterval time.Duration,
+) *nodeTagsController {
+	ctrl := &nodeTagsController{
+		client:       c,
+		kubeClient:   kubeClient,
+		nodeLister:   nodeInformer.Lister(),
+		nodesSynced:  nodeInformer.Informer().HasSynced,
+		queue:        workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), nodeTagsControllerName),
+		recorder:     newEventRecorder(kubeClient, nodeTagsControllerName),
+		pollInterval: pollInterval,
+		invalidTags:  make(map[string]string),
+	}
+
+	_, _ = nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: ctrl.enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// The provider ID gets set when the node gets initialized.
+			oldNode, ok := oldObj.(*corev1.Node)
+			newNode, ok2 := newObj.(*corev1.Node)
+			if ok && ok2 && oldNode.Spec.ProviderID != newNode.Spec.ProviderID {
+				ctrl.enqueue(newObj)
+			}
+		},
+	})
+	return ctrl
+}
+
+func (c *nodeTagsController) enqueue(obj interface{}) {
+	if node, ok := obj.(*corev1.Node); ok {
+		c.queue.Add(node.Name)
+	}
+}
+
+// Run applies the device tags until ctx is done.
+func (c *nodeTagsController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+	defer c.queue.ShutDown()
+
+	klog.Info("Starting Hivelocity node tags controller")
+	defer klog.Info("Shutting down Hivelocity node tags controller")
+
+	if !cache.WaitForNamedCacheSync(nodeTagsControllerName, ctx.Done(), c.nodesSynced) {
+		return
+	}
+
+	go wait.UntilWithContext(ctx, c.runWorker, time.Second)
+	wait.UntilWithContext(ctx, c.enqueueAll, c.pollInterval)
+}
+
+func (c *nodeTagsController) enqueueAll(context.Context) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("[nodeTagsController] listing nodes failed: %v", err)
+		return
+	}
+	for _, node := range nodes {
+		c.queue.Add(node.Name)
+	}
+}
+
+func (c *nodeTagsController) runWorker(ctx context.Context) {
+	for c.processNextItem(ctx) {
+	}
+}
+
+func (c *nodeTagsController) processNextItem(ctx context.Context) bool {
+	key, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(key)
+
+	nodeName, ok := key.(string)
+	if !ok {
+		c.queue.Forget(key)
+		return true
+	}
+
+	if err := c.reconcileNode(ctx, nodeName); err != nil {
+		klog.Errorf("[nodeTagsController] node %q: %v", nodeName, err)
+		c.queue.AddRateLimited(key)
+		return true
+	}
+	c.queue.Forget(key)
+	return true
+}
+
+func (c *nodeTagsController) reconcileNode(ctx context.Context, nodeName string) error {
+	node, err := c.nodeLister.Get(nodeName)
+	if apierrors.IsNotFound(err) {
+		c.invalidTagsMu.Lock()
+		delete(c.invalidTags, nodeName)
+		c.invalidTagsMu.Unlock()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] getting node failed: %w", err)
+	}
+	if !isNodeInitialized(node) {
+		// The node gets the tags by initNode.
+		return nil
+	}
+
+	deviceID, err := getHivelocityDeviceIDFromNode(node)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] getHivelocityDeviceIDFromNode() failed: %w", err)
+	}
+	device, err := c.client.GetBareMetalDevice(ctx, deviceID)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] GetBareMetalDevice() failed. deviceID %d: %w", deviceID, err)
+	}
+	if err := c.applyDeviceTags(ctx, node, device); err != nil {
+		return fmt.Errorf("[reconcileNode] applyDeviceTags() failed: %w", err)
+	}
+	return nil
+}
+
+// initNode applies the tags of the device to a node which is initialized by
+// the cloud node controller. It is called by InstanceMetadata.
+func (c *nodeTagsController) initNode(ctx context.Context, node *corev1.Node, device *hv.BareMetalDevice) error {
+	if err := c.applyDeviceTags(ctx, node, device); err != nil {
+		return fmt.Errorf("[initNode] applyDeviceTags() failed: %w", err)
+	}
+	return nil
+}
+
+// applyDeviceTags updates the node with the labels and taints of the tags of the device.
+func (c *nodeTagsController) applyDeviceTags(ctx context.Context, node *corev1.Node, device *hv.BareMetalDevice) error {
+	tagLabels, tagTaints, errs := hvutils.GetNodeLabelsAndTaintsFromTags(device.Tags)
+	c.reportInvalidTags(node, device.DeviceId, errs)
+
+	updated := applyTagLabelsAndTaints(node, tagLabels, tagTaints)
+	if equality.Semantic.DeepEqual(node, updated) {
+		return nil
+	}
+	if _, err := c.kubeClient.CoreV1().Nodes().Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("[applyDeviceTags] updating node failed: %w", err)
+	}
+	return nil
+}
+
+// reportInvalidTags emits an event per invalid tag, unless the same tags were reported before.
+func (c *nodeTagsController) reportInvalidTags(node *corev1.Node, deviceID int32, errs []error) {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	reported := strings.Join(messages, "\n")
+
+	c.invalidTagsMu.Lock()
+	defer c.invalidTagsMu.Unlock()
+	if c.invalidTags[node.Name] == reported {
+		return
+	}
+	if reported == "" {
+		delete(c.invalidTags, node.Name)
+		return
+	}
+	c.invalidTags[node.Name] = reported
+	for _, err := range errs {
+		c.recorder.Eventf(node, corev1.EventTypeWarning, "InvalidDeviceTag",
+			"Ignoring tag of device %d: %v", deviceID, err)
+	}
+}
+
+// applyTagLabelsAndTaints returns a copy of the node with the labels and taints
+// of the device tags. Labels and taints which were set from tags before, but
+// whose tags are gone, get removed. Others are not touched.
+func applyTagLabelsAndTaints(node *corev1.Node, tagLabels map[string]string, tagTaints []corev1.Taint) *corev1.Node {
+	updated := node.DeepCopy()
+
+	previousLabels := splitAnnotation(node.Annotations[nodeTagLabelsAnnotation])
+	for _, key := range previousLabels {
+		if _, ok := tagLabels[key]; !ok {
+			delete(updated.Labels, key)
+		}
+	}
+	if len(tagLabels) > 0 && updated.Labels == nil {
+		updated.Labels = make(map[string]string, len(tagLabels))
+	}
+	labelKeys := make([]string, 0, len(tagLabels))
+	for key, value := range tagLabels {
+		updated.Labels[key] = value
+		labelKeys = append(labelKeys, key)
+	}
+
+	desiredTaints := make(map[string]corev1.Taint, len(tagTaints))
+	for _, taint := range tagTaints {
+		desiredTaints[taintKeyEffect(taint)] = taint
+	}
+	previousTaints := sets.NewString(splitAnnotation(node.Annotations[nodeTagTaintsAnnotation])...)
+
+	// Existing taints keep their position, so that nothing changes if the tags did not change.
+	taints := make([]corev1.Taint, 0, len(node.Spec.Taints)+len(tagTaints))
+	applied := sets.NewString()
+	for _, taint := range node.Spec.Taints {
+		keyEffect := taintKeyEffect(taint)
+		if desired, ok := desiredTaints[keyEffect]; ok {
+			if applied.Has(keyEffect) {
+				continue
+			}
+			if taint.Value != desired.Value {
+				taint = desired
+			}
+			taints = append(taints, taint)
+			applied.Insert(keyEffect)
+			continue
+		}
+		if previousTaints.Has(keyEffect) {
+			// The tag was removed.
+			continue
+		}
+		taints = append(taints, taint)
+	}
+	for _, taint := range tagTaints {
+		if !applied.Has(taintKeyEffect(taint)) {
+			taints = append(taints, taint)
+		}
+	}
+	if len(taints) == 0 {
+		taints = nil
+	}
+	updated.Spec.Taints = taints
+
+	setAnnotation(updated, nodeTagLabelsAnnotation, labelKeys)
+	setAnnotation(updated, nodeTagTaintsAnnotation, sets.StringKeySet(desiredTaints).List())
+	return updated
+}
+
+func taintKeyEffect(taint corev1.Taint) string {
+	return taint.Key + ":" + string(taint.Effect)
+}
+
+func splitAnnotation(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// setAnnotation stores the sorted values comma separated. The annotation gets removed if values is empty.
+func setAnnotation(node *corev1.Node, key string, values []string) {
+	if len(values) == 0 {
+		delete(node.Annotations, key)
+		return
+	}
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+	sort.Strings(values)
+	node.Annotations[key] = strings.Join(values, ",")
+}
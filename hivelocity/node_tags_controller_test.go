@@ -0,0 +1,172 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func Test_applyTagLabelsAndTaints(t *testing.T) {
+	t.Parallel()
+	dedicated := corev1.Taint{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoSchedule}
+	foreign := corev1.Taint{Key: "foreign", Effect: corev1.TaintEffectNoExecute}
+
+	tests := []struct {
+		name            string
+		node            *corev1.Node
+		tagLabels       map[string]string
+		tagTaints       []corev1.Taint
+		wantLabels      map[string]string
+		wantTaints      []corev1.Taint
+		wantAnnotations map[string]string
+	}{
+		{
+			name:       "add labels and taints",
+			node:       &corev1.Node{},
+			tagLabels:  map[string]string{"gpu": "true"},
+			tagTaints:  []corev1.Taint{dedicated},
+			wantLabels: map[string]string{"gpu": "true"},
+			wantTaints: []corev1.Taint{dedicated},
+			wantAnnotations: map[string]string{
+				nodeTagLabelsAnnotation: "gpu",
+				nodeTagTaintsAnnotation: "dedicated:NoSchedule",
+			},
+		},
+		{
+			name: "remove labels and taints of removed tags only",
+			node: &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"gpu": "true", "other": "x"},
+					Annotations: map[string]string{
+						nodeTagLabelsAnnotation: "gpu",
+						nodeTagTaintsAnnotation: "dedicated:NoSchedule",
+					},
+				},
+				Spec: corev1.NodeSpec{Taints: []corev1.Taint{foreign, dedicated}},
+			},
+			wantLabels:      map[string]string{"other": "x"},
+			wantTaints:      []corev1.Taint{foreign},
+			wantAnnotations: map[string]string{},
+		},
+		{
+			name: "update taint value in place",
+			node: &corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{nodeTagTaintsAnnotation: "dedicated:NoSchedule"},
+				},
+				Spec: corev1.NodeSpec{Taints: []corev1.Taint{
+					{Key: "dedicated", Value: "web", Effect: corev1.TaintEffectNoSchedule}, foreign,
+				}},
+			},
+			tagTaints:       []corev1.Taint{dedicated},
+			wantTaints:      []corev1.Taint{dedicated, foreign},
+			wantAnnotations: map[string]string{nodeTagTaintsAnnotation: "dedicated:NoSchedule"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := applyTagLabelsAndTaints(tt.node, tt.tagLabels, tt.tagTaints)
+			require.Equal(t, len(tt.wantLabels), len(got.Labels))
+			for key, value := range tt.wantLabels {
+				require.Equal(t, value, got.Labels[key])
+			}
+			require.Equal(t, tt.wantTaints, got.Spec.Taints)
+			require.Equal(t, len(tt.wantAnnotations), len(got.Annotations))
+			for key, value := range tt.wantAnnotations {
+				require.Equal(t, value, got.Annotations[key])
+			}
+
+			// A second pass must not change anything.
+			require.Equal(t, got, applyTagLabelsAndTaints(got, tt.tagLabels, tt.tagTaints))
+		})
+	}
+}
+
+func Test_nodeTags_reconcileNode(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("GetBareMetalDevice", mock.Anything, int32(dummyDeviceID)).Return(&hv.BareMetalDevice{
+		DeviceId: dummyDeviceID,
+		Tags:     []string{"caphv-device-type=abc", "k8s-label/gpu=true", "k8s-taint/bad"},
+	}, nil)
+
+	node := newNode("hivelocity://12345", nodeName)
+	recorder := record.NewFakeRecorder(10)
+
+	c := &nodeTagsController{
+		client:      m,
+		kubeClient:  fake.NewSimpleClientset(node),
+		nodeLister:  newTestNodeLister(t, node),
+		recorder:    recorder,
+		invalidTags: make(map[string]string),
+	}
+	require.NoError(t, c.reconcileNode(context.Background(), nodeName))
+
+	updatedNode, err := c.kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "true", updatedNode.Labels["gpu"])
+	require.Len(t, recorder.Events, 1)
+
+	// The invalid tag is only reported again when it changes.
+	require.NoError(t, c.reconcileNode(context.Background(), nodeName))
+	require.Len(t, recorder.Events, 1)
+}
+
+func Test_nodeTags_initNode(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ListDevices", mock.Anything).Return([]hv.BareMetalDevice{{
+		DeviceId: dummyDeviceID,
+		Tags:     []string{"caphv-device-type=abc", "caphv-machine-name=" + nodeName, "k8s-taint/dedicated=db:NoSchedule"},
+	}}, nil)
+
+	uninitialized := corev1.Taint{Key: "node.cloudprovider.kubernetes.io/uninitialized", Value: "true", Effect: corev1.TaintEffectNoSchedule}
+	node := newNode("", nodeName)
+	node.Spec.Taints = []corev1.Taint{uninitialized}
+
+	c := &nodeTagsController{
+		client:      m,
+		kubeClient:  fake.NewSimpleClientset(node),
+		recorder:    record.NewFakeRecorder(10),
+		invalidTags: make(map[string]string),
+	}
+	i2 := newHVInstanceV2(m)
+	i2.initNode = c.initNode
+
+	// The taints get added while the node is not initialized.
+	_, err := i2.InstanceMetadata(context.Background(), node)
+	require.NoError(t, err)
+
+	updatedNode, err := c.kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, []corev1.Taint{
+		uninitialized,
+		{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoSchedule},
+	}, updatedNode.Spec.Taints)
+}
@@ -0,0 +1,252 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/pkg/hvutils"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/pkg/networktask"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+// privateVLANController keeps the private ports of the devices of all nodes in
+// a VLAN. The VLAN gets updated by one network task at a time: no update is
+// sent until the previous task has finished. The task is tracked on the nodes
+// which get added.
+type privateVLANController struct {
+	client             client.Interface
+	kubeClient         kubernetes.Interface
+	nodeLister         corelisters.NodeLister
+	nodesSynced        cache.InformerSynced
+	recorder           record.EventRecorder
+	pollInterval       time.Duration
+	vlanID             int32
+	removeDeletedNodes bool
+	clusterName        string
+	tracker            *networktask.Tracker
+
+	// facilityMismatch are the names of the nodes which were not in the
+	// facility of the VLAN in the last sync, so that they are reported once.
+	facilityMismatch sets.Set[string]
+}
+
+func startPrivateVLANController(
+	ctx context.Context,
+	initContext app.ControllerInitContext,
+	_ genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error) {
+	if c.config.privateVLAN.vlanID == 0 {
+		klog.Warningf("%s is not set, not starting %s", privateVLANIDENVVar, privateVLANControllerName)
+		return nil, false, nil
+	}
+
+	ctrl := newPrivateVLANController(
+		c.client,
+		completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName),
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		c.config.privateVLAN,
+		c.clusterNameOr(completedConfig.ComponentConfig.KubeCloudShared.ClusterName),
+	)
+	go ctrl.Run(ctx)
+	return nil, true, nil
+}
+
+func newPrivateVLANController(
+	c client.Interface,
+	kubeClient kubernetes.Interface,
+	nodeInformer coreinformers.NodeInformer,
+	cfg privateVLANConfig,
+	clusterName string,
+) *privateVLANController {
+	ctrl := &privateVLANController{
+		client:             c,
+		kubeClient:         kubeClient,
+		nodeLister:         nodeInformer.Lister(),
+		nodesSynced:        nodeInformer.Informer().HasSynced,
+		recorder:           newEventRecorder(kubeClient, privateVLANControllerName),
+		pollInterval:       cfg.pollInterval,
+		vlanID:             int32(cfg.vlanID),
+		removeDeletedNodes: cfg.removeDeletedNodes,
+		clusterName:        clusterName,
+		facilityMismatch:   sets.New[string](),
+	}
+	ctrl.tracker = networktask.New(networktask.Options{
+		Controller: privateVLANControllerName,
+		Client:     c,
+		Recorder:   ctrl.recorder,
+		Patch:      nodeAnnotationPatcher(kubeClient),
+	})
+	return ctrl
+}
+
+// Run syncs the VLAN until ctx is done.
+func (c *privateVLANController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.Info("Starting Hivelocity private VLAN controller")
+	defer klog.Info("Shutting down Hivelocity private VLAN controller")
+
+	if !cache.WaitForNamedCacheSync(privateVLANControllerName, ctx.Done(), c.nodesSynced) {
+		return
+	}
+
+	go c.tracker.Run(ctx)
+	wait.UntilWithContext(ctx, c.reconcile, c.pollInterval)
+}
+
+func (c *privateVLANController) reconcile(ctx context.Context) {
+	if err := c.sync(ctx); err != nil {
+		klog.Errorf("[privateVLANController] vlan %d: %v", c.vlanID, err)
+	}
+}
+
+func (c *privateVLANController) sync(ctx context.Context) error {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("[sync] listing nodes failed: %w", err)
+	}
+	objs := make([]networktask.Object, 0, len(nodes))
+	for _, node := range nodes {
+		objs = append(objs, node)
+	}
+	c.tracker.Resume(objs...)
+	if c.tracker.Len() > 0 {
+		// Wait for the running update.
+		return nil
+	}
+
+	vlan, err := c.client.GetVLAN(ctx, c.vlanID)
+	if err != nil {
+		return fmt.Errorf("[sync] GetVLAN() failed: %w", err)
+	}
+	ports, err := c.client.ListDevicePorts(ctx)
+	if err != nil {
+		return fmt.Errorf("[sync] ListDevicePorts() failed: %w", err)
+	}
+	privatePorts := make(map[int32][]int32)
+	for _, port := range ports {
+		if port.Private {
+			privatePorts[port.DeviceId] = append(privatePorts[port.DeviceId], port.PortId)
+		}
+	}
+
+	current := sets.New[int32](vlan.PortIds...)
+	desired := sets.New[int32](vlan.PortIds...)
+	nodeDevices := sets.New[int32]()
+	nodeNames := sets.New[string]()
+	var added []networktask.Object
+	facilityMismatch := sets.New[string]()
+	for _, node := range nodes {
+		nodeNames.Insert(node.Name)
+		if !isNodeInitialized(node) {
+			continue
+		}
+		deviceID, err := getHivelocityDeviceIDFromNode(node)
+		if err != nil {
+			klog.Errorf("[privateVLANController] node %q: %v", node.Name, err)
+			continue
+		}
+		nodeDevices.Insert(deviceID)
+
+		missing := sets.New[int32](privatePorts[deviceID]...).Difference(current)
+		if missing.Len() == 0 {
+			continue
+		}
+		if !nodeInFacility(node, vlan.FacilityCode) {
+			facilityMismatch.Insert(node.Name)
+			if !c.facilityMismatch.Has(node.Name) {
+				c.recorder.Eventf(node, corev1.EventTypeWarning, "PrivateVLANFacilityMismatch",
+					"VLAN %d is in facility %s, the node is not", c.vlanID, vlan.FacilityCode)
+			}
+			continue
+		}
+		desired = desired.Union(missing)
+		added = append(added, node)
+	}
+	c.facilityMismatch = facilityMismatch
+
+	if c.removeDeletedNodes {
+		removed, err := c.deletedNodePorts(ctx, nodeDevices, nodeNames, privatePorts)
+		if err != nil {
+			return fmt.Errorf("[sync] deletedNodePorts() failed: %w", err)
+		}
+		desired = desired.Difference(removed)
+	}
+
+	if desired.Equal(current) {
+		return nil
+	}
+
+	portIDs := sets.List(desired)
+	task, err := c.client.UpdateVLAN(ctx, c.vlanID, hv.VlanUpdate{PortIds: portIDs, IpIds: vlan.IpIds})
+	if err != nil {
+		return fmt.Errorf("[sync] UpdateVLAN() failed: %w", err)
+	}
+	klog.Infof("Updating ports of VLAN %d from %v to %v. Network task %q",
+		c.vlanID, sets.List(current), portIDs, task.TaskId)
+	if err := c.tracker.Track(ctx, task.TaskId, added...); err != nil {
+		return fmt.Errorf("[sync] Track() failed: %w", err)
+	}
+	return nil
+}
+
+// deletedNodePorts returns the private ports of the devices of the cluster
+// which have no node. Devices of nodes which are not initialized yet are
+// matched by the machine name, like the cloud node controller does.
+func (c *privateVLANController) deletedNodePorts(
+	ctx context.Context,
+	nodeDevices sets.Set[int32],
+	nodeNames sets.Set[string],
+	privatePorts map[int32][]int32,
+) (sets.Set[int32], error) {
+	devices, err := c.client.ListDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[deletedNodePorts] ListDevices() failed: %w", err)
+	}
+	removed := sets.New[int32]()
+	for _, device := range devices {
+		if nodeDevices.Has(device.DeviceId) || !hvutils.HasClusterNameTag(device.Tags, c.clusterName) {
+			continue
+		}
+		if name, err := hvutils.GetMachineNameFromTags(device.Tags); err == nil && nodeNames.Has(name) {
+			continue
+		}
+		removed.Insert(privatePorts[device.DeviceId]...)
+	}
+	return removed, nil
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/pkg/networktask"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func newPrivateVLANTestController(t *testing.T, m *mocks.Interface, nodes ...*corev1.Node) *privateVLANController {
+	t.Helper()
+	kubeClient := fake.NewSimpleClientset()
+	for _, node := range nodes {
+		require.NoError(t, kubeClient.Tracker().Add(node))
+	}
+	c := &privateVLANController{
+		client:      m,
+		kubeClient:  kubeClient,
+		nodeLister:  newTestNodeLister(t, nodes...),
+		recorder:    record.NewFakeRecorder(10),
+		vlanID:      7,
+		clusterName: "prod",
+	}
+	c.tracker = networktask.New(networktask.Options{
+		Controller: privateVLANControllerName,
+		Client:     m,
+		Recorder:   c.recorder,
+		Patch:      nodeAnnotationPatcher(kubeClient),
+	})
+	return c
+}
+
+var privateVLANTestPorts = []hv.DevicePort{
+	{PortId: 100, DeviceId: 12345, Private: false},
+	{PortId: 101, DeviceId: 12345, Private: true},
+	{PortId: 201, DeviceId: 2, Private: true},
+	{PortId: 301, DeviceId: 3, Private: true},
+	{PortId: 401, DeviceId: 4, Private: true},
+}
+
+func Test_privateVLANController_sync(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name               string
+		vlanPorts          []int32
+		removeDeletedNodes bool
+		wantPorts          []int32
+	}{
+		{
+			name:      "adds the private port of the node",
+			vlanPorts: []int32{201, 301},
+			wantPorts: []int32{101, 201, 301},
+		},
+		{
+			name:      "in sync",
+			vlanPorts: []int32{101, 201},
+		},
+		{
+			name:               "removes the ports of deleted nodes of the cluster",
+			vlanPorts:          []int32{101, 201, 301},
+			removeDeletedNodes: true,
+			wantPorts:          []int32{101, 301},
+		},
+		{
+			name:               "keeps the ports of nodes which are not initialized",
+			vlanPorts:          []int32{101, 201, 301, 401},
+			removeDeletedNodes: true,
+			wantPorts:          []int32{101, 301, 401},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := mocks.NewInterface(t)
+			m.On("GetVLAN", mock.Anything, int32(7)).Return(&hv.Vlan{VlanId: 7, PortIds: tt.vlanPorts, IpIds: []int32{9}}, nil)
+			m.On("ListDevicePorts", mock.Anything).Return(privateVLANTestPorts, nil)
+			if tt.removeDeletedNodes {
+				m.On("ListDevices", mock.Anything).Return([]hv.BareMetalDevice{
+					{DeviceId: 12345, Tags: []string{"caphv-cluster-name=prod"}},
+					{DeviceId: 2, Tags: []string{"caphv-cluster-name=prod"}},
+					{DeviceId: 3, Tags: []string{"caphv-cluster-name=other"}},
+					{DeviceId: 4, Tags: []string{"caphv-cluster-name=prod", "caphv-machine-name=new-node"}},
+				}, nil)
+			}
+			if tt.wantPorts != nil {
+				m.On("UpdateVLAN", mock.Anything, int32(7), hv.VlanUpdate{PortIds: tt.wantPorts, IpIds: []int32{9}}).
+					Return(&hv.NetworkTaskDump{TaskId: "task-1"}, nil)
+			}
+
+			c := newPrivateVLANTestController(t, m, newNode("hivelocity://12345", nodeName), newNode("", "new-node"))
+			c.removeDeletedNodes = tt.removeDeletedNodes
+			require.NoError(t, c.sync(context.Background()))
+			if tt.wantPorts == nil {
+				require.Zero(t, c.tracker.Len())
+				return
+			}
+			require.Equal(t, 1, c.tracker.Len())
+		})
+	}
+}
+
+func Test_privateVLANController_sync_pending(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("GetVLAN", mock.Anything, int32(7)).Return(&hv.Vlan{VlanId: 7}, nil).Once()
+	m.On("ListDevicePorts", mock.Anything).Return(privateVLANTestPorts, nil).Once()
+	m.On("UpdateVLAN", mock.Anything, int32(7), hv.VlanUpdate{PortIds: []int32{101}}).
+		Return(&hv.NetworkTaskDump{TaskId: "task-1"}, nil)
+
+	c := newPrivateVLANTestController(t, m, newNode("hivelocity://12345", nodeName))
+	ctx := context.Background()
+	require.NoError(t, c.sync(ctx))
+
+	// The task is persisted on the added node.
+	node, err := c.kubeClient.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "task-1", node.Annotations[networktask.AnnotationKey])
+
+	// A new leader resumes the task from the annotation and does not update the VLAN.
+	resumed := newPrivateVLANTestController(t, m, node)
+	require.NoError(t, resumed.sync(ctx))
+	require.Equal(t, 1, resumed.tracker.Len())
+
+	m.On("GetNetworkTask", mock.Anything, "task-1").
+		Return(&hv.NetworkTaskDump{TaskId: "task-1", Result: networktask.ResultSuccess}, nil)
+	resumed.tracker.Poll(ctx)
+	require.Zero(t, resumed.tracker.Len())
+
+	events := resumed.recorder.(*record.FakeRecorder).Events
+	require.Contains(t, <-events, "NetworkTaskSucceeded")
+	require.Empty(t, events)
+}
+
+func Test_privateVLANController_sync_facilityMismatch(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("GetVLAN", mock.Anything, int32(7)).Return(&hv.Vlan{VlanId: 7, FacilityCode: "NYC1"}, nil)
+	m.On("ListDevicePorts", mock.Anything).Return(privateVLANTestPorts, nil)
+
+	node := newNode("hivelocity://12345", nodeName)
+	node.Labels = map[string]string{corev1.LabelTopologyZone: "LAX1"}
+	c := newPrivateVLANTestController(t, m, node)
+	require.NoError(t, c.sync(context.Background()))
+	require.Zero(t, c.tracker.Len())
+	events := c.recorder.(*record.FakeRecorder).Events
+	require.Contains(t, <-events, "PrivateVLANFacilityMismatch")
+
+	// The node is reported once.
+	require.NoError(t, c.sync(context.Background()))
+	require.Empty(t, events)
+}
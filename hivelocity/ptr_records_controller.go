@@ -0,0 +1,319 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+// ptrRecordsConfigMapName is the ConfigMap in kube-system which contains the
+// previous names of the records, so that they can be restored after the node
+// was deleted. The keys are the IDs of the records.
+const ptrRecordsConfigMapName = "hivelocity-ptr-records"
+
+// ptrRecordState is the value of a record in the ConfigMap.
+type ptrRecordState struct {
+	Address      string `json:"address"`
+	Node         string `json:"node"`
+	PreviousName string `json:"previousName"`
+}
+
+// ptrRecordsController sets the reverse DNS records of the external addresses
+// of all nodes to a name from a template. Changes of the records by others
+// get reverted. When the node is removed, the previous name is restored.
+type ptrRecordsController struct {
+	client       client.Interface
+	kubeClient   kubernetes.Interface
+	nodeLister   corelisters.NodeLister
+	nodesSynced  cache.InformerSynced
+	recorder     record.EventRecorder
+	pollInterval time.Duration
+	template     string
+	clusterName  string
+}
+
+func startPTRRecordsController(
+	ctx context.Context,
+	initContext app.ControllerInitContext,
+	_ genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error) {
+	if c.config.ptrRecords.template == "" {
+		klog.Warningf("%s is empty, not starting %s", ptrRecordsTemplateENVVar, ptrRecordsControllerName)
+		return nil, false, nil
+	}
+
+	ctrl := newPTRRecordsController(
+		c.client,
+		completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName),
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		c.config.ptrRecords,
+		c.clusterNameOr(completedConfig.ComponentConfig.KubeCloudShared.ClusterName),
+	)
+	go ctrl.Run(ctx)
+	return nil, true, nil
+}
+
+func newPTRRecordsController(
+	c client.Interface,
+	kubeClient kubernetes.Interface,
+	nodeInformer coreinformers.NodeInformer,
+	cfg ptrRecordsConfig,
+	clusterName string,
+) *ptrRecordsController {
+	return &ptrRecordsController{
+		client:       c,
+		kubeClient:   kubeClient,
+		nodeLister:   nodeInformer.Lister(),
+		nodesSynced:  nodeInformer.Informer().HasSynced,
+		recorder:     newEventRecorder(kubeClient, ptrRecordsControllerName),
+		pollInterval: cfg.pollInterval,
+		template:     cfg.template,
+		clusterName:  clusterName,
+	}
+}
+
+// Run syncs the records until ctx is done.
+func (c *ptrRecordsController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.Info("Starting Hivelocity PTR records controller")
+	defer klog.Info("Shutting down Hivelocity PTR records controller")
+
+	if !cache.WaitForNamedCacheSync(ptrRecordsControllerName, ctx.Done(), c.nodesSynced) {
+		return
+	}
+
+	wait.UntilWithContext(ctx, c.reconcile, c.pollInterval)
+}
+
+func (c *ptrRecordsController) reconcile(ctx context.Context) {
+	if err := c.sync(ctx); err != nil {
+		klog.Errorf("[ptrRecordsController] %v", err)
+	}
+}
+
+func (c *ptrRecordsController) sync(ctx context.Context) error {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("[sync] listing nodes failed: %w", err)
+	}
+	nodesByAddress := make(map[string]*corev1.Node)
+	for _, node := range nodes {
+		if !isNodeInitialized(node) {
+			continue
+		}
+		for _, address := range node.Status.Addresses {
+			if ip := net.ParseIP(address.Address); address.Type == corev1.NodeExternalIP && ip != nil {
+				nodesByAddress[ip.String()] = node
+			}
+		}
+	}
+
+	records, err := c.client.ListPTRRecords(ctx)
+	if err != nil {
+		return fmt.Errorf("[sync] ListPTRRecords() failed: %w", err)
+	}
+	configMap, states, err := c.loadStates(ctx)
+	if err != nil {
+		return fmt.Errorf("[sync] loadStates() failed: %w", err)
+	}
+
+	type update struct {
+		record hv.PtrRecordReturn
+		node   *corev1.Node
+		name   string
+	}
+	var updates []update
+	for _, record := range records {
+		ip := net.ParseIP(record.Address)
+		if ip == nil {
+			continue
+		}
+		node, ok := nodesByAddress[ip.String()]
+		if !ok {
+			continue
+		}
+		name := ptrRecordName(c.template, node.Name, c.clusterName)
+		if sameDNSName(record.Name, name) {
+			continue
+		}
+		key := strconv.Itoa(int(record.Id))
+		if state, ok := states[key]; !ok || state.Node != node.Name {
+			previousName := record.Name
+			if ok {
+				// The address moved to another node, keep the name from before the first node.
+				previousName = state.PreviousName
+			}
+			states[key] = ptrRecordState{Address: ip.String(), Node: node.Name, PreviousName: previousName}
+		}
+		updates = append(updates, update{record: record, node: node, name: name})
+	}
+
+	// The previous names are stored before the records get changed.
+	if len(updates) > 0 {
+		if configMap, err = c.saveStates(ctx, configMap, states); err != nil {
+			return fmt.Errorf("[sync] saveStates() failed: %w", err)
+		}
+	}
+	for _, u := range updates {
+		if err := c.client.UpdatePTRRecord(ctx, u.record.Id, hv.PtrRecordUpdate{Name: u.name, Ttl: u.record.Ttl}); err != nil {
+			return fmt.Errorf("[sync] UpdatePTRRecord() failed: %w", err)
+		}
+		klog.Infof("Changed PTR record of %s from %q to %q", u.record.Address, u.record.Name, u.name)
+		c.recorder.Eventf(u.node, corev1.EventTypeNormal, "PTRRecordUpdated",
+			"Set the PTR record of %s to %s", u.record.Address, u.name)
+	}
+
+	restored, err := c.restoreRecords(ctx, records, nodesByAddress, states)
+	if restored {
+		if _, saveErr := c.saveStates(ctx, configMap, states); saveErr != nil && err == nil {
+			err = fmt.Errorf("[sync] saveStates() failed: %w", saveErr)
+		}
+	}
+	return err
+}
+
+// restoreRecords restores the previous names of the records whose address
+// does not belong to the node of the state anymore. The states get removed.
+// Returns true if states were removed.
+func (c *ptrRecordsController) restoreRecords(
+	ctx context.Context,
+	records []hv.PtrRecordReturn,
+	nodesByAddress map[string]*corev1.Node,
+	states map[string]ptrRecordState,
+) (bool, error) {
+	recordsByID := make(map[string]hv.PtrRecordReturn, len(records))
+	for _, record := range records {
+		recordsByID[strconv.Itoa(int(record.Id))] = record
+	}
+
+	restored := false
+	for key, state := range states {
+		if node, ok := nodesByAddress[state.Address]; ok && node.Name == state.Node {
+			continue
+		}
+		record, ok := recordsByID[key]
+		if ok && !sameDNSName(record.Name, state.PreviousName) {
+			err := c.client.UpdatePTRRecord(ctx, record.Id, hv.PtrRecordUpdate{Name: state.PreviousName, Ttl: record.Ttl})
+			if err != nil {
+				return restored, fmt.Errorf("[restoreRecords] UpdatePTRRecord() failed: %w", err)
+			}
+			klog.Infof("Restored PTR record of %s to %q, node %q was removed",
+				state.Address, state.PreviousName, state.Node)
+		}
+		delete(states, key)
+		restored = true
+	}
+	return restored, nil
+}
+
+// loadStates returns the ConfigMap with the states, nil if it does not exist yet.
+func (c *ptrRecordsController) loadStates(ctx context.Context) (*corev1.ConfigMap, map[string]ptrRecordState, error) {
+	states := make(map[string]ptrRecordState)
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(metav1.NamespaceSystem).
+		Get(ctx, ptrRecordsConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, states, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("[loadStates] getting ConfigMap failed: %w", err)
+	}
+	for key, value := range configMap.Data {
+		var state ptrRecordState
+		if err := json.Unmarshal([]byte(value), &state); err != nil {
+			klog.Errorf("[ptrRecordsController] ConfigMap %s, key %q: %v", ptrRecordsConfigMapName, key, err)
+			continue
+		}
+		states[key] = state
+	}
+	return configMap, states, nil
+}
+
+// saveStates creates or updates the ConfigMap. The update fails if the
+// ConfigMap was changed since loadStates.
+func (c *ptrRecordsController) saveStates(
+	ctx context.Context,
+	configMap *corev1.ConfigMap,
+	states map[string]ptrRecordState,
+) (*corev1.ConfigMap, error) {
+	data := make(map[string]string, len(states))
+	for key, state := range states {
+		value, err := json.Marshal(state)
+		if err != nil {
+			return nil, fmt.Errorf("[saveStates] json.Marshal() failed: %w", err)
+		}
+		data[key] = string(value)
+	}
+
+	configMaps := c.kubeClient.CoreV1().ConfigMaps(metav1.NamespaceSystem)
+	if configMap == nil {
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: metav1.NamespaceSystem,
+				Name:      ptrRecordsConfigMapName,
+				Labels:    map[string]string{managedByLabel: managedByValue},
+			},
+			Data: data,
+		}
+		created, err := configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("[saveStates] creating ConfigMap failed: %w", err)
+		}
+		return created, nil
+	}
+
+	configMap = configMap.DeepCopy()
+	configMap.Data = data
+	updated, err := configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("[saveStates] updating ConfigMap failed: %w", err)
+	}
+	return updated, nil
+}
+
+// ptrRecordName returns the name of the template for a node. The
+// placeholders {{node}} and {{cluster}} get replaced.
+func ptrRecordName(template, nodeName, clusterName string) string {
+	return strings.ToLower(strings.NewReplacer("{{node}}", nodeName, "{{cluster}}", clusterName).Replace(template))
+}
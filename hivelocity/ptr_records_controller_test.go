@@ -0,0 +1,138 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+)
+
+func newPTRRecordsTestController(t *testing.T, m *mocks.Interface, objs ...runtime.Object) *ptrRecordsController {
+	t.Helper()
+	var nodes []*corev1.Node
+	for _, obj := range objs {
+		if node, ok := obj.(*corev1.Node); ok {
+			nodes = append(nodes, node)
+		}
+	}
+	return &ptrRecordsController{
+		client:      m,
+		kubeClient:  fake.NewSimpleClientset(objs...),
+		nodeLister:  newTestNodeLister(t, nodes...),
+		recorder:    record.NewFakeRecorder(10),
+		template:    "{{node}}.{{cluster}}.example.com",
+		clusterName: "prod",
+	}
+}
+
+func newPTRRecordsTestConfigMap(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: metav1.NamespaceSystem, Name: ptrRecordsConfigMapName},
+		Data:       data,
+	}
+}
+
+func getPTRRecordsTestConfigMap(t *testing.T, c *ptrRecordsController) map[string]string {
+	t.Helper()
+	configMap, err := c.kubeClient.CoreV1().ConfigMaps(metav1.NamespaceSystem).
+		Get(context.Background(), ptrRecordsConfigMapName, metav1.GetOptions{})
+	require.NoError(t, err)
+	return configMap.Data
+}
+
+func Test_ptrRecordsController_sync_setsName(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ListPTRRecords", mock.Anything).Return([]hv.PtrRecordReturn{
+		{Id: 1, Address: "198.51.100.1", Name: "1.100.51.198.static.hvvc.us", Ttl: 3600},
+		{Id: 2, Address: "198.51.100.2", Name: "node-2.prod.example.com.", Ttl: 3600},
+		{Id: 3, Address: "198.51.100.9", Name: "9.100.51.198.static.hvvc.us", Ttl: 3600},
+	}, nil)
+	m.On("UpdatePTRRecord", mock.Anything, int32(1),
+		hv.PtrRecordUpdate{Name: "node-1.prod.example.com", Ttl: 3600}).Return(nil)
+
+	c := newPTRRecordsTestController(t, m,
+		newLoadBalancerTestNode("node-1", "198.51.100.1", true),
+		newLoadBalancerTestNode("Node-2", "198.51.100.2", true))
+	require.NoError(t, c.sync(context.Background()))
+
+	require.Equal(t, map[string]string{
+		"1": `{"address":"198.51.100.1","node":"node-1","previousName":"1.100.51.198.static.hvvc.us"}`,
+	}, getPTRRecordsTestConfigMap(t, c))
+	require.Contains(t, <-c.recorder.(*record.FakeRecorder).Events, "PTRRecordUpdated")
+}
+
+func Test_ptrRecordsController_sync_repairsDrift(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ListPTRRecords", mock.Anything).Return([]hv.PtrRecordReturn{
+		{Id: 1, Address: "198.51.100.1", Name: "changed.example.com", Ttl: 3600},
+	}, nil)
+	m.On("UpdatePTRRecord", mock.Anything, int32(1),
+		hv.PtrRecordUpdate{Name: "node-1.prod.example.com", Ttl: 3600}).Return(nil)
+
+	state := map[string]string{
+		"1": `{"address":"198.51.100.1","node":"node-1","previousName":"1.100.51.198.static.hvvc.us"}`,
+	}
+	c := newPTRRecordsTestController(t, m,
+		newLoadBalancerTestNode("node-1", "198.51.100.1", true),
+		newPTRRecordsTestConfigMap(state))
+	require.NoError(t, c.sync(context.Background()))
+
+	// The name from before the controller is kept.
+	require.Equal(t, state, getPTRRecordsTestConfigMap(t, c))
+}
+
+func Test_ptrRecordsController_sync_restoresRemovedNode(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ListPTRRecords", mock.Anything).Return([]hv.PtrRecordReturn{
+		{Id: 1, Address: "198.51.100.1", Name: "node-1.prod.example.com", Ttl: 3600},
+		{Id: 2, Address: "198.51.100.2", Name: "node-2.prod.example.com", Ttl: 3600},
+	}, nil)
+	m.On("UpdatePTRRecord", mock.Anything, int32(1),
+		hv.PtrRecordUpdate{Name: "1.100.51.198.static.hvvc.us", Ttl: 3600}).Return(nil)
+
+	c := newPTRRecordsTestController(t, m,
+		newLoadBalancerTestNode("node-2", "198.51.100.2", true),
+		newPTRRecordsTestConfigMap(map[string]string{
+			"1": `{"address":"198.51.100.1","node":"node-1","previousName":"1.100.51.198.static.hvvc.us"}`,
+			"2": `{"address":"198.51.100.2","node":"node-2","previousName":"2.100.51.198.static.hvvc.us"}`,
+		}))
+	require.NoError(t, c.sync(context.Background()))
+
+	require.Equal(t, map[string]string{
+		"2": `{"address":"198.51.100.2","node":"node-2","previousName":"2.100.51.198.static.hvvc.us"}`,
+	}, getPTRRecordsTestConfigMap(t, c))
+}
+
+func Test_ptrRecordName(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "node-1.prod.example.com", ptrRecordName("{{node}}.{{cluster}}.example.com", "Node-1", "prod"))
+	require.Equal(t, "static.example.com", ptrRecordName("static.example.com", "node-1", "prod"))
+	require.Contains(t, ptrRecordName("{{host}}.example.com", "node-1", "prod"), "{{")
+}
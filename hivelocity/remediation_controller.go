@@ -0,0 +1,416 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/api/v1alpha1"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// lastRemediationAnnotation stores the time of the last power-cycle or reload
+	// of a node. It is used to rate limit the remediations per node.
+	lastRemediationAnnotation = "hivelocity.net/last-remediation"
+
+	defaultPowerCycleTimeout = 10 * time.Minute
+	defaultReloadTimeout     = 60 * time.Minute
+)
+
+// remediationController remediates unhealthy nodes according to HivelocityRemediation
+// objects. The objects are usually created by the medik8s Node Health Check operator.
+type remediationController struct {
+	client       client.Interface
+	kubeClient   kubernetes.Interface
+	ctrlClient   ctrlclient.Client
+	nodeLister   corelisters.NodeLister
+	nodesSynced  cache.InformerSynced
+	recorder     record.EventRecorder
+	pollInterval time.Duration
+	minInterval  time.Duration
+}
+
+func startRemediationController(
+	ctx context.Context,
+	initContext app.ControllerInitContext,
+	_ genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error) {
+	ctrlClient, err := newCtrlClient(completedConfig, initContext.ClientName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ctrl := newRemediationController(
+		c.client,
+		completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName),
+		ctrlClient,
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		c.config.remediation,
+	)
+	go ctrl.Run(ctx)
+	return nil, true, nil
+}
+
+// newCtrlClient creates a client which knows the API types of this repository.
+func newCtrlClient(
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	clientName string,
+) (ctrlclient.Client, error) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return nil, fmt.Errorf("[newCtrlClient] corev1.AddToScheme() failed: %w", err)
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		return nil, fmt.Errorf("[newCtrlClient] v1alpha1.AddToScheme() failed: %w", err)
+	}
+	c, err := ctrlclient.New(completedConfig.ClientBuilder.ConfigOrDie(clientName), ctrlclient.Options{Scheme: scheme})
+	if err != nil {
+		return nil, fmt.Errorf("[newCtrlClient] New() failed: %w", err)
+	}
+	return c, nil
+}
+
+func newRemediationController(
+	c client.Interface,
+	kubeClient kubernetes.Interface,
+	ctrlClient ctrlclient.Client,
+	nodeInformer coreinformers.NodeInformer,
+	cfg remediationConfig,
+) *remediationController {
+	return &remediationController{
+		client:       c,
+		kubeClient:   kubeClient,
+		ctrlClient:   ctrlClient,
+		nodeLister:   nodeInformer.Lister(),
+		nodesSynced:  nodeInformer.Informer().HasSynced,
+		recorder:     newEventRecorder(kubeClient, remediationControllerName),
+		pollInterval: cfg.pollInterval,
+		minInterval:  cfg.minInterval,
+	}
+}
+
+// Run reconciles the HivelocityRemediation objects until ctx is done.
+func (c *remediationController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.Info("Starting Hivelocity remediation controller")
+	defer klog.Info("Shutting down Hivelocity remediation controller")
+
+	if !cache.WaitForNamedCacheSync(remediationControllerName, ctx.Done(), c.nodesSynced) {
+		return
+	}
+
+	wait.UntilWithContext(ctx, c.reconcileRemediations, c.pollInterval)
+}
+
+func (c *remediationController) reconcileRemediations(ctx context.Context) {
+	var remediations v1alpha1.HivelocityRemediationList
+	if err := c.ctrlClient.List(ctx, &remediations); err != nil {
+		klog.Errorf("[remediationController] listing HivelocityRemediations failed: %v", err)
+		return
+	}
+
+	for i := range remediations.Items {
+		remediation := &remediations.Items[i]
+		if err := c.reconcile(ctx, remediation); err != nil {
+			klog.Errorf("[remediationController] HivelocityRemediation %s/%s: %v",
+				remediation.Namespace, remediation.Name, err)
+		}
+	}
+}
+
+func (c *remediationController) reconcile(ctx context.Context, remediation *v1alpha1.HivelocityRemediation) error {
+	original := remediation.Status.DeepCopy()
+
+	switch remediation.Status.Phase {
+	case v1alpha1.RemediationPhaseSucceeded, v1alpha1.RemediationPhaseFailed, v1alpha1.RemediationPhaseDryRun:
+		return nil
+	case "":
+		remediation.Status.Phase = v1alpha1.RemediationPhasePending
+	case v1alpha1.RemediationPhasePending,
+		v1alpha1.RemediationPhaseRateLimited,
+		v1alpha1.RemediationPhasePowerCycling,
+		v1alpha1.RemediationPhaseReloading:
+	}
+
+	if err := c.remediate(ctx, remediation); err != nil {
+		return err
+	}
+	setRemediationConditions(remediation)
+
+	if equality.Semantic.DeepEqual(original, &remediation.Status) {
+		return nil
+	}
+	if err := c.ctrlClient.Status().Update(ctx, remediation); err != nil {
+		return fmt.Errorf("[reconcile] updating status failed: %w", err)
+	}
+	return nil
+}
+
+// remediate advances the remediation by one step. The node has the same name
+// as the HivelocityRemediation.
+func (c *remediationController) remediate(ctx context.Context, remediation *v1alpha1.HivelocityRemediation) error {
+	node, err := c.nodeLister.Get(remediation.Name)
+	if apierrors.IsNotFound(err) {
+		setRemediationPhase(remediation, v1alpha1.RemediationPhaseFailed, "node %q not found", remediation.Name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("[remediate] getting node failed: %w", err)
+	}
+
+	deviceID, err := getHivelocityDeviceIDFromNode(node)
+	if err != nil {
+		setRemediationPhase(remediation, v1alpha1.RemediationPhaseFailed, "%v", err)
+		return nil
+	}
+	remediation.Status.DeviceID = deviceID
+
+	now := time.Now()
+	status := &remediation.Status
+
+	switch status.Phase {
+	case v1alpha1.RemediationPhasePowerCycling:
+		if nodeReadySince(node, status.PowerCycleTime.Time) {
+			setRemediationPhase(remediation, v1alpha1.RemediationPhaseSucceeded, "node is ready after power-cycle")
+			return nil
+		}
+		timeout := durationOrDefault(remediation.Spec.PowerCycleTimeout, defaultPowerCycleTimeout)
+		if now.Before(status.PowerCycleTime.Add(timeout)) {
+			return nil
+		}
+		if remediation.Spec.Reload == nil {
+			setRemediationPhase(remediation, v1alpha1.RemediationPhaseFailed,
+				"node is not ready %s after power-cycle and reload is not configured", timeout)
+			c.recorder.Event(node, corev1.EventTypeWarning, "RemediationFailed", status.Message)
+			return nil
+		}
+		return c.reloadDevice(ctx, node, remediation, now)
+
+	case v1alpha1.RemediationPhaseReloading:
+		if nodeReadySince(node, status.ReloadTime.Time) {
+			setRemediationPhase(remediation, v1alpha1.RemediationPhaseSucceeded, "node is ready after reload")
+			return nil
+		}
+		timeout := defaultReloadTimeout
+		if remediation.Spec.Reload != nil {
+			timeout = durationOrDefault(remediation.Spec.Reload.Timeout, defaultReloadTimeout)
+		}
+		if now.After(status.ReloadTime.Add(timeout)) {
+			setRemediationPhase(remediation, v1alpha1.RemediationPhaseFailed,
+				"node is not ready %s after reload", timeout)
+			c.recorder.Event(node, corev1.EventTypeWarning, "RemediationFailed", status.Message)
+		}
+		return nil
+
+	default:
+		return c.powerCycleDevice(ctx, node, remediation, now)
+	}
+}
+
+func (c *remediationController) powerCycleDevice(
+	ctx context.Context,
+	node *corev1.Node,
+	remediation *v1alpha1.HivelocityRemediation,
+	now time.Time,
+) error {
+	deviceID := remediation.Status.DeviceID
+
+	if remediation.Spec.DryRun {
+		setRemediationPhase(remediation, v1alpha1.RemediationPhaseDryRun,
+			"dry-run: device %d would be power-cycled", deviceID)
+		c.recorder.Event(node, corev1.EventTypeNormal, "RemediationDryRun", remediation.Status.Message)
+		return nil
+	}
+
+	if next, limited := c.rateLimited(node, now); limited {
+		setRemediationPhase(remediation, v1alpha1.RemediationPhaseRateLimited,
+			"node was remediated recently, next remediation at %s", next.Format(time.RFC3339))
+		return nil
+	}
+
+	// Record the remediation first. If this fails, the device won't be power-cycled
+	// without the rate limit.
+	if err := c.recordRemediation(ctx, node, now); err != nil {
+		return err
+	}
+	if err := c.client.PowerDevice(ctx, deviceID, client.PowerActionReboot); err != nil {
+		c.restoreLastRemediation(ctx, node)
+		return fmt.Errorf("[powerCycleDevice] PowerDevice() failed: %w", err)
+	}
+
+	remediation.Status.PowerCycleTime = &metav1.Time{Time: now}
+	setRemediationPhase(remediation, v1alpha1.RemediationPhasePowerCycling,
+		"device %d was power-cycled, waiting for node to become ready", deviceID)
+	c.recorder.Event(node, corev1.EventTypeNormal, "RemediationPowerCycle", remediation.Status.Message)
+	return nil
+}
+
+func (c *remediationController) reloadDevice(
+	ctx context.Context,
+	node *corev1.Node,
+	remediation *v1alpha1.HivelocityRemediation,
+	now time.Time,
+) error {
+	deviceID := remediation.Status.DeviceID
+	reload := remediation.Spec.Reload
+
+	if err := c.recordRemediation(ctx, node, now); err != nil {
+		return err
+	}
+	if err := c.client.ReloadDevice(ctx, deviceID, hv.DeviceReload{
+		OperatingSystemId: reload.OperatingSystemID,
+		IgnitionId:        reload.IgnitionID,
+		Script:            reload.Script,
+		PublicSshKeyIds:   reload.PublicSSHKeyIDs,
+	}); err != nil {
+		c.restoreLastRemediation(ctx, node)
+		return fmt.Errorf("[reloadDevice] ReloadDevice() failed: %w", err)
+	}
+
+	remediation.Status.ReloadTime = &metav1.Time{Time: now}
+	setRemediationPhase(remediation, v1alpha1.RemediationPhaseReloading,
+		"node was not ready after power-cycle, reloading device %d", deviceID)
+	c.recorder.Event(node, corev1.EventTypeWarning, "RemediationReload", remediation.Status.Message)
+	return nil
+}
+
+// rateLimited returns true if the node was remediated less than minInterval ago.
+func (c *remediationController) rateLimited(node *corev1.Node, now time.Time) (time.Time, bool) {
+	last, err := time.Parse(time.RFC3339, node.Annotations[lastRemediationAnnotation])
+	if err != nil {
+		return time.Time{}, false
+	}
+	next := last.Add(c.minInterval)
+	return next, now.Before(next)
+}
+
+// recordRemediation stores the time of the remediation in an annotation of the node.
+// The annotation survives restarts of the controller and the deletion of the
+// HivelocityRemediation.
+func (c *remediationController) recordRemediation(ctx context.Context, node *corev1.Node, now time.Time) error {
+	return c.patchLastRemediation(ctx, node, now.UTC().Format(time.RFC3339))
+}
+
+// restoreLastRemediation sets the annotation back to the value of node after
+// the API call of the remediation failed. Nothing was done to the device, so
+// the rate limit must not delay the retry.
+func (c *remediationController) restoreLastRemediation(ctx context.Context, node *corev1.Node) {
+	if err := c.patchLastRemediation(ctx, node, node.Annotations[lastRemediationAnnotation]); err != nil {
+		klog.Errorf("[remediationController] restoring the last remediation of node %q failed: %v", node.Name, err)
+	}
+}
+
+// patchLastRemediation sets the annotation of the last remediation. An empty value removes it.
+func (c *remediationController) patchLastRemediation(ctx context.Context, node *corev1.Node, value string) error {
+	patch, err := annotationMergePatch(lastRemediationAnnotation, value)
+	if err != nil {
+		return fmt.Errorf("[patchLastRemediation] annotationMergePatch() failed: %w", err)
+	}
+	if _, err := c.kubeClient.CoreV1().Nodes().Patch(
+		ctx, node.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		return fmt.Errorf("[patchLastRemediation] patching node %q failed: %w", node.Name, err)
+	}
+	return nil
+}
+
+func setRemediationPhase(
+	remediation *v1alpha1.HivelocityRemediation,
+	phase v1alpha1.RemediationPhase,
+	format string,
+	args ...interface{},
+) {
+	remediation.Status.Phase = phase
+	remediation.Status.Message = fmt.Sprintf(format, args...)
+}
+
+// setRemediationConditions sets the conditions which are evaluated by the
+// Node Health Check operator.
+func setRemediationConditions(remediation *v1alpha1.HivelocityRemediation) {
+	status := &remediation.Status
+	processing := metav1.ConditionFalse
+	succeeded := metav1.ConditionFalse
+	switch status.Phase {
+	case v1alpha1.RemediationPhasePending,
+		v1alpha1.RemediationPhaseRateLimited,
+		v1alpha1.RemediationPhasePowerCycling,
+		v1alpha1.RemediationPhaseReloading:
+		processing = metav1.ConditionTrue
+	case v1alpha1.RemediationPhaseSucceeded:
+		succeeded = metav1.ConditionTrue
+	case v1alpha1.RemediationPhaseFailed, v1alpha1.RemediationPhaseDryRun:
+	}
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               v1alpha1.RemediationConditionProcessing,
+		Status:             processing,
+		Reason:             string(status.Phase),
+		Message:            status.Message,
+		ObservedGeneration: remediation.Generation,
+	})
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               v1alpha1.RemediationConditionSucceeded,
+		Status:             succeeded,
+		Reason:             string(status.Phase),
+		Message:            status.Message,
+		ObservedGeneration: remediation.Generation,
+	})
+}
+
+// nodeReadySince returns true if the node became ready after t.
+func nodeReadySince(node *corev1.Node, t time.Time) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type != corev1.NodeReady {
+			continue
+		}
+		return condition.Status == corev1.ConditionTrue && condition.LastTransitionTime.After(t)
+	}
+	return false
+}
+
+func durationOrDefault(d *metav1.Duration, defaultValue time.Duration) time.Duration {
+	if d == nil || d.Duration <= 0 {
+		return defaultValue
+	}
+	return d.Duration
+}
@@ -0,0 +1,205 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/api/v1alpha1"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newRemediationTestController(
+	t *testing.T,
+	m *mocks.Interface,
+	node *corev1.Node,
+	remediation *v1alpha1.HivelocityRemediation,
+) (*remediationController, ctrlclient.Client) {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	ctrlClient := ctrlfake.NewClientBuilder().WithScheme(scheme).WithObjects(remediation).Build()
+
+	return &remediationController{
+		client:      m,
+		kubeClient:  fake.NewSimpleClientset(node),
+		ctrlClient:  ctrlClient,
+		nodeLister:  newTestNodeLister(t, node),
+		recorder:    record.NewFakeRecorder(10),
+		minInterval: time.Hour,
+	}, ctrlClient
+}
+
+func newRemediationTestNode(annotations map[string]string, readySince time.Time) *corev1.Node {
+	node := newNode("hivelocity://12345", nodeName)
+	node.Annotations = annotations
+	node.Status.Conditions = []corev1.NodeCondition{{
+		Type:               corev1.NodeReady,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(readySince),
+	}}
+	return node
+}
+
+func newTestRemediation(spec v1alpha1.HivelocityRemediationSpec, status v1alpha1.HivelocityRemediationStatus) *v1alpha1.HivelocityRemediation {
+	return &v1alpha1.HivelocityRemediation{
+		ObjectMeta: metav1.ObjectMeta{Name: nodeName, Namespace: "default"},
+		Spec:       spec,
+		Status:     status,
+	}
+}
+
+func reconcileTestRemediation(
+	t *testing.T,
+	c *remediationController,
+	ctrlClient ctrlclient.Client,
+) *v1alpha1.HivelocityRemediation {
+	t.Helper()
+	ctx := context.Background()
+	c.reconcileRemediations(ctx)
+
+	var got v1alpha1.HivelocityRemediation
+	require.NoError(t, ctrlClient.Get(ctx, ctrlclient.ObjectKey{Namespace: "default", Name: nodeName}, &got))
+	return &got
+}
+
+func Test_remediation_powerCycle(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("PowerDevice", mock.Anything, int32(dummyDeviceID), client.PowerActionReboot).Return(nil)
+
+	node := newRemediationTestNode(nil, time.Now().Add(-time.Hour))
+	c, ctrlClient := newRemediationTestController(t, m, node,
+		newTestRemediation(v1alpha1.HivelocityRemediationSpec{}, v1alpha1.HivelocityRemediationStatus{}))
+
+	got := reconcileTestRemediation(t, c, ctrlClient)
+	require.Equal(t, v1alpha1.RemediationPhasePowerCycling, got.Status.Phase)
+	require.Equal(t, int32(dummyDeviceID), got.Status.DeviceID)
+	require.NotNil(t, got.Status.PowerCycleTime)
+
+	updatedNode, err := c.kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.NotEmpty(t, updatedNode.Annotations[lastRemediationAnnotation])
+}
+
+func Test_remediation_powerCycleFailed(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("PowerDevice", mock.Anything, int32(dummyDeviceID), client.PowerActionReboot).
+		Return(errors.New("service unavailable"))
+
+	lastRemediation := time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	node := newRemediationTestNode(map[string]string{lastRemediationAnnotation: lastRemediation},
+		time.Now().Add(-time.Hour))
+	c, ctrlClient := newRemediationTestController(t, m, node,
+		newTestRemediation(v1alpha1.HivelocityRemediationSpec{}, v1alpha1.HivelocityRemediationStatus{}))
+
+	got := reconcileTestRemediation(t, c, ctrlClient)
+	require.Nil(t, got.Status.PowerCycleTime)
+
+	// Nothing was power-cycled, so the rate limit does not block the retry.
+	updatedNode, err := c.kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, lastRemediation, updatedNode.Annotations[lastRemediationAnnotation])
+}
+
+func Test_remediation_dryRun(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+
+	node := newRemediationTestNode(nil, time.Now().Add(-time.Hour))
+	c, ctrlClient := newRemediationTestController(t, m, node,
+		newTestRemediation(v1alpha1.HivelocityRemediationSpec{DryRun: true}, v1alpha1.HivelocityRemediationStatus{}))
+
+	got := reconcileTestRemediation(t, c, ctrlClient)
+	require.Equal(t, v1alpha1.RemediationPhaseDryRun, got.Status.Phase)
+	m.AssertNotCalled(t, "PowerDevice", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func Test_remediation_rateLimited(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+
+	node := newRemediationTestNode(map[string]string{
+		lastRemediationAnnotation: time.Now().Add(-10 * time.Minute).UTC().Format(time.RFC3339),
+	}, time.Now().Add(-time.Hour))
+	c, ctrlClient := newRemediationTestController(t, m, node,
+		newTestRemediation(v1alpha1.HivelocityRemediationSpec{}, v1alpha1.HivelocityRemediationStatus{}))
+
+	got := reconcileTestRemediation(t, c, ctrlClient)
+	require.Equal(t, v1alpha1.RemediationPhaseRateLimited, got.Status.Phase)
+	m.AssertNotCalled(t, "PowerDevice", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func Test_remediation_escalateToReload(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ReloadDevice", mock.Anything, int32(dummyDeviceID), hv.DeviceReload{OperatingSystemId: 42}).Return(nil)
+
+	powerCycleTime := time.Now().Add(-20 * time.Minute)
+	node := newRemediationTestNode(nil, powerCycleTime.Add(-time.Hour))
+	c, ctrlClient := newRemediationTestController(t, m, node,
+		newTestRemediation(
+			v1alpha1.HivelocityRemediationSpec{Reload: &v1alpha1.ReloadSpec{OperatingSystemID: 42}},
+			v1alpha1.HivelocityRemediationStatus{
+				Phase:          v1alpha1.RemediationPhasePowerCycling,
+				PowerCycleTime: &metav1.Time{Time: powerCycleTime},
+			}))
+
+	got := reconcileTestRemediation(t, c, ctrlClient)
+	require.Equal(t, v1alpha1.RemediationPhaseReloading, got.Status.Phase)
+	require.NotNil(t, got.Status.ReloadTime)
+}
+
+func Test_remediation_succeeded(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+
+	powerCycleTime := time.Now().Add(-5 * time.Minute)
+	node := newRemediationTestNode(nil, powerCycleTime.Add(time.Minute))
+	c, ctrlClient := newRemediationTestController(t, m, node,
+		newTestRemediation(
+			v1alpha1.HivelocityRemediationSpec{},
+			v1alpha1.HivelocityRemediationStatus{
+				Phase:          v1alpha1.RemediationPhasePowerCycling,
+				PowerCycleTime: &metav1.Time{Time: powerCycleTime},
+			}))
+
+	got := reconcileTestRemediation(t, c, ctrlClient)
+	require.Equal(t, v1alpha1.RemediationPhaseSucceeded, got.Status.Phase)
+	require.Len(t, got.Status.Conditions, 2)
+	for _, condition := range got.Status.Conditions {
+		if condition.Type == v1alpha1.RemediationConditionSucceeded {
+			require.Equal(t, metav1.ConditionTrue, condition.Status)
+		}
+	}
+}
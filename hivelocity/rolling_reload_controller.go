@@ -0,0 +1,412 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/api/v1alpha1"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const defaultDrainTimeout = 10 * time.Minute
+
+// rollingReloadController reloads the operating system of nodes according to
+// HivelocityRollingReload objects.
+type rollingReloadController struct {
+	client       client.Interface
+	kubeClient   kubernetes.Interface
+	ctrlClient   ctrlclient.Client
+	nodeLister   corelisters.NodeLister
+	nodesSynced  cache.InformerSynced
+	recorder     record.EventRecorder
+	pollInterval time.Duration
+}
+
+func startRollingReloadController(
+	ctx context.Context,
+	initContext app.ControllerInitContext,
+	_ genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error) {
+	ctrlClient, err := newCtrlClient(completedConfig, initContext.ClientName)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ctrl := newRollingReloadController(
+		c.client,
+		completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName),
+		ctrlClient,
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		c.config.rollingReload.pollInterval,
+	)
+	go ctrl.Run(ctx)
+	return nil, true, nil
+}
+
+func newRollingReloadController(
+	c client.Interface,
+	kubeClient kubernetes.Interface,
+	ctrlClient ctrlclient.Client,
+	nodeInformer coreinformers.NodeInformer,
+	pollInterval time.Duration,
+) *rollingReloadController {
+	return &rollingReloadController{
+		client:       c,
+		kubeClient:   kubeClient,
+		ctrlClient:   ctrlClient,
+		nodeLister:   nodeInformer.Lister(),
+		nodesSynced:  nodeInformer.Informer().HasSynced,
+		recorder:     newEventRecorder(kubeClient, rollingReloadControllerName),
+		pollInterval: pollInterval,
+	}
+}
+
+// Run reconciles the HivelocityRollingReload objects until ctx is done.
+func (c *rollingReloadController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.Info("Starting Hivelocity rolling reload controller")
+	defer klog.Info("Shutting down Hivelocity rolling reload controller")
+
+	if !cache.WaitForNamedCacheSync(rollingReloadControllerName, ctx.Done(), c.nodesSynced) {
+		return
+	}
+
+	wait.UntilWithContext(ctx, c.reconcileRollingReloads, c.pollInterval)
+}
+
+func (c *rollingReloadController) reconcileRollingReloads(ctx context.Context) {
+	var rollingReloads v1alpha1.HivelocityRollingReloadList
+	if err := c.ctrlClient.List(ctx, &rollingReloads); err != nil {
+		klog.Errorf("[rollingReloadController] listing HivelocityRollingReloads failed: %v", err)
+		return
+	}
+
+	for i := range rollingReloads.Items {
+		rollingReload := &rollingReloads.Items[i]
+		if err := c.reconcile(ctx, rollingReload); err != nil {
+			klog.Errorf("[rollingReloadController] HivelocityRollingReload %s: %v", rollingReload.Name, err)
+		}
+	}
+}
+
+func (c *rollingReloadController) reconcile(ctx context.Context, rollingReload *v1alpha1.HivelocityRollingReload) error {
+	if rollingReload.Status.Phase == v1alpha1.RollingReloadPhaseCompleted {
+		return nil
+	}
+	original := rollingReload.DeepCopy()
+	status := &rollingReload.Status
+
+	if status.Nodes == nil {
+		if err := c.selectNodes(rollingReload); err != nil {
+			return err
+		}
+	}
+
+	// Failed nodes get retried when the reload gets resumed. A failed node may
+	// still be cordoned by the reload, so whether it was cordoned before is kept.
+	if !rollingReload.Spec.Paused {
+		for i := range status.Nodes {
+			if status.Nodes[i].Phase == v1alpha1.NodeReloadPhaseFailed {
+				status.Nodes[i] = v1alpha1.NodeReloadStatus{
+					Name:             status.Nodes[i].Name,
+					Phase:            v1alpha1.NodeReloadPhasePending,
+					WasUnschedulable: status.Nodes[i].WasUnschedulable,
+				}
+			}
+		}
+	}
+
+	maxUnavailable := int(rollingReload.Spec.MaxUnavailable)
+	if maxUnavailable < 1 {
+		maxUnavailable = 1
+	}
+
+	// Nodes which are in progress continue even if the reload is paused. They
+	// are counted before pending nodes get started, so that no more than
+	// maxUnavailable nodes are in progress, whatever the order of the nodes.
+	inProgress := 0
+	for _, nodeStatus := range status.Nodes {
+		if reloadInProgress(nodeStatus.Phase) {
+			inProgress++
+		}
+	}
+
+	var failed []string
+	for i := range status.Nodes {
+		nodeStatus := &status.Nodes[i]
+		switch nodeStatus.Phase {
+		case v1alpha1.NodeReloadPhaseDone, v1alpha1.NodeReloadPhaseFailed:
+			continue
+		case v1alpha1.NodeReloadPhasePending:
+			if rollingReload.Spec.Paused || inProgress >= maxUnavailable {
+				continue
+			}
+			inProgress++
+		case v1alpha1.NodeReloadPhaseDraining, v1alpha1.NodeReloadPhaseReloading:
+		}
+
+		if err := c.advanceNode(ctx, rollingReload, nodeStatus); err != nil {
+			return err
+		}
+
+		if !reloadInProgress(nodeStatus.Phase) {
+			inProgress--
+		}
+		if nodeStatus.Phase == v1alpha1.NodeReloadPhaseFailed {
+			failed = append(failed, nodeStatus.Name)
+		}
+	}
+
+	if len(failed) > 0 && !rollingReload.Spec.Paused {
+		// Pause on failure. Setting spec.paused to false resumes the reload.
+		patchBase := ctrlclient.MergeFrom(original)
+		paused := original.DeepCopy()
+		paused.Spec.Paused = true
+		if err := c.ctrlClient.Patch(ctx, paused, patchBase); err != nil {
+			return fmt.Errorf("[reconcile] pausing failed: %w", err)
+		}
+		rollingReload.Spec.Paused = true
+		rollingReload.ResourceVersion = paused.ResourceVersion
+	}
+
+	setRollingReloadPhase(rollingReload, inProgress, failed)
+
+	if equality.Semantic.DeepEqual(original.Status, rollingReload.Status) {
+		return nil
+	}
+	if err := c.ctrlClient.Status().Update(ctx, rollingReload); err != nil {
+		return fmt.Errorf("[reconcile] updating status failed: %w", err)
+	}
+	return nil
+}
+
+// selectNodes stores the nodes which match the selector in the status.
+func (c *rollingReloadController) selectNodes(rollingReload *v1alpha1.HivelocityRollingReload) error {
+	selector, err := metav1.LabelSelectorAsSelector(&rollingReload.Spec.NodeSelector)
+	if err != nil {
+		return fmt.Errorf("[selectNodes] invalid nodeSelector: %w", err)
+	}
+	nodes, err := c.nodeLister.List(selector)
+	if err != nil {
+		return fmt.Errorf("[selectNodes] listing nodes failed: %w", err)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	rollingReload.Status.Nodes = make([]v1alpha1.NodeReloadStatus, 0, len(nodes))
+	for _, node := range nodes {
+		rollingReload.Status.Nodes = append(rollingReload.Status.Nodes, v1alpha1.NodeReloadStatus{
+			Name:  node.Name,
+			Phase: v1alpha1.NodeReloadPhasePending,
+		})
+	}
+	return nil
+}
+
+// advanceNode moves the node one step forward: Pending -> Draining -> Reloading -> Done.
+func (c *rollingReloadController) advanceNode(
+	ctx context.Context,
+	rollingReload *v1alpha1.HivelocityRollingReload,
+	nodeStatus *v1alpha1.NodeReloadStatus,
+) error {
+	now := time.Now()
+
+	node, err := c.nodeLister.Get(nodeStatus.Name)
+	if apierrors.IsNotFound(err) {
+		if nodeStatus.Phase == v1alpha1.NodeReloadPhaseReloading {
+			// The node may be re-registered after the reload.
+			return c.checkReloadTimeout(rollingReload, nodeStatus, now)
+		}
+		nodeStatus.Phase = v1alpha1.NodeReloadPhaseDone
+		nodeStatus.Message = "node does not exist anymore, skipped"
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("[advanceNode] getting node %q failed: %w", nodeStatus.Name, err)
+	}
+
+	switch nodeStatus.Phase {
+	case v1alpha1.NodeReloadPhasePending:
+		if err := setNodeUnschedulable(ctx, c.kubeClient, node.Name, true); err != nil {
+			return err
+		}
+		if nodeStatus.WasUnschedulable == nil {
+			nodeStatus.WasUnschedulable = pointer.Bool(node.Spec.Unschedulable)
+		}
+		nodeStatus.Phase = v1alpha1.NodeReloadPhaseDraining
+		nodeStatus.DrainStartTime = &metav1.Time{Time: now}
+		nodeStatus.Message = "node cordoned, evicting pods"
+		c.recorder.Event(node, corev1.EventTypeNormal, "RollingReloadDrain", nodeStatus.Message)
+		return nil
+
+	case v1alpha1.NodeReloadPhaseDraining:
+		return c.drainAndReload(ctx, rollingReload, node, nodeStatus, now)
+
+	case v1alpha1.NodeReloadPhaseReloading:
+		if !nodeReadySince(node, nodeStatus.ReloadTime.Time) {
+			return c.checkReloadTimeout(rollingReload, nodeStatus, now)
+		}
+		if err := c.restoreUnschedulable(ctx, node, nodeStatus); err != nil {
+			return err
+		}
+		nodeStatus.Phase = v1alpha1.NodeReloadPhaseDone
+		nodeStatus.Message = "node is ready after reload"
+		c.recorder.Event(node, corev1.EventTypeNormal, "RollingReloadDone", nodeStatus.Message)
+		return nil
+
+	case v1alpha1.NodeReloadPhaseDone, v1alpha1.NodeReloadPhaseFailed:
+	}
+	return nil
+}
+
+func (c *rollingReloadController) drainAndReload(
+	ctx context.Context,
+	rollingReload *v1alpha1.HivelocityRollingReload,
+	node *corev1.Node,
+	nodeStatus *v1alpha1.NodeReloadStatus,
+	now time.Time,
+) error {
+	remaining, err := evictPods(ctx, c.kubeClient, node.Name)
+	if err != nil {
+		return err
+	}
+
+	if remaining > 0 {
+		timeout := durationOrDefault(rollingReload.Spec.DrainTimeout, defaultDrainTimeout)
+		if now.Before(nodeStatus.DrainStartTime.Add(timeout)) {
+			nodeStatus.Message = fmt.Sprintf("waiting for %d pods to be evicted", remaining)
+			return nil
+		}
+		// The node is still healthy, so it gets uncordoned again.
+		if err := c.restoreUnschedulable(ctx, node, nodeStatus); err != nil {
+			return err
+		}
+		nodeStatus.Phase = v1alpha1.NodeReloadPhaseFailed
+		nodeStatus.Message = fmt.Sprintf("drain timed out after %s, %d pods not evicted", timeout, remaining)
+		c.recorder.Event(node, corev1.EventTypeWarning, "RollingReloadFailed", nodeStatus.Message)
+		return nil
+	}
+
+	deviceID, err := getHivelocityDeviceIDFromNode(node)
+	if err != nil {
+		// Nothing was reloaded, so the node gets uncordoned again.
+		if err := c.restoreUnschedulable(ctx, node, nodeStatus); err != nil {
+			return err
+		}
+		nodeStatus.Phase = v1alpha1.NodeReloadPhaseFailed
+		nodeStatus.Message = err.Error()
+		return nil
+	}
+
+	reload := rollingReload.Spec.Reload
+	if err := c.client.ReloadDevice(ctx, deviceID, hv.DeviceReload{
+		OperatingSystemId: reload.OperatingSystemID,
+		IgnitionId:        reload.IgnitionID,
+		Script:            reload.Script,
+		PublicSshKeyIds:   reload.PublicSSHKeyIDs,
+	}); err != nil {
+		return fmt.Errorf("[drainAndReload] ReloadDevice() failed. node %q: %w", node.Name, err)
+	}
+
+	nodeStatus.Phase = v1alpha1.NodeReloadPhaseReloading
+	nodeStatus.ReloadTime = &metav1.Time{Time: now}
+	nodeStatus.Message = fmt.Sprintf("reloading device %d, waiting for node to become ready", deviceID)
+	c.recorder.Event(node, corev1.EventTypeNormal, "RollingReloadReload", nodeStatus.Message)
+	return nil
+}
+
+// restoreUnschedulable uncordons the node, unless it was cordoned before the reload.
+func (c *rollingReloadController) restoreUnschedulable(
+	ctx context.Context,
+	node *corev1.Node,
+	nodeStatus *v1alpha1.NodeReloadStatus,
+) error {
+	if pointer.BoolDeref(nodeStatus.WasUnschedulable, false) {
+		return nil
+	}
+	return setNodeUnschedulable(ctx, c.kubeClient, node.Name, false)
+}
+
+// reloadInProgress returns true if the node is cordoned for the reload.
+func reloadInProgress(phase v1alpha1.NodeReloadPhase) bool {
+	return phase == v1alpha1.NodeReloadPhaseDraining || phase == v1alpha1.NodeReloadPhaseReloading
+}
+
+func (c *rollingReloadController) checkReloadTimeout(
+	rollingReload *v1alpha1.HivelocityRollingReload,
+	nodeStatus *v1alpha1.NodeReloadStatus,
+	now time.Time,
+) error {
+	timeout := durationOrDefault(rollingReload.Spec.Reload.Timeout, defaultReloadTimeout)
+	if now.Before(nodeStatus.ReloadTime.Add(timeout)) {
+		return nil
+	}
+	nodeStatus.Phase = v1alpha1.NodeReloadPhaseFailed
+	nodeStatus.Message = fmt.Sprintf("node is not ready %s after reload", timeout)
+	return nil
+}
+
+func setRollingReloadPhase(rollingReload *v1alpha1.HivelocityRollingReload, inProgress int, failed []string) {
+	status := &rollingReload.Status
+
+	done := 0
+	for _, nodeStatus := range status.Nodes {
+		if nodeStatus.Phase == v1alpha1.NodeReloadPhaseDone {
+			done++
+		}
+	}
+
+	switch {
+	case done == len(status.Nodes):
+		status.Phase = v1alpha1.RollingReloadPhaseCompleted
+		status.Message = fmt.Sprintf("%d nodes reloaded", done)
+	case len(failed) > 0:
+		status.Phase = v1alpha1.RollingReloadPhasePaused
+		status.Message = fmt.Sprintf("paused because of failed nodes %v, set spec.paused=false to retry", failed)
+	case rollingReload.Spec.Paused:
+		status.Phase = v1alpha1.RollingReloadPhasePaused
+		status.Message = fmt.Sprintf("%d of %d nodes reloaded, %d in progress", done, len(status.Nodes), inProgress)
+	default:
+		status.Phase = v1alpha1.RollingReloadPhaseProgressing
+		status.Message = fmt.Sprintf("%d of %d nodes reloaded, %d in progress", done, len(status.Nodes), inProgress)
+	}
+}
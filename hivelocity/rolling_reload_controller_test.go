@@ -0,0 +1,269 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/api/v1alpha1"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const testRollingReloadName = "upgrade"
+
+func newRollingReloadTestController(
+	t *testing.T,
+	m *mocks.Interface,
+	node *corev1.Node,
+	rollingReload *v1alpha1.HivelocityRollingReload,
+	kubeObjects ...runtime.Object,
+) (*rollingReloadController, ctrlclient.Client) {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	ctrlClient := ctrlfake.NewClientBuilder().WithScheme(scheme).WithObjects(rollingReload).Build()
+
+	return &rollingReloadController{
+		client:     m,
+		kubeClient: fake.NewSimpleClientset(append(kubeObjects, node)...),
+		ctrlClient: ctrlClient,
+		nodeLister: newTestNodeLister(t, node),
+		recorder:   record.NewFakeRecorder(10),
+	}, ctrlClient
+}
+
+func newTestRollingReload(status v1alpha1.HivelocityRollingReloadStatus) *v1alpha1.HivelocityRollingReload {
+	return &v1alpha1.HivelocityRollingReload{
+		ObjectMeta: metav1.ObjectMeta{Name: testRollingReloadName},
+		Spec: v1alpha1.HivelocityRollingReloadSpec{
+			Reload: v1alpha1.ReloadSpec{OperatingSystemID: 42},
+		},
+		Status: status,
+	}
+}
+
+func reconcileTestRollingReload(
+	t *testing.T,
+	c *rollingReloadController,
+	ctrlClient ctrlclient.Client,
+) *v1alpha1.HivelocityRollingReload {
+	t.Helper()
+	ctx := context.Background()
+	c.reconcileRollingReloads(ctx)
+
+	var got v1alpha1.HivelocityRollingReload
+	require.NoError(t, ctrlClient.Get(ctx, ctrlclient.ObjectKey{Name: testRollingReloadName}, &got))
+	return &got
+}
+
+func Test_rollingReload_startDrain(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+
+	node := newRemediationTestNode(nil, time.Now().Add(-time.Hour))
+	c, ctrlClient := newRollingReloadTestController(t, m, node,
+		newTestRollingReload(v1alpha1.HivelocityRollingReloadStatus{}))
+
+	got := reconcileTestRollingReload(t, c, ctrlClient)
+	require.Equal(t, v1alpha1.RollingReloadPhaseProgressing, got.Status.Phase)
+	require.Len(t, got.Status.Nodes, 1)
+	require.Equal(t, nodeName, got.Status.Nodes[0].Name)
+	require.Equal(t, v1alpha1.NodeReloadPhaseDraining, got.Status.Nodes[0].Phase)
+	require.NotNil(t, got.Status.Nodes[0].DrainStartTime)
+
+	updatedNode, err := c.kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.True(t, updatedNode.Spec.Unschedulable)
+}
+
+func Test_rollingReload_reload(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ReloadDevice", mock.Anything, int32(dummyDeviceID), hv.DeviceReload{OperatingSystemId: 42}).Return(nil)
+
+	node := newRemediationTestNode(nil, time.Now().Add(-time.Hour))
+	c, ctrlClient := newRollingReloadTestController(t, m, node,
+		newTestRollingReload(v1alpha1.HivelocityRollingReloadStatus{
+			Nodes: []v1alpha1.NodeReloadStatus{{
+				Name:           nodeName,
+				Phase:          v1alpha1.NodeReloadPhaseDraining,
+				DrainStartTime: &metav1.Time{Time: time.Now().Add(-time.Minute)},
+			}},
+		}))
+
+	got := reconcileTestRollingReload(t, c, ctrlClient)
+	require.Equal(t, v1alpha1.NodeReloadPhaseReloading, got.Status.Nodes[0].Phase)
+	require.NotNil(t, got.Status.Nodes[0].ReloadTime)
+}
+
+func Test_rollingReload_drainTimeout(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+
+	node := newRemediationTestNode(nil, time.Now().Add(-time.Hour))
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec:       corev1.PodSpec{NodeName: nodeName},
+	}
+	c, ctrlClient := newRollingReloadTestController(t, m, node,
+		newTestRollingReload(v1alpha1.HivelocityRollingReloadStatus{
+			Nodes: []v1alpha1.NodeReloadStatus{{
+				Name:           nodeName,
+				Phase:          v1alpha1.NodeReloadPhaseDraining,
+				DrainStartTime: &metav1.Time{Time: time.Now().Add(-time.Hour)},
+			}},
+		}), pod)
+
+	got := reconcileTestRollingReload(t, c, ctrlClient)
+	require.Equal(t, v1alpha1.NodeReloadPhaseFailed, got.Status.Nodes[0].Phase)
+	require.Equal(t, v1alpha1.RollingReloadPhasePaused, got.Status.Phase)
+	require.True(t, got.Spec.Paused)
+	m.AssertNotCalled(t, "ReloadDevice", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func Test_rollingReload_completed(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+
+	reloadTime := time.Now().Add(-10 * time.Minute)
+	node := newRemediationTestNode(nil, reloadTime.Add(5*time.Minute))
+	node.Spec.Unschedulable = true
+	c, ctrlClient := newRollingReloadTestController(t, m, node,
+		newTestRollingReload(v1alpha1.HivelocityRollingReloadStatus{
+			Phase: v1alpha1.RollingReloadPhaseProgressing,
+			Nodes: []v1alpha1.NodeReloadStatus{{
+				Name:       nodeName,
+				Phase:      v1alpha1.NodeReloadPhaseReloading,
+				ReloadTime: &metav1.Time{Time: reloadTime},
+			}},
+		}))
+
+	got := reconcileTestRollingReload(t, c, ctrlClient)
+	require.Equal(t, v1alpha1.NodeReloadPhaseDone, got.Status.Nodes[0].Phase)
+	require.Equal(t, v1alpha1.RollingReloadPhaseCompleted, got.Status.Phase)
+
+	updatedNode, err := c.kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.False(t, updatedNode.Spec.Unschedulable)
+}
+
+func Test_rollingReload_maxUnavailable(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+
+	// The failed node gets retried on resume, but sorts before the node
+	// which is still reloading, so it must not start.
+	node := newRemediationTestNode(nil, time.Now().Add(-time.Hour))
+	c, ctrlClient := newRollingReloadTestController(t, m, node,
+		newTestRollingReload(v1alpha1.HivelocityRollingReloadStatus{
+			Phase: v1alpha1.RollingReloadPhasePaused,
+			Nodes: []v1alpha1.NodeReloadStatus{
+				{Name: nodeName, Phase: v1alpha1.NodeReloadPhaseFailed},
+				{Name: "zz-node", Phase: v1alpha1.NodeReloadPhaseReloading, ReloadTime: &metav1.Time{Time: time.Now()}},
+			},
+		}))
+
+	got := reconcileTestRollingReload(t, c, ctrlClient)
+	require.Equal(t, v1alpha1.NodeReloadPhasePending, got.Status.Nodes[0].Phase)
+	require.Equal(t, v1alpha1.NodeReloadPhaseReloading, got.Status.Nodes[1].Phase)
+	require.Equal(t, v1alpha1.RollingReloadPhaseProgressing, got.Status.Phase)
+
+	updatedNode, err := c.kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.False(t, updatedNode.Spec.Unschedulable)
+}
+
+func Test_rollingReload_keepsCordon(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+
+	node := newRemediationTestNode(nil, time.Now().Add(-time.Hour))
+	node.Spec.Unschedulable = true
+	c, ctrlClient := newRollingReloadTestController(t, m, node,
+		newTestRollingReload(v1alpha1.HivelocityRollingReloadStatus{}))
+
+	got := reconcileTestRollingReload(t, c, ctrlClient)
+	require.Equal(t, v1alpha1.NodeReloadPhaseDraining, got.Status.Nodes[0].Phase)
+	require.Equal(t, pointer.Bool(true), got.Status.Nodes[0].WasUnschedulable)
+
+	reloadTime := time.Now().Add(-10 * time.Minute)
+	node = newRemediationTestNode(nil, reloadTime.Add(5*time.Minute))
+	node.Spec.Unschedulable = true
+	c, ctrlClient = newRollingReloadTestController(t, m, node,
+		newTestRollingReload(v1alpha1.HivelocityRollingReloadStatus{
+			Phase: v1alpha1.RollingReloadPhaseProgressing,
+			Nodes: []v1alpha1.NodeReloadStatus{{
+				Name:             nodeName,
+				Phase:            v1alpha1.NodeReloadPhaseReloading,
+				ReloadTime:       &metav1.Time{Time: reloadTime},
+				WasUnschedulable: pointer.Bool(true),
+			}},
+		}))
+
+	got = reconcileTestRollingReload(t, c, ctrlClient)
+	require.Equal(t, v1alpha1.NodeReloadPhaseDone, got.Status.Nodes[0].Phase)
+
+	updatedNode, err := c.kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.True(t, updatedNode.Spec.Unschedulable)
+}
+
+func Test_rollingReload_retryAfterReloadTimeout(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ReloadDevice", mock.Anything, int32(dummyDeviceID), hv.DeviceReload{OperatingSystemId: 42}).Return(nil)
+
+	// The reload timed out, the node is still cordoned by the reload.
+	node := newRemediationTestNode(nil, time.Now().Add(time.Hour))
+	node.Spec.Unschedulable = true
+	c, ctrlClient := newRollingReloadTestController(t, m, node,
+		newTestRollingReload(v1alpha1.HivelocityRollingReloadStatus{
+			Phase: v1alpha1.RollingReloadPhasePaused,
+			Nodes: []v1alpha1.NodeReloadStatus{{
+				Name:             nodeName,
+				Phase:            v1alpha1.NodeReloadPhaseFailed,
+				WasUnschedulable: pointer.Bool(false),
+			}},
+		}))
+
+	// Resume: Pending -> Draining -> Reloading -> Done.
+	for _, want := range []v1alpha1.NodeReloadPhase{
+		v1alpha1.NodeReloadPhaseDraining, v1alpha1.NodeReloadPhaseReloading, v1alpha1.NodeReloadPhaseDone,
+	} {
+		got := reconcileTestRollingReload(t, c, ctrlClient)
+		require.Equal(t, want, got.Status.Nodes[0].Phase)
+		require.Equal(t, pointer.Bool(false), got.Status.Nodes[0].WasUnschedulable)
+	}
+
+	updatedNode, err := c.kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+	require.NoError(t, err)
+	require.False(t, updatedNode.Spec.Unschedulable)
+}
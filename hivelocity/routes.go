@@ -0,0 +1,296 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/pkg/hvutils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+)
+
+var (
+	errNodeCacheNotSynced = errors.New("node cache is not synced yet")
+
+	errNoIPAssignment = errors.New("no IP assignment for CIDR")
+
+	errIPAssignmentInUse = errors.New("IP assignment is routed to a next hop outside of the cluster")
+
+	errNoNextHop = errors.New("node has no address of the IP family")
+)
+
+// routes implements cloudprovider.Routes. A route is an IP assignment whose
+// next hop is the address of a node. Hivelocity routes the traffic of the
+// whole subnet of the assignment to the next hop.
+//
+// Only assignments which are routed to a node of the cluster, or to a device
+// with the caphv-cluster-name tag of the cluster, belong to the cluster.
+// Other assignments are neither listed nor modified.
+type routes struct {
+	client      client.Interface
+	nodeLister  corelisters.NodeLister
+	nodesSynced cache.InformerSynced
+
+	// clusterName overrides the cluster name of the route controller, if the
+	// cloud is scoped to a cluster.
+	clusterName string
+}
+
+var _ cloudprovider.Routes = (*routes)(nil)
+
+func newRoutes(c client.Interface, nodeInformer coreinformers.NodeInformer, clusterName string) *routes {
+	return &routes{
+		client:      c,
+		nodeLister:  nodeInformer.Lister(),
+		nodesSynced: nodeInformer.Informer().HasSynced,
+		clusterName: clusterName,
+	}
+}
+
+// routeOwners contains the next hops which belong to the cluster.
+type routeOwners struct {
+	// nodes maps the addresses of the nodes to the node names.
+	nodes map[string]types.NodeName
+
+	// devices contains the primary IPs of the devices with the cluster tag.
+	devices map[string]bool
+}
+
+func (o routeOwners) owns(nextHop string) bool {
+	_, ok := o.nodes[nextHop]
+	return ok || o.devices[nextHop]
+}
+
+// ListRoutes implements cloudprovider.Routes.ListRoutes.
+// Routes to devices of the cluster without a node are returned as blackholes,
+// so that the route controller deletes them.
+func (r *routes) ListRoutes(ctx context.Context, clusterName string) ([]*cloudprovider.Route, error) {
+	owners, err := r.routeOwners(ctx, clusterName)
+	if err != nil {
+		return nil, fmt.Errorf("[ListRoutes] routeOwners() failed: %w", err)
+	}
+
+	assignments, err := r.client.ListIPAssignments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[ListRoutes] ListIPAssignments() failed: %w", err)
+	}
+
+	var result []*cloudprovider.Route
+	for _, assignment := range assignments {
+		if assignment.NextHopIp == "" || !owners.owns(assignment.NextHopIp) {
+			continue
+		}
+		cidr, err := normalizeCIDR(assignment.Subnet)
+		if err != nil {
+			klog.Warningf("[ListRoutes] skipping IP assignment %d: %v", assignment.AssignmentId, err)
+			continue
+		}
+		nodeName, ok := owners.nodes[assignment.NextHopIp]
+		result = append(result, &cloudprovider.Route{
+			Name:            routeName(assignment),
+			TargetNode:      nodeName,
+			DestinationCIDR: cidr,
+			Blackhole:       !ok,
+		})
+	}
+	return result, nil
+}
+
+// CreateRoute implements cloudprovider.Routes.CreateRoute.
+// The subnet of the route must already be assigned to the account. The
+// assignment gets routed to the address of the target node.
+func (r *routes) CreateRoute(ctx context.Context, clusterName string, _ string, route *cloudprovider.Route) error {
+	if !r.nodesSynced() {
+		return errNodeCacheNotSynced
+	}
+	node, err := r.nodeLister.Get(string(route.TargetNode))
+	if err != nil {
+		return fmt.Errorf("[CreateRoute] Get() failed. node %q: %w", route.TargetNode, err)
+	}
+	nextHop, err := nodeNextHop(node, route.DestinationCIDR)
+	if err != nil {
+		return fmt.Errorf("[CreateRoute] nodeNextHop() failed: %w", err)
+	}
+
+	assignment, err := r.findIPAssignment(ctx, route.DestinationCIDR)
+	if err != nil {
+		return fmt.Errorf("[CreateRoute] findIPAssignment() failed: %w", err)
+	}
+	if assignment.NextHopIp == nextHop {
+		return nil
+	}
+	if assignment.NextHopIp != "" {
+		if err := r.checkOwner(ctx, clusterName, assignment); err != nil {
+			return fmt.Errorf("[CreateRoute] checkOwner() failed: %w", err)
+		}
+	}
+
+	task, err := r.client.SetIPAssignmentNextHop(ctx, assignment.AssignmentId, nextHop)
+	if err != nil {
+		return fmt.Errorf("[CreateRoute] SetIPAssignmentNextHop() failed: %w", err)
+	}
+	klog.Infof("Routing %s to node %q (%s). Network task %q",
+		route.DestinationCIDR, route.TargetNode, nextHop, task.TaskId)
+	return nil
+}
+
+// DeleteRoute implements cloudprovider.Routes.DeleteRoute.
+// The routing of the assignment gets cleared, the assignment itself is kept.
+func (r *routes) DeleteRoute(ctx context.Context, clusterName string, route *cloudprovider.Route) error {
+	assignment, err := r.findIPAssignment(ctx, route.DestinationCIDR)
+	if errors.Is(err, errNoIPAssignment) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("[DeleteRoute] findIPAssignment() failed: %w", err)
+	}
+	if assignment.NextHopIp == "" {
+		return nil
+	}
+	if err := r.checkOwner(ctx, clusterName, assignment); err != nil {
+		return fmt.Errorf("[DeleteRoute] checkOwner() failed: %w", err)
+	}
+
+	task, err := r.client.ClearIPAssignment(ctx, assignment.AssignmentId)
+	if err != nil {
+		return fmt.Errorf("[DeleteRoute] ClearIPAssignment() failed: %w", err)
+	}
+	klog.Infof("Removed route of %s to %s. Network task %q",
+		route.DestinationCIDR, assignment.NextHopIp, task.TaskId)
+	return nil
+}
+
+func (r *routes) routeOwners(ctx context.Context, clusterName string) (routeOwners, error) {
+	if !r.nodesSynced() {
+		return routeOwners{}, errNodeCacheNotSynced
+	}
+	if r.clusterName != "" {
+		clusterName = r.clusterName
+	}
+	nodes, err := r.nodeLister.List(labels.Everything())
+	if err != nil {
+		return routeOwners{}, fmt.Errorf("[routeOwners] listing nodes failed: %w", err)
+	}
+	devices, err := r.client.ListDevices(ctx)
+	if err != nil {
+		return routeOwners{}, fmt.Errorf("[routeOwners] ListDevices() failed: %w", err)
+	}
+
+	owners := routeOwners{
+		nodes:   make(map[string]types.NodeName),
+		devices: make(map[string]bool),
+	}
+	for _, node := range nodes {
+		for _, address := range node.Status.Addresses {
+			if address.Type == corev1.NodeExternalIP || address.Type == corev1.NodeInternalIP {
+				owners.nodes[address.Address] = types.NodeName(node.Name)
+			}
+		}
+	}
+	for _, device := range devices {
+		if device.PrimaryIp != "" && hvutils.HasClusterNameTag(device.Tags, clusterName) {
+			owners.devices[device.PrimaryIp] = true
+		}
+	}
+	return owners, nil
+}
+
+// checkOwner returns errIPAssignmentInUse if the assignment is routed to a
+// next hop which does not belong to the cluster.
+func (r *routes) checkOwner(ctx context.Context, clusterName string, assignment *hv.IpAssignment) error {
+	owners, err := r.routeOwners(ctx, clusterName)
+	if err != nil {
+		return fmt.Errorf("[checkOwner] routeOwners() failed: %w", err)
+	}
+	if !owners.owns(assignment.NextHopIp) {
+		return fmt.Errorf("[checkOwner] assignment %d (%s), next hop %s: %w",
+			assignment.AssignmentId, assignment.Subnet, assignment.NextHopIp, errIPAssignmentInUse)
+	}
+	return nil
+}
+
+// findIPAssignment returns the assignment whose subnet is the CIDR.
+func (r *routes) findIPAssignment(ctx context.Context, cidr string) (*hv.IpAssignment, error) {
+	want, err := normalizeCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("[findIPAssignment] normalizeCIDR() failed: %w", err)
+	}
+	assignments, err := r.client.ListIPAssignments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[findIPAssignment] ListIPAssignments() failed: %w", err)
+	}
+	for i := range assignments {
+		subnet, err := normalizeCIDR(assignments[i].Subnet)
+		if err == nil && subnet == want {
+			return &assignments[i], nil
+		}
+	}
+	return nil, fmt.Errorf("[findIPAssignment] cidr %s: %w", cidr, errNoIPAssignment)
+}
+
+// nodeNextHop returns the address of the node which has the IP family of the CIDR.
+func nodeNextHop(node *corev1.Node, cidr string) (string, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", fmt.Errorf("[nodeNextHop] ParseCIDR() failed: %w", err)
+	}
+	return nodeAddress(node, ipNet.IP.To4() == nil)
+}
+
+// nodeAddress returns the external address of the node of the IP family.
+// The internal address is used if there is no such external address.
+func nodeAddress(node *corev1.Node, ipv6 bool) (string, error) {
+	for _, addressType := range []corev1.NodeAddressType{corev1.NodeExternalIP, corev1.NodeInternalIP} {
+		for _, address := range node.Status.Addresses {
+			if address.Type != addressType {
+				continue
+			}
+			ip := net.ParseIP(address.Address)
+			if ip != nil && (ip.To4() == nil) == ipv6 {
+				return ip.String(), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("[nodeAddress] node %q, ipv6 %t: %w", node.Name, ipv6, errNoNextHop)
+}
+
+// normalizeCIDR returns the canonical form of the CIDR, so that
+// "2001:DB8::/64" and "2001:db8::/64" are equal.
+func normalizeCIDR(cidr string) (string, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", fmt.Errorf("[normalizeCIDR] ParseCIDR() failed: %w", err)
+	}
+	return ipNet.String(), nil
+}
+
+func routeName(assignment hv.IpAssignment) string {
+	return "ip-assignment-" + strconv.Itoa(int(assignment.AssignmentId))
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	cloudprovider "k8s.io/cloud-provider"
+)
+
+const testClusterName = "prod"
+
+func newRoutesTestNode(name string, addresses ...corev1.NodeAddress) *corev1.Node {
+	node := newNode("hivelocity://12345", name)
+	node.Status.Addresses = addresses
+	return node
+}
+
+func newTestRoutes(t *testing.T, m *mocks.Interface, nodes ...*corev1.Node) *routes {
+	t.Helper()
+	return &routes{
+		client:      m,
+		nodeLister:  newTestNodeLister(t, nodes...),
+		nodesSynced: func() bool { return true },
+	}
+}
+
+func Test_ListRoutes(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ListDevices", mock.Anything).Return([]hv.BareMetalDevice{
+		{DeviceId: 1, PrimaryIp: "10.0.0.1", Tags: []string{"caphv-cluster-name=prod"}},
+		{DeviceId: 2, PrimaryIp: "10.0.0.2", Tags: []string{"caphv-cluster-name=prod"}},
+		{DeviceId: 3, PrimaryIp: "10.0.0.3", Tags: []string{"caphv-cluster-name=other"}},
+	}, nil)
+	m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{
+		{AssignmentId: 10, Subnet: "192.0.2.0/28", NextHopIp: "10.0.0.1"},
+		{AssignmentId: 11, Subnet: "192.0.2.16/28", NextHopIp: "10.0.0.2"},
+		{AssignmentId: 12, Subnet: "192.0.2.32/28", NextHopIp: "10.0.0.3"},
+		{AssignmentId: 13, Subnet: "192.0.2.48/28"},
+		{AssignmentId: 14, Subnet: "2001:DB8::/64", NextHopIp: "2001:db8:1::1"},
+	}, nil)
+
+	r := newTestRoutes(t, m,
+		newRoutesTestNode("node-1",
+			corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "10.0.0.1"},
+			corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "2001:db8:1::1"},
+		),
+	)
+
+	got, err := r.ListRoutes(context.Background(), testClusterName)
+	require.NoError(t, err)
+	require.Equal(t, []*cloudprovider.Route{
+		{Name: "ip-assignment-10", TargetNode: "node-1", DestinationCIDR: "192.0.2.0/28"},
+		{Name: "ip-assignment-11", DestinationCIDR: "192.0.2.16/28", Blackhole: true},
+		{Name: "ip-assignment-14", TargetNode: "node-1", DestinationCIDR: "2001:db8::/64"},
+	}, got)
+}
+
+func Test_CreateRoute(t *testing.T) {
+	t.Parallel()
+	node := newRoutesTestNode("node-1",
+		corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "172.16.0.1"},
+		corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "10.0.0.1"},
+	)
+	route := &cloudprovider.Route{TargetNode: "node-1", DestinationCIDR: "192.0.2.0/28"}
+
+	tests := []struct {
+		name       string
+		nextHop    string
+		devices    []hv.BareMetalDevice
+		wantUpdate bool
+		wantErr    error
+	}{
+		{
+			name:       "unrouted assignment",
+			wantUpdate: true,
+		},
+		{
+			name:    "already routed to the node",
+			nextHop: "10.0.0.1",
+		},
+		{
+			name:       "routed to another device of the cluster",
+			nextHop:    "10.0.0.2",
+			devices:    []hv.BareMetalDevice{{PrimaryIp: "10.0.0.2", Tags: []string{"caphv-cluster-name=prod"}}},
+			wantUpdate: true,
+		},
+		{
+			name:    "routed outside of the cluster",
+			nextHop: "10.0.0.3",
+			devices: []hv.BareMetalDevice{{PrimaryIp: "10.0.0.3", Tags: []string{"caphv-cluster-name=other"}}},
+			wantErr: errIPAssignmentInUse,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := mocks.NewInterface(t)
+			m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{
+				{AssignmentId: 10, Subnet: "192.0.2.0/28", NextHopIp: tt.nextHop},
+			}, nil)
+			if tt.devices != nil {
+				m.On("ListDevices", mock.Anything).Return(tt.devices, nil)
+			}
+			if tt.wantUpdate {
+				m.On("SetIPAssignmentNextHop", mock.Anything, int32(10), "10.0.0.1").
+					Return(&hv.NetworkTaskDump{TaskId: "task-1"}, nil)
+			}
+
+			err := newTestRoutes(t, m, node).CreateRoute(context.Background(), testClusterName, "", route)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func Test_CreateRoute_noAssignment(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{}, nil)
+	node := newRoutesTestNode("node-1", corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "10.0.0.1"})
+
+	err := newTestRoutes(t, m, node).CreateRoute(context.Background(), testClusterName, "",
+		&cloudprovider.Route{TargetNode: "node-1", DestinationCIDR: "192.0.2.0/28"})
+	require.ErrorIs(t, err, errNoIPAssignment)
+}
+
+func Test_DeleteRoute(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		nextHop   string
+		wantClear bool
+		wantErr   error
+	}{
+		{
+			name: "not routed",
+		},
+		{
+			name:      "routed to a device of the cluster",
+			nextHop:   "10.0.0.1",
+			wantClear: true,
+		},
+		{
+			name:    "routed outside of the cluster",
+			nextHop: "10.0.0.3",
+			wantErr: errIPAssignmentInUse,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			m := mocks.NewInterface(t)
+			m.On("ListIPAssignments", mock.Anything).Return([]hv.IpAssignment{
+				{AssignmentId: 10, Subnet: "192.0.2.0/28", NextHopIp: tt.nextHop},
+			}, nil)
+			if tt.nextHop != "" {
+				m.On("ListDevices", mock.Anything).Return([]hv.BareMetalDevice{
+					{PrimaryIp: "10.0.0.1", Tags: []string{"caphv-cluster-name=prod"}},
+				}, nil)
+			}
+			if tt.wantClear {
+				m.On("ClearIPAssignment", mock.Anything, int32(10)).Return(&hv.NetworkTaskDump{TaskId: "task-1"}, nil)
+			}
+
+			err := newTestRoutes(t, m).DeleteRoute(context.Background(), testClusterName,
+				&cloudprovider.Route{DestinationCIDR: "192.0.2.0/28"})
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func Test_nodeNextHop(t *testing.T) {
+	t.Parallel()
+	node := newRoutesTestNode("node-1",
+		corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "2001:db8:1::1"},
+		corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "10.0.0.1"},
+	)
+
+	nextHop, err := nodeNextHop(node, "192.0.2.0/28")
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.1", nextHop)
+
+	nextHop, err = nodeNextHop(node, "2001:db8::/64")
+	require.NoError(t, err)
+	require.Equal(t, "2001:db8:1::1", nextHop)
+
+	_, err = nodeNextHop(newRoutesTestNode("node-2"), "192.0.2.0/28")
+	require.ErrorIs(t, err, errNoNextHop)
+}
@@ -0,0 +1,233 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+// nodeConditionSwitchPortProblem is true if a switch port of the device is
+// disabled or has an unexpected MTU.
+const nodeConditionSwitchPortProblem corev1.NodeConditionType = "SwitchPortProblem"
+
+// switchPortStatusDisabled is the status of a disabled port. The API also knows ENABLED and UNKOWN.
+const switchPortStatusDisabled = "DISABLED"
+
+// switchPortsController checks the switch ports of the devices of all nodes.
+type switchPortsController struct {
+	client       client.Interface
+	kubeClient   kubernetes.Interface
+	nodeLister   corelisters.NodeLister
+	nodesSynced  cache.InformerSynced
+	recorder     record.EventRecorder
+	pollInterval time.Duration
+	expectedMTU  int
+	// exportedPorts are the series which were exported by the last poll.
+	exportedPorts map[switchPortSeries]bool
+}
+
+// switchPortSeries are the label values of the series of a switch port.
+type switchPortSeries struct {
+	node    string
+	port    string
+	private string
+}
+
+func startSwitchPortsController(
+	ctx context.Context,
+	initContext app.ControllerInitContext,
+	_ genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error) {
+	ctrl := newSwitchPortsController(
+		c.client,
+		completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName),
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		c.config.switchPorts,
+	)
+	go ctrl.Run(ctx)
+	return nil, true, nil
+}
+
+func newSwitchPortsController(
+	c client.Interface,
+	kubeClient kubernetes.Interface,
+	nodeInformer coreinformers.NodeInformer,
+	cfg switchPortsConfig,
+) *switchPortsController {
+	registerMetrics()
+	return &switchPortsController{
+		client:       c,
+		kubeClient:   kubeClient,
+		nodeLister:   nodeInformer.Lister(),
+		nodesSynced:  nodeInformer.Informer().HasSynced,
+		recorder:     newEventRecorder(kubeClient, switchPortsControllerName),
+		pollInterval: cfg.pollInterval,
+		expectedMTU:  cfg.expectedMTU,
+	}
+}
+
+// Run checks the switch ports until ctx is done.
+func (c *switchPortsController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.Info("Starting Hivelocity switch ports controller")
+	defer klog.Info("Shutting down Hivelocity switch ports controller")
+
+	if !cache.WaitForNamedCacheSync(switchPortsControllerName, ctx.Done(), c.nodesSynced) {
+		return
+	}
+
+	wait.UntilWithContext(ctx, c.reconcileNodes, c.pollInterval)
+}
+
+func (c *switchPortsController) reconcileNodes(ctx context.Context) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("[switchPortsController] listing nodes failed: %v", err)
+		return
+	}
+
+	ports, err := c.client.ListDevicePorts(ctx)
+	if err != nil {
+		klog.Errorf("[switchPortsController] ListDevicePorts() failed: %v", err)
+		return
+	}
+	portsByDevice := make(map[int32][]hv.DevicePort)
+	for _, port := range ports {
+		portsByDevice[port.DeviceId] = append(portsByDevice[port.DeviceId], port)
+	}
+
+	exported := make(map[switchPortSeries]bool)
+	for _, node := range nodes {
+		if err := c.reconcileNode(node, portsByDevice, exported); err != nil {
+			klog.Errorf("[switchPortsController] node %q: %v", node.Name, err)
+		}
+	}
+
+	// Ports of deleted nodes, and ports which were removed or renamed, are
+	// not exported any more.
+	for series := range c.exportedPorts {
+		if exported[series] {
+			continue
+		}
+		labels := map[string]string{"node": series.node, "port": series.port, "private": series.private}
+		switchPortEnabled.Delete(labels)
+		switchPortMTU.Delete(labels)
+	}
+	c.exportedPorts = exported
+}
+
+// reconcileNode exports the series of the ports of the node, and adds them to
+// exported.
+func (c *switchPortsController) reconcileNode(
+	node *corev1.Node,
+	portsByDevice map[int32][]hv.DevicePort,
+	exported map[switchPortSeries]bool,
+) error {
+	if !isNodeInitialized(node) {
+		return nil
+	}
+
+	deviceID, err := getHivelocityDeviceIDFromNode(node)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] getHivelocityDeviceIDFromNode() failed: %w", err)
+	}
+
+	ports := portsByDevice[deviceID]
+	for _, port := range ports {
+		enabled := 0.0
+		if strings.EqualFold(port.Status, "ENABLED") {
+			enabled = 1
+		}
+		series := switchPortSeries{node: node.Name, port: switchPortName(port), private: strconv.FormatBool(port.Private)}
+		switchPortEnabled.WithLabelValues(series.node, series.port, series.private).Set(enabled)
+		switchPortMTU.WithLabelValues(series.node, series.port, series.private).Set(float64(port.Mtu))
+		exported[series] = true
+	}
+
+	return updateNodeCondition(c.kubeClient, c.recorder, node, switchPortCondition(ports, c.expectedMTU))
+}
+
+// switchPortCondition returns the condition for the ports of a device. Ports
+// with unknown status are not reported as problem.
+func switchPortCondition(ports []hv.DevicePort, expectedMTU int) corev1.NodeCondition {
+	var down, wrongMTU []string
+	for _, port := range ports {
+		if strings.EqualFold(port.Status, switchPortStatusDisabled) {
+			down = append(down, switchPortName(port))
+		}
+		if expectedMTU > 0 && port.Mtu != 0 && int(port.Mtu) != expectedMTU {
+			wrongMTU = append(wrongMTU, fmt.Sprintf("%s (%d)", switchPortName(port), port.Mtu))
+		}
+	}
+
+	switch {
+	case len(down) > 0:
+		return corev1.NodeCondition{
+			Type:    nodeConditionSwitchPortProblem,
+			Status:  corev1.ConditionTrue,
+			Reason:  "SwitchPortDown",
+			Message: "Switch ports are disabled: " + strings.Join(down, ", "),
+		}
+	case len(wrongMTU) > 0:
+		return corev1.NodeCondition{
+			Type:   nodeConditionSwitchPortProblem,
+			Status: corev1.ConditionTrue,
+			Reason: "SwitchPortMTUMismatch",
+			Message: fmt.Sprintf("Switch ports do not have the expected MTU %d: %s",
+				expectedMTU, strings.Join(wrongMTU, ", ")),
+		}
+	default:
+		return corev1.NodeCondition{
+			Type:    nodeConditionSwitchPortProblem,
+			Status:  corev1.ConditionFalse,
+			Reason:  "SwitchPortsOK",
+			Message: "All switch ports are fine",
+		}
+	}
+}
+
+// switchPortName returns the name of the port, or its ID if it has no name.
+func switchPortName(port hv.DevicePort) string {
+	if port.Name != "" {
+		return port.Name
+	}
+	return strconv.Itoa(int(port.PortId))
+}
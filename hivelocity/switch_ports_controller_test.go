@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/component-base/metrics/testutil"
+)
+
+func Test_switchPortCondition(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		ports       []hv.DevicePort
+		expectedMTU int
+		wantStatus  corev1.ConditionStatus
+		wantReason  string
+	}{
+		{
+			name:       "no ports",
+			wantStatus: corev1.ConditionFalse,
+			wantReason: "SwitchPortsOK",
+		},
+		{
+			name: "all enabled",
+			ports: []hv.DevicePort{
+				{Name: "eth0", Status: "ENABLED", Mtu: 9000},
+				{PortId: 2, Status: "UNKOWN", Mtu: 9000},
+			},
+			expectedMTU: 9000,
+			wantStatus:  corev1.ConditionFalse,
+			wantReason:  "SwitchPortsOK",
+		},
+		{
+			name:        "mtu check disabled",
+			ports:       []hv.DevicePort{{Name: "eth0", Status: "ENABLED", Mtu: 1500}},
+			wantStatus:  corev1.ConditionFalse,
+			wantReason:  "SwitchPortsOK",
+			expectedMTU: 0,
+		},
+		{
+			name:        "mtu mismatch",
+			ports:       []hv.DevicePort{{Name: "eth0", Status: "ENABLED", Mtu: 1500}},
+			expectedMTU: 9000,
+			wantStatus:  corev1.ConditionTrue,
+			wantReason:  "SwitchPortMTUMismatch",
+		},
+		{
+			name: "down wins",
+			ports: []hv.DevicePort{
+				{Name: "eth0", Status: "ENABLED", Mtu: 1500},
+				{Name: "eth1", Status: "DISABLED", Mtu: 9000},
+			},
+			expectedMTU: 9000,
+			wantStatus:  corev1.ConditionTrue,
+			wantReason:  "SwitchPortDown",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			condition := switchPortCondition(tt.ports, tt.expectedMTU)
+			require.Equal(t, nodeConditionSwitchPortProblem, condition.Type)
+			require.Equal(t, tt.wantStatus, condition.Status)
+			require.Equal(t, tt.wantReason, condition.Reason)
+		})
+	}
+}
+
+func Test_switchPortsController_deletesRenamedPorts(t *testing.T) {
+	t.Parallel()
+	registerMetrics()
+	m := mocks.NewInterface(t)
+	m.On("ListDevicePorts", mock.Anything).Return([]hv.DevicePort{
+		{PortId: 1, DeviceId: 12345, Name: "eth0", Status: "ENABLED", Mtu: 9000},
+	}, nil).Once()
+	m.On("ListDevicePorts", mock.Anything).Return([]hv.DevicePort{
+		{PortId: 1, DeviceId: 12345, Name: "eth1", Status: "ENABLED", Mtu: 9000},
+	}, nil).Once()
+
+	node := newNode("hivelocity://12345", "switch-ports-renamed")
+	c := &switchPortsController{
+		client:      m,
+		kubeClient:  fake.NewSimpleClientset(node),
+		nodeLister:  newTestNodeLister(t, node),
+		recorder:    record.NewFakeRecorder(10),
+		expectedMTU: 9000,
+	}
+
+	c.reconcileNodes(context.Background())
+	c.reconcileNodes(context.Background())
+
+	enabled, err := testutil.GetGaugeMetricValue(switchPortEnabled.WithLabelValues("switch-ports-renamed", "eth1", "false"))
+	require.NoError(t, err)
+	require.Equal(t, float64(1), enabled)
+	labels := map[string]string{"node": "switch-ports-renamed", "port": "eth0", "private": "false"}
+	require.False(t, switchPortEnabled.Delete(labels))
+	require.False(t, switchPortMTU.Delete(labels))
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+)
+
+// Labels set by the topology controller.
+const (
+	// labelOrderGroup is the ID of the order group of the device.
+	labelOrderGroup = "hivelocity.net/order-group"
+
+	// labelRack is the same for all devices which are in the same rack. It is
+	// only known for order groups with SameRack. Use it as topologyKey.
+	labelRack = "hivelocity.net/rack"
+)
+
+// topologyLabels contains all labels which are managed by the topology controller.
+var topologyLabels = []string{labelOrderGroup, labelRack}
+
+// topologyController labels the nodes with the order group of their device.
+type topologyController struct {
+	client       client.Interface
+	kubeClient   kubernetes.Interface
+	nodeLister   corelisters.NodeLister
+	nodesSynced  cache.InformerSynced
+	pollInterval time.Duration
+}
+
+func startTopologyController(
+	ctx context.Context,
+	initContext app.ControllerInitContext,
+	_ genericcontrollermanager.ControllerContext,
+	completedConfig *cloudcontrollerconfig.CompletedConfig,
+	c *cloud,
+) (controller.Interface, bool, error) {
+	ctrl := newTopologyController(
+		c.client,
+		completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName),
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		c.config.topology.pollInterval,
+	)
+	go ctrl.Run(ctx)
+	return nil, true, nil
+}
+
+func newTopologyController(
+	c client.Interface,
+	kubeClient kubernetes.Interface,
+	nodeInformer coreinformers.NodeInformer,
+	pollInterval time.Duration,
+) *topologyController {
+	return &topologyController{
+		client:       c,
+		kubeClient:   kubeClient,
+		nodeLister:   nodeInformer.Lister(),
+		nodesSynced:  nodeInformer.Informer().HasSynced,
+		pollInterval: pollInterval,
+	}
+}
+
+// Run labels the nodes until ctx is done.
+func (c *topologyController) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+
+	klog.Info("Starting Hivelocity topology controller")
+	defer klog.Info("Shutting down Hivelocity topology controller")
+
+	if !cache.WaitForNamedCacheSync(topologyControllerName, ctx.Done(), c.nodesSynced) {
+		return
+	}
+
+	wait.UntilWithContext(ctx, c.reconcileNodes, c.pollInterval)
+}
+
+func (c *topologyController) reconcileNodes(ctx context.Context) {
+	nodes, err := c.nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("[topologyController] listing nodes failed: %v", err)
+		return
+	}
+
+	orderGroups, err := c.client.ListOrderGroups(ctx)
+	if err != nil {
+		klog.Errorf("[topologyController] ListOrderGroups() failed: %v", err)
+		return
+	}
+	orderGroupsByDevice := orderGroupsByDeviceID(orderGroups)
+
+	for _, node := range nodes {
+		if err := c.reconcileNode(ctx, node, orderGroupsByDevice); err != nil {
+			klog.Errorf("[topologyController] node %q: %v", node.Name, err)
+		}
+	}
+}
+
+func (c *topologyController) reconcileNode(
+	ctx context.Context,
+	node *corev1.Node,
+	orderGroupsByDevice map[int32]*hv.OrderGroup,
+) error {
+	if !isNodeInitialized(node) {
+		return nil
+	}
+
+	deviceID, err := getHivelocityDeviceIDFromNode(node)
+	if err != nil {
+		return fmt.Errorf("[reconcileNode] getHivelocityDeviceIDFromNode() failed: %w", err)
+	}
+
+	desired := make(map[string]string)
+	if orderGroup, ok := orderGroupsByDevice[deviceID]; ok {
+		id := strconv.Itoa(int(orderGroup.Id))
+		desired[labelOrderGroup] = id
+		if orderGroup.SameRack {
+			desired[labelRack] = "order-group-" + id
+		}
+	}
+	return patchNodeLabels(ctx, c.kubeClient, node, topologyLabels, desired)
+}
+
+// orderGroupsByDeviceID maps the devices to their order group. If a device is
+// in several order groups, the one with the lowest ID wins.
+func orderGroupsByDeviceID(orderGroups []hv.OrderGroup) map[int32]*hv.OrderGroup {
+	result := make(map[int32]*hv.OrderGroup)
+	for i := range orderGroups {
+		orderGroup := &orderGroups[i]
+		for _, deviceID := range orderGroup.DeviceIds {
+			if current, ok := result[deviceID]; ok && current.Id < orderGroup.Id {
+				continue
+			}
+			result[deviceID] = orderGroup
+		}
+	}
+	return result
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_topology_reconcileNode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		orderGroups []hv.OrderGroup
+		nodeLabels  map[string]string
+		wantLabels  map[string]string
+	}{
+		{
+			name:        "same rack",
+			orderGroups: []hv.OrderGroup{{Id: 3, SameRack: true, DeviceIds: []int32{1, dummyDeviceID}}},
+			wantLabels:  map[string]string{labelOrderGroup: "3", labelRack: "order-group-3"},
+		},
+		{
+			name:        "not same rack, lowest ID wins",
+			orderGroups: []hv.OrderGroup{{Id: 9, SameRack: true, DeviceIds: []int32{dummyDeviceID}}, {Id: 4, DeviceIds: []int32{dummyDeviceID}}},
+			nodeLabels:  map[string]string{labelRack: "order-group-9"},
+			wantLabels:  map[string]string{labelOrderGroup: "4"},
+		},
+		{
+			name:       "no order group",
+			nodeLabels: map[string]string{labelOrderGroup: "4", "other": "x"},
+			wantLabels: map[string]string{"other": "x"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			node := newNode("hivelocity://12345", nodeName)
+			node.Labels = tt.nodeLabels
+			kubeClient := fake.NewSimpleClientset(node)
+			c := &topologyController{kubeClient: kubeClient}
+
+			require.NoError(t, c.reconcileNode(context.Background(), node, orderGroupsByDeviceID(tt.orderGroups)))
+
+			updatedNode, err := kubeClient.CoreV1().Nodes().Get(context.Background(), nodeName, metav1.GetOptions{})
+			require.NoError(t, err)
+			require.Equal(t, len(tt.wantLabels), len(updatedNode.Labels))
+			for key, value := range tt.wantLabels {
+				require.Equal(t, value, updatedNode.Labels[key])
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"fmt"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	cloudprovider "k8s.io/cloud-provider"
+)
+
+// zones implements the legacy cloudprovider.Zones for components which do
+// not read the topology labels of the nodes. The devices are looked up like
+// in HVInstancesV2, so the zones match the ones of InstanceMetadata.
+type zones struct {
+	instancesV2 *HVInstancesV2
+
+	// nodeName is the name of the node the CCM runs on, used by GetZone.
+	nodeName string
+}
+
+var _ cloudprovider.Zones = &zones{}
+
+func newZones(i2 *HVInstancesV2, nodeName string) *zones {
+	return &zones{instancesV2: i2, nodeName: nodeName}
+}
+
+// deviceZone returns the failure domain of the device. There is one zone per
+// location of Hivelocity, so the region is the location too.
+func deviceZone(device *hv.BareMetalDevice) cloudprovider.Zone {
+	return cloudprovider.Zone{
+		FailureDomain: device.LocationName, // for example LAX1
+		Region:        device.LocationName, // for example LAX1
+	}
+}
+
+// GetZone returns the zone of the node the CCM runs on, from the environment variable NODE_NAME.
+// Implements cloudprovider.Zones.GetZone.
+func (z *zones) GetZone(ctx context.Context) (cloudprovider.Zone, error) {
+	if z.nodeName == "" {
+		return cloudprovider.Zone{}, fmt.Errorf("[GetZone] %s is empty: %w", nodeNameENVVar, cloudprovider.NotImplemented)
+	}
+	return z.GetZoneByNodeName(ctx, types.NodeName(z.nodeName))
+}
+
+// GetZoneByProviderID returns the zone of the device with the provider ID "hivelocity://<deviceID>".
+// Implements cloudprovider.Zones.GetZoneByProviderID.
+func (z *zones) GetZoneByProviderID(ctx context.Context, providerID string) (cloudprovider.Zone, error) {
+	return z.getZone(ctx, &corev1.Node{Spec: corev1.NodeSpec{ProviderID: providerID}})
+}
+
+// GetZoneByNodeName returns the zone of the device with the machine name tag of the node.
+// Implements cloudprovider.Zones.GetZoneByNodeName.
+func (z *zones) GetZoneByNodeName(ctx context.Context, nodeName types.NodeName) (cloudprovider.Zone, error) {
+	return z.getZone(ctx, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: string(nodeName)}})
+}
+
+func (z *zones) getZone(ctx context.Context, node *corev1.Node) (cloudprovider.Zone, error) {
+	device, err := z.instancesV2.lookUpDevice(ctx, node)
+	if err != nil {
+		return cloudprovider.Zone{}, fmt.Errorf("[getZone] lookUpDevice() failed: %w", err)
+	}
+	if device == nil {
+		return cloudprovider.Zone{}, fmt.Errorf("[getZone] node %q, providerID %q: %w",
+			node.Name, node.Spec.ProviderID, cloudprovider.InstanceNotFound)
+	}
+	return deviceZone(device), nil
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hivelocity
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	cloudprovider "k8s.io/cloud-provider"
+)
+
+func Test_zones(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	ctx := context.Background()
+	standardMocks(m)
+	i2 := newHVInstanceV2(m)
+	wantZone := cloudprovider.Zone{FailureDomain: region, Region: region}
+
+	metaData, err := i2.InstanceMetadata(ctx, newNode(fmt.Sprintf("hivelocity://%d", dummyDeviceID), nodeName))
+	require.NoError(t, err)
+	require.Equal(t, wantZone, cloudprovider.Zone{FailureDomain: metaData.Zone, Region: metaData.Region})
+
+	z := newZones(i2, nodeName)
+	zone, err := z.GetZoneByProviderID(ctx, fmt.Sprintf("hivelocity://%d", dummyDeviceID))
+	require.NoError(t, err)
+	require.Equal(t, wantZone, zone)
+
+	_, err = z.GetZoneByProviderID(ctx, fmt.Sprintf("hivelocity://%d", unknownDeviceID))
+	require.ErrorIs(t, err, cloudprovider.InstanceNotFound)
+
+	_, err = z.GetZoneByProviderID(ctx, "12345")
+	require.ErrorIs(t, err, errMissingProviderPrefix)
+
+	zone, err = z.GetZoneByNodeName(ctx, types.NodeName(nodeName))
+	require.NoError(t, err)
+	require.Equal(t, wantZone, zone)
+
+	_, err = z.GetZoneByNodeName(ctx, "unknown")
+	require.ErrorIs(t, err, cloudprovider.InstanceNotFound)
+
+	zone, err = z.GetZone(ctx)
+	require.NoError(t, err)
+	require.Equal(t, wantZone, zone)
+
+	_, err = newZones(i2, "").GetZone(ctx)
+	require.ErrorIs(t, err, cloudprovider.NotImplemented)
+}
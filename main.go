@@ -23,9 +23,10 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"os"
 
-	_ "github.com/hivelocity/hivelocity-cloud-controller-manager/hivelocity"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/hivelocity"
 	"k8s.io/apimachinery/pkg/util/wait"
 	cloudprovider "k8s.io/cloud-provider"
 	"k8s.io/cloud-provider/app"
@@ -45,6 +46,10 @@ func main() {
 	}
 
 	controllerInitializers := app.DefaultInitFuncConstructors
+	for name, constructor := range hivelocity.ControllerInitFuncConstructors() {
+		controllerInitializers[name] = constructor
+	}
+	app.ControllersDisabledByDefault.Insert(hivelocity.ControllersDisabledByDefault.List()...)
 
 	fss := cliflag.NamedFlagSets{}
 	command := app.NewCloudControllerManagerCommand(
@@ -68,21 +73,22 @@ func cloudInitializer(config *cloudcontrollerconfig.CompletedConfig) cloudprovid
 		klog.Fatalf("Cloud provider is nil")
 	}
 
-	/*
-		AFAIK this HasClusterID() is not needed
-		https://github.com/kubernetes/cloud-provider/issues/12
-		if !cloud.HasClusterID() {
-			if config.ComponentConfig.KubeCloudShared.AllowUntaggedCloud {
-				klog.Warning("detected a cluster without a ClusterID. " +
-				    "A ClusterID will be required in the future. "+
-					"Please tag your cluster to avoid any future issues")
-			} else {
-				klog.Fatalf("no ClusterID found. A ClusterID is required " +
-				    "for the cloud provider to function properly. " +
-					"This check can be bypassed by setting the allow-untagged-cloud option")
-			}
+	clusterName := config.ComponentConfig.KubeCloudShared.ClusterName
+	if err := hivelocity.InitClusterName(context.Background(), cloud, clusterName); err != nil {
+		klog.Fatalf("Cluster name could not be initialized: %v", err)
+	}
+
+	if !cloud.HasClusterID() {
+		if config.ComponentConfig.KubeCloudShared.AllowUntaggedCloud {
+			klog.Warning("detected a cluster without a ClusterID. " +
+				"A ClusterID will be required in the future. " +
+				"Please tag your cluster to avoid any future issues")
+		} else {
+			klog.Fatalf("no ClusterID found. A ClusterID is required " +
+				"for the cloud provider to function properly. " +
+				"This check can be bypassed by setting the allow-untagged-cloud option")
 		}
-	*/
+	}
 
 	return cloud
 }
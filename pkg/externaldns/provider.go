@@ -0,0 +1,426 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package externaldns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client"
+	"k8s.io/klog/v2"
+)
+
+// DefaultTTL is the TTL of endpoints without TTL. The API requires a TTL.
+const DefaultTTL = 3600
+
+var (
+	// ErrInvalidAddress means that the target of an AAAA endpoint is no IP address.
+	ErrInvalidAddress = errors.New("invalid address")
+
+	// ErrInvalidMXTarget means that the target of an MX endpoint is not "<preference> <exchange>".
+	ErrInvalidMXTarget = errors.New("invalid MX target")
+
+	// ErrNoSuchZone means that a configured zone does not exist in the account.
+	ErrNoSuchZone = errors.New("no such zone")
+
+	// ErrNoZones means that no zone is configured. external-dns runs with
+	// --registry=noop, so it would delete the records of all zones of the
+	// account which it does not know.
+	ErrNoZones = errors.New("no zones")
+)
+
+// Provider reads and changes the A, AAAA and MX records of the zones of the
+// account which are configured. A records contain all addresses of a name. AAAA and MX records
+// contain a single target, so an endpoint maps to several records.
+type Provider struct {
+	client client.Interface
+
+	// zones are the names of the zones.
+	zones []string
+}
+
+// zone is a zone of the account.
+type zone struct {
+	id   int32
+	name string
+}
+
+// zoneRecords are the records of a zone, by name.
+type zoneRecords struct {
+	a    map[string]hv.ARecord
+	aaaa map[string][]hv.AaaaRecordReturn
+	mx   map[string][]hv.MxRecordReturn
+}
+
+// NewProvider creates a Provider for the zones. Returns ErrNoZones if zones is empty.
+func NewProvider(c client.Interface, zones []string) (*Provider, error) {
+	if len(zones) == 0 {
+		return nil, fmt.Errorf("[NewProvider] %w", ErrNoZones)
+	}
+	normalized := make([]string, 0, len(zones))
+	for _, z := range zones {
+		normalized = append(normalized, normalizeName(z))
+	}
+	return &Provider{client: c, zones: normalized}, nil
+}
+
+// DomainFilter returns the zones of the provider.
+func (p *Provider) DomainFilter(context.Context) (DomainFilter, error) {
+	return DomainFilter{Include: p.zones}, nil
+}
+
+// Records returns the endpoints of all records of the zones.
+func (p *Provider) Records(ctx context.Context) ([]*Endpoint, error) {
+	zones, err := p.listZones(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[Records] listZones() failed: %w", err)
+	}
+
+	var endpoints []*Endpoint
+	for _, z := range zones {
+		records, err := p.listRecords(ctx, z)
+		if err != nil {
+			return nil, fmt.Errorf("[Records] listRecords() failed: %w", err)
+		}
+		for name, record := range records.a {
+			endpoints = append(endpoints, &Endpoint{
+				DNSName:    name,
+				RecordType: RecordTypeA,
+				Targets:    sortedCopy(record.Addresses),
+				RecordTTL:  int64(record.Ttl),
+			})
+		}
+		for name, aaaaRecords := range records.aaaa {
+			ep := &Endpoint{DNSName: name, RecordType: RecordTypeAAAA, RecordTTL: int64(aaaaRecords[0].Ttl)}
+			for _, record := range aaaaRecords {
+				ep.Targets = append(ep.Targets, record.Address)
+			}
+			sort.Strings(ep.Targets)
+			endpoints = append(endpoints, ep)
+		}
+		for name, mxRecords := range records.mx {
+			ep := &Endpoint{DNSName: name, RecordType: RecordTypeMX, RecordTTL: int64(mxRecords[0].Ttl)}
+			for _, record := range mxRecords {
+				ep.Targets = append(ep.Targets, mxTarget(record.Preference, record.Exchange))
+			}
+			sort.Strings(ep.Targets)
+			endpoints = append(endpoints, ep)
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].DNSName != endpoints[j].DNSName {
+			return endpoints[i].DNSName < endpoints[j].DNSName
+		}
+		return endpoints[i].RecordType < endpoints[j].RecordType
+	})
+	return endpoints, nil
+}
+
+// AdjustEndpoints drops the endpoints of unsupported record types and sets
+// the defaults of the API, so that the endpoints are equal to the ones
+// returned by Records.
+func (*Provider) AdjustEndpoints(endpoints []*Endpoint) []*Endpoint {
+	adjusted := make([]*Endpoint, 0, len(endpoints))
+	for _, ep := range endpoints {
+		switch ep.RecordType {
+		case RecordTypeA, RecordTypeAAAA, RecordTypeMX:
+		default:
+			klog.Warningf("[AdjustEndpoints] record type %s of %s is not supported", ep.RecordType, ep.DNSName)
+			continue
+		}
+		ep.DNSName = normalizeName(ep.DNSName)
+		if ep.RecordTTL <= 0 {
+			ep.RecordTTL = DefaultTTL
+		}
+		ep.Targets = sortedCopy(ep.Targets)
+		adjusted = append(adjusted, ep)
+	}
+	return adjusted
+}
+
+// ApplyChanges changes the records. The targets of the deleted endpoints get
+// removed first, then the records of the updated and created endpoints are
+// set to their targets.
+func (p *Provider) ApplyChanges(ctx context.Context, changes *Changes) error {
+	zones, err := p.listZones(ctx)
+	if err != nil {
+		return fmt.Errorf("[ApplyChanges] listZones() failed: %w", err)
+	}
+
+	records := make(map[int32]*zoneRecords)
+	apply := func(ep *Endpoint, deleted bool) error {
+		name := normalizeName(ep.DNSName)
+		z := zoneOf(zones, name)
+		if z == nil {
+			klog.Warningf("[ApplyChanges] %s is not in a zone of the provider", name)
+			return nil
+		}
+		current, ok := records[z.id]
+		if !ok {
+			current, err = p.listRecords(ctx, *z)
+			if err != nil {
+				return fmt.Errorf("listRecords() failed: %w", err)
+			}
+			records[z.id] = current
+		}
+		var targets []string
+		if !deleted {
+			targets = ep.Targets
+		}
+		ttl := int32(ep.RecordTTL)
+		if ttl <= 0 {
+			ttl = DefaultTTL
+		}
+
+		switch ep.RecordType {
+		case RecordTypeA:
+			return p.setA(ctx, *z, current, name, targets, ttl)
+		case RecordTypeAAAA:
+			return p.setAAAA(ctx, *z, current, name, targets, ttl)
+		case RecordTypeMX:
+			return p.setMX(ctx, *z, current, name, targets, ttl)
+		default:
+			klog.Warningf("[ApplyChanges] record type %s of %s is not supported", ep.RecordType, name)
+			return nil
+		}
+	}
+
+	for _, ep := range changes.Delete {
+		if err := apply(ep, true); err != nil {
+			return fmt.Errorf("[ApplyChanges] deleting %s %s: %w", ep.RecordType, ep.DNSName, err)
+		}
+	}
+	for _, ep := range append(changes.UpdateNew, changes.Create...) {
+		if err := apply(ep, false); err != nil {
+			return fmt.Errorf("[ApplyChanges] setting %s %s: %w", ep.RecordType, ep.DNSName, err)
+		}
+	}
+	return nil
+}
+
+// setA sets the A record of name to the addresses. No addresses delete the record.
+func (p *Provider) setA(ctx context.Context, z zone, current *zoneRecords, name string, addresses []string, ttl int32) error {
+	record, exists := current.a[name]
+	desired := hv.ARecord{Name: name, Addresses: sortedCopy(addresses), Ttl: ttl}
+	switch {
+	case !exists && len(addresses) == 0:
+		return nil
+	case !exists:
+		if err := p.client.CreateARecord(ctx, z.id, desired); err != nil {
+			return fmt.Errorf("[setA] CreateARecord() failed: %w", err)
+		}
+	case len(addresses) == 0:
+		if err := p.client.DeleteARecord(ctx, z.id, record.Name); err != nil {
+			return fmt.Errorf("[setA] DeleteARecord() failed: %w", err)
+		}
+		delete(current.a, name)
+		return nil
+	default:
+		desired.Name = record.Name
+		if err := p.client.UpdateARecord(ctx, z.id, desired); err != nil {
+			return fmt.Errorf("[setA] UpdateARecord() failed: %w", err)
+		}
+	}
+	current.a[name] = desired
+	return nil
+}
+
+// setAAAA sets the AAAA records of name to the addresses.
+func (p *Provider) setAAAA(ctx context.Context, z zone, current *zoneRecords, name string, addresses []string, ttl int32) error {
+	wanted := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return fmt.Errorf("[setAAAA] %q is no IP address: %w", address, ErrInvalidAddress)
+		}
+		wanted[ip.String()] = true
+	}
+
+	var kept []hv.AaaaRecordReturn
+	for _, record := range current.aaaa[name] {
+		address := net.ParseIP(record.Address).String()
+		if wanted[address] && record.Ttl == ttl {
+			delete(wanted, address)
+			kept = append(kept, record)
+			continue
+		}
+		if err := p.client.DeleteAAAARecord(ctx, z.id, record.Id); err != nil {
+			return fmt.Errorf("[setAAAA] DeleteAAAARecord() failed: %w", err)
+		}
+	}
+	for _, address := range sortedKeys(wanted) {
+		record := hv.AaaaRecordCreate{Name: name, Address: address, Ttl: ttl}
+		if err := p.client.CreateAAAARecord(ctx, z.id, record); err != nil {
+			return fmt.Errorf("[setAAAA] CreateAAAARecord() failed: %w", err)
+		}
+		kept = append(kept, hv.AaaaRecordReturn{Name: name, Address: address, Ttl: ttl})
+	}
+	current.aaaa[name] = kept
+	return nil
+}
+
+// setMX sets the MX records of name to the targets "<preference> <exchange>".
+func (p *Provider) setMX(ctx context.Context, z zone, current *zoneRecords, name string, targets []string, ttl int32) error {
+	wanted := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		preference, exchange, err := parseMXTarget(target)
+		if err != nil {
+			return fmt.Errorf("[setMX] parseMXTarget() failed: %w", err)
+		}
+		wanted[mxTarget(preference, exchange)] = true
+	}
+
+	var kept []hv.MxRecordReturn
+	for _, record := range current.mx[name] {
+		target := mxTarget(record.Preference, record.Exchange)
+		if wanted[target] && record.Ttl == ttl {
+			delete(wanted, target)
+			kept = append(kept, record)
+			continue
+		}
+		if err := p.client.DeleteMXRecord(ctx, z.id, record.Id); err != nil {
+			return fmt.Errorf("[setMX] DeleteMXRecord() failed: %w", err)
+		}
+	}
+	for _, target := range sortedKeys(wanted) {
+		preference, exchange, _ := parseMXTarget(target)
+		record := hv.MxRecordCreate{Name: name, Preference: preference, Exchange: exchange, Ttl: ttl}
+		if err := p.client.CreateMXRecord(ctx, z.id, record); err != nil {
+			return fmt.Errorf("[setMX] CreateMXRecord() failed: %w", err)
+		}
+		kept = append(kept, hv.MxRecordReturn{Name: name, Preference: preference, Exchange: exchange, Ttl: ttl})
+	}
+	current.mx[name] = kept
+	return nil
+}
+
+// listZones returns the zones of the provider. All of them must exist in the account.
+func (p *Provider) listZones(ctx context.Context) ([]zone, error) {
+	domains, err := p.client.ListDomains(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("[listZones] ListDomains() failed: %w", err)
+	}
+	byName := make(map[string]zone, len(domains))
+	for _, domain := range domains {
+		name := normalizeName(domain.Name)
+		byName[name] = zone{id: domain.DomainId, name: name}
+	}
+
+	zones := make([]zone, 0, len(p.zones))
+	for _, name := range p.zones {
+		z, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("[listZones] zone %q: %w", name, ErrNoSuchZone)
+		}
+		zones = append(zones, z)
+	}
+	return zones, nil
+}
+
+// listRecords returns the records of the zone.
+func (p *Provider) listRecords(ctx context.Context, z zone) (*zoneRecords, error) {
+	records := &zoneRecords{
+		a:    make(map[string]hv.ARecord),
+		aaaa: make(map[string][]hv.AaaaRecordReturn),
+		mx:   make(map[string][]hv.MxRecordReturn),
+	}
+
+	aRecords, err := p.client.ListARecords(ctx, z.id)
+	if err != nil {
+		return nil, fmt.Errorf("[listRecords] ListARecords() failed: %w", err)
+	}
+	for _, record := range aRecords {
+		records.a[normalizeName(record.Name)] = record
+	}
+
+	aaaaRecords, err := p.client.ListAAAARecords(ctx, z.id)
+	if err != nil {
+		return nil, fmt.Errorf("[listRecords] ListAAAARecords() failed: %w", err)
+	}
+	for _, record := range aaaaRecords {
+		name := normalizeName(record.Name)
+		records.aaaa[name] = append(records.aaaa[name], record)
+	}
+
+	mxRecords, err := p.client.ListMXRecords(ctx, z.id)
+	if err != nil {
+		return nil, fmt.Errorf("[listRecords] ListMXRecords() failed: %w", err)
+	}
+	for _, record := range mxRecords {
+		name := normalizeName(record.Name)
+		records.mx[name] = append(records.mx[name], record)
+	}
+	return records, nil
+}
+
+// zoneOf returns the most specific zone which contains name, nil if there is none.
+func zoneOf(zones []zone, name string) *zone {
+	var best *zone
+	for i := range zones {
+		z := &zones[i]
+		if name != z.name && !strings.HasSuffix(name, "."+z.name) {
+			continue
+		}
+		if best == nil || len(z.name) > len(best.name) {
+			best = z
+		}
+	}
+	return best
+}
+
+// parseMXTarget splits the target "<preference> <exchange>" of external-dns.
+func parseMXTarget(target string) (int32, string, error) {
+	fields := strings.Fields(target)
+	if len(fields) != 2 {
+		return 0, "", fmt.Errorf("[parseMXTarget] %q: %w", target, ErrInvalidMXTarget)
+	}
+	preference, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return 0, "", fmt.Errorf("[parseMXTarget] %q: %w", target, ErrInvalidMXTarget)
+	}
+	return int32(preference), normalizeName(fields[1]), nil
+}
+
+func mxTarget(preference int32, exchange string) string {
+	return fmt.Sprintf("%d %s", preference, normalizeName(exchange))
+}
+
+// normalizeName returns the name in lower case without trailing dot.
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+func sortedCopy(values []string) []string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
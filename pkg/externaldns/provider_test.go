@@ -0,0 +1,201 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package externaldns
+
+import (
+	"context"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProvider(t *testing.T) (*Provider, *mocks.Interface) {
+	t.Helper()
+	m := mocks.NewInterface(t)
+	m.On("ListDomains", mock.Anything).Return([]hv.DomainReturn{
+		{DomainId: 7, Name: "example.com"},
+		{DomainId: 42, Name: "sub.example.com."},
+		{DomainId: 9, Name: "example.org"},
+	}, nil).Maybe()
+	p, err := NewProvider(m, []string{"example.com", "Sub.Example.com."})
+	require.NoError(t, err)
+	return p, m
+}
+
+func mockRecords(m *mocks.Interface, domainID int32, a []hv.ARecord, aaaa []hv.AaaaRecordReturn, mx []hv.MxRecordReturn) {
+	m.On("ListARecords", mock.Anything, domainID).Return(a, nil).Maybe()
+	m.On("ListAAAARecords", mock.Anything, domainID).Return(aaaa, nil).Maybe()
+	m.On("ListMXRecords", mock.Anything, domainID).Return(mx, nil).Maybe()
+}
+
+func Test_Provider_DomainFilter(t *testing.T) {
+	t.Parallel()
+	p, _ := newTestProvider(t)
+	filter, err := p.DomainFilter(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"example.com", "sub.example.com"}, filter.Include)
+
+	_, err = NewProvider(mocks.NewInterface(t), nil)
+	require.ErrorIs(t, err, ErrNoZones)
+}
+
+func Test_Provider_Records(t *testing.T) {
+	t.Parallel()
+	p, m := newTestProvider(t)
+	mockRecords(m, 7,
+		[]hv.ARecord{{Name: "www.example.com.", Addresses: []string{"198.51.100.2", "198.51.100.1"}, Ttl: 60}},
+		[]hv.AaaaRecordReturn{
+			{Id: 1, Name: "www.example.com", Address: "2001:db8::2", Ttl: 60},
+			{Id: 2, Name: "www.example.com", Address: "2001:db8::1", Ttl: 60},
+		},
+		[]hv.MxRecordReturn{{Id: 3, Name: "example.com", Preference: 10, Exchange: "mail.example.com.", Ttl: 300}},
+	)
+	mockRecords(m, 42, []hv.ARecord{{Name: "a.sub.example.com", Addresses: []string{"198.51.100.3"}, Ttl: 60}}, nil, nil)
+
+	endpoints, err := p.Records(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []*Endpoint{
+		{DNSName: "a.sub.example.com", RecordType: RecordTypeA, Targets: []string{"198.51.100.3"}, RecordTTL: 60},
+		{DNSName: "example.com", RecordType: RecordTypeMX, Targets: []string{"10 mail.example.com"}, RecordTTL: 300},
+		{DNSName: "www.example.com", RecordType: RecordTypeA, Targets: []string{"198.51.100.1", "198.51.100.2"}, RecordTTL: 60},
+		{DNSName: "www.example.com", RecordType: RecordTypeAAAA, Targets: []string{"2001:db8::1", "2001:db8::2"}, RecordTTL: 60},
+	}, endpoints)
+}
+
+func Test_Provider_AdjustEndpoints(t *testing.T) {
+	t.Parallel()
+	p, _ := newTestProvider(t)
+	adjusted := p.AdjustEndpoints([]*Endpoint{
+		{DNSName: "WWW.example.com.", RecordType: RecordTypeA, Targets: []string{"198.51.100.2", "198.51.100.1"}},
+		{DNSName: "www.example.com", RecordType: "TXT", Targets: []string{"heritage=external-dns"}},
+		{DNSName: "example.com", RecordType: RecordTypeMX, Targets: []string{"10 mail.example.com"}, RecordTTL: 300},
+	})
+	require.Equal(t, []*Endpoint{
+		{DNSName: "www.example.com", RecordType: RecordTypeA, Targets: []string{"198.51.100.1", "198.51.100.2"}, RecordTTL: DefaultTTL},
+		{DNSName: "example.com", RecordType: RecordTypeMX, Targets: []string{"10 mail.example.com"}, RecordTTL: 300},
+	}, adjusted)
+}
+
+func Test_Provider_ApplyChanges(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		changes Changes
+		setup   func(m *mocks.Interface)
+		wantErr error
+	}{
+		{
+			name: "create A record in the most specific zone",
+			changes: Changes{Create: []*Endpoint{
+				{DNSName: "web.sub.example.com", RecordType: RecordTypeA, Targets: []string{"198.51.100.2", "198.51.100.1"}},
+			}},
+			setup: func(m *mocks.Interface) {
+				m.On("CreateARecord", mock.Anything, int32(42), hv.ARecord{
+					Name: "web.sub.example.com", Addresses: []string{"198.51.100.1", "198.51.100.2"}, Ttl: DefaultTTL,
+				}).Return(nil)
+			},
+		},
+		{
+			name: "update A record",
+			changes: Changes{
+				UpdateOld: []*Endpoint{{DNSName: "www.example.com", RecordType: RecordTypeA, Targets: []string{"198.51.100.1"}, RecordTTL: 60}},
+				UpdateNew: []*Endpoint{{DNSName: "www.example.com", RecordType: RecordTypeA, Targets: []string{"198.51.100.9"}, RecordTTL: 60}},
+			},
+			setup: func(m *mocks.Interface) {
+				m.On("UpdateARecord", mock.Anything, int32(7), hv.ARecord{
+					Name: "www.example.com.", Addresses: []string{"198.51.100.9"}, Ttl: 60,
+				}).Return(nil)
+			},
+		},
+		{
+			name: "delete A record",
+			changes: Changes{Delete: []*Endpoint{
+				{DNSName: "www.example.com", RecordType: RecordTypeA, Targets: []string{"198.51.100.1"}},
+			}},
+			setup: func(m *mocks.Interface) {
+				m.On("DeleteARecord", mock.Anything, int32(7), "www.example.com.").Return(nil)
+			},
+		},
+		{
+			name: "update AAAA records",
+			changes: Changes{UpdateNew: []*Endpoint{
+				{DNSName: "www.example.com", RecordType: RecordTypeAAAA, Targets: []string{"2001:db8::1", "2001:db8::3"}, RecordTTL: 60},
+			}},
+			setup: func(m *mocks.Interface) {
+				m.On("DeleteAAAARecord", mock.Anything, int32(7), int32(2)).Return(nil)
+				m.On("CreateAAAARecord", mock.Anything, int32(7), hv.AaaaRecordCreate{
+					Name: "www.example.com", Address: "2001:db8::3", Ttl: 60,
+				}).Return(nil)
+			},
+		},
+		{
+			name: "delete and create MX records",
+			changes: Changes{
+				Delete: []*Endpoint{{DNSName: "example.com", RecordType: RecordTypeMX, Targets: []string{"10 mail.example.com"}}},
+				Create: []*Endpoint{{DNSName: "example.com", RecordType: RecordTypeMX, Targets: []string{"20 mx.example.org."}}},
+			},
+			setup: func(m *mocks.Interface) {
+				m.On("DeleteMXRecord", mock.Anything, int32(7), int32(3)).Return(nil)
+				m.On("CreateMXRecord", mock.Anything, int32(7), hv.MxRecordCreate{
+					Name: "example.com", Preference: 20, Exchange: "mx.example.org", Ttl: DefaultTTL,
+				}).Return(nil)
+			},
+		},
+		{
+			name: "outside of the zones",
+			changes: Changes{Create: []*Endpoint{
+				{DNSName: "www.example.org", RecordType: RecordTypeA, Targets: []string{"198.51.100.1"}},
+			}},
+		},
+		{
+			name: "invalid MX target",
+			changes: Changes{Create: []*Endpoint{
+				{DNSName: "example.com", RecordType: RecordTypeMX, Targets: []string{"mail.example.com"}},
+			}},
+			wantErr: ErrInvalidMXTarget,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p, m := newTestProvider(t)
+			mockRecords(m, 7,
+				[]hv.ARecord{{Name: "www.example.com.", Addresses: []string{"198.51.100.1"}, Ttl: 60}},
+				[]hv.AaaaRecordReturn{
+					{Id: 1, Name: "www.example.com", Address: "2001:db8::1", Ttl: 60},
+					{Id: 2, Name: "www.example.com", Address: "2001:db8::2", Ttl: 60},
+				},
+				[]hv.MxRecordReturn{{Id: 3, Name: "example.com", Preference: 10, Exchange: "mail.example.com", Ttl: 300}},
+			)
+			mockRecords(m, 42, nil, nil, nil)
+			if tt.setup != nil {
+				tt.setup(m)
+			}
+
+			err := p.ApplyChanges(context.Background(), &tt.changes)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
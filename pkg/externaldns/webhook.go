@@ -0,0 +1,120 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package externaldns
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"k8s.io/klog/v2"
+)
+
+// MediaType is the media type of the webhook protocol of external-dns.
+const MediaType = "application/external.dns.webhook+json;version=1"
+
+// NewHandler returns the handler of the webhook protocol of external-dns:
+//
+//   - GET / negotiates the domain filter.
+//   - GET /records returns the current records.
+//   - POST /records applies the changes.
+//   - POST /adjustendpoints adjusts the desired endpoints.
+//
+// Failed requests get status 500, so that external-dns retries them on its
+// next synchronization.
+func NewHandler(p *Provider) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		filter, err := p.DomainFilter(r.Context())
+		if err != nil {
+			internalError(w, "negotiate", err)
+			return
+		}
+		writeJSON(w, filter)
+	})
+	mux.HandleFunc("/records", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			endpoints, err := p.Records(r.Context())
+			if err != nil {
+				internalError(w, "records", err)
+				return
+			}
+			if endpoints == nil {
+				endpoints = []*Endpoint{}
+			}
+			writeJSON(w, endpoints)
+		case http.MethodPost:
+			var changes Changes
+			if err := json.NewDecoder(r.Body).Decode(&changes); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := p.ApplyChanges(r.Context(), &changes); err != nil {
+				internalError(w, "apply changes", err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			allowMethod(w, r, http.MethodGet, http.MethodPost)
+		}
+	})
+	mux.HandleFunc("/adjustendpoints", func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		var endpoints []*Endpoint
+		if err := json.NewDecoder(r.Body).Decode(&endpoints); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, p.AdjustEndpoints(endpoints))
+	})
+	return mux
+}
+
+// allowMethod responds with status 405 if the method of the request is not one of methods.
+func allowMethod(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	for _, method := range methods {
+		w.Header().Add("Allow", method)
+	}
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return false
+}
+
+func internalError(w http.ResponseWriter, operation string, err error) {
+	klog.Errorf("[externaldns] %s failed: %v", operation, err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", MediaType)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		klog.Errorf("[externaldns] writing response failed: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package externaldns
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewHandler(t *testing.T) {
+	t.Parallel()
+	p, m := newTestProvider(t)
+	mockRecords(m, 7, []hv.ARecord{{Name: "www.example.com", Addresses: []string{"198.51.100.1"}, Ttl: 60}}, nil, nil)
+	m.On("ListARecords", mock.Anything, int32(42)).Return(nil, errors.New("unavailable"))
+	m.On("ListAAAARecords", mock.Anything, int32(42)).Return(nil, nil).Maybe()
+	m.On("ListMXRecords", mock.Anything, int32(42)).Return(nil, nil).Maybe()
+	handler := NewHandler(p)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "negotiate",
+			method:     http.MethodGet,
+			path:       "/",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"include":["example.com","sub.example.com"]}`,
+		},
+		{
+			name:       "adjust endpoints",
+			method:     http.MethodPost,
+			path:       "/adjustendpoints",
+			body:       `[{"dnsName":"www.example.com.","recordType":"A","targets":["198.51.100.1"]}]`,
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"dnsName":"www.example.com","targets":["198.51.100.1"],"recordType":"A","recordTTL":3600}]`,
+		},
+		{
+			name:       "records fail",
+			method:     http.MethodGet,
+			path:       "/records",
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "apply changes",
+			method:     http.MethodPost,
+			path:       "/records",
+			body:       `{"Delete":[{"dnsName":"www.example.org","recordType":"A","targets":["198.51.100.1"]}]}`,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "invalid changes",
+			method:     http.MethodPost,
+			path:       "/records",
+			body:       `{`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "method not allowed",
+			method:     http.MethodDelete,
+			path:       "/records",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "not found",
+			method:     http.MethodGet,
+			path:       "/healthz",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			recorder := httptest.NewRecorder()
+			request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			request.Header.Set("Accept", MediaType)
+			handler.ServeHTTP(recorder, request)
+
+			require.Equal(t, tt.wantStatus, recorder.Code)
+			if tt.wantBody != "" {
+				require.Equal(t, MediaType, recorder.Header().Get("Content-Type"))
+				require.JSONEq(t, tt.wantBody, recorder.Body.String())
+			}
+		})
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"strings"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/validation"
 )
 
@@ -40,8 +41,67 @@ var (
 
 	// ErrNoMachineNameFound gets returned if no caphv-machine-name tag was found via the HV API.
 	ErrNoMachineNameFound = fmt.Errorf("no caphv-machine-name tag found")
+
+	// ErrNoClusterNameFound gets returned if no caphv-cluster-name tag was found via the HV API.
+	ErrNoClusterNameFound = fmt.Errorf("no caphv-cluster-name tag found")
+
+	// ErrMoreThanOneClusterNameFound gets returned if more than one caphv-cluster-name tag was found via the HV API.
+	ErrMoreThanOneClusterNameFound = fmt.Errorf("more than one caphv-cluster-name tag found")
+
+	// ErrInvalidNodeTag gets returned if a k8s-label/ or k8s-taint/ tag can't be parsed.
+	ErrInvalidNodeTag = fmt.Errorf("invalid node tag")
+)
+
+const (
+	// LabelTagPrefix is the prefix of device tags which become node labels.
+	// Example: "k8s-label/gpu=true".
+	LabelTagPrefix = "k8s-label/"
+
+	// TaintTagPrefix is the prefix of device tags which become node taints.
+	// Example: "k8s-taint/dedicated=db:NoSchedule" or "k8s-taint/dedicated:NoSchedule".
+	TaintTagPrefix = "k8s-taint/"
+
+	// ClusterNameTagPrefix is the prefix of the device tag which contains the name
+	// of the cluster the device belongs to. Example: "caphv-cluster-name=prod".
+	ClusterNameTagPrefix = "caphv-cluster-name="
 )
 
+// HasClusterNameTag returns true if the tags contain the caphv-cluster-name tag of the cluster.
+func HasClusterNameTag(tags []string, clusterName string) bool {
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, ClusterNameTagPrefix) {
+			continue
+		}
+		if strings.TrimSpace(strings.TrimPrefix(tag, ClusterNameTagPrefix)) == clusterName {
+			return true
+		}
+	}
+	return false
+}
+
+// GetClusterNameFromTags returns the value of the caphv-cluster-name tag.
+// Example: {"caphv-cluster-name=prod", "other-label"} would return "prod".
+func GetClusterNameFromTags(tags []string) (string, error) {
+	clusterNames := make([]string, 0, 1)
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag, ClusterNameTagPrefix) {
+			continue
+		}
+		clusterNames = append(clusterNames, strings.TrimSpace(strings.TrimPrefix(tag, ClusterNameTagPrefix)))
+	}
+	if len(clusterNames) == 0 {
+		return "", ErrNoClusterNameFound
+	}
+	if len(clusterNames) > 1 {
+		return "", fmt.Errorf(
+			"[GetClusterNameFromTags] more than one cluster name. clusterNames %v: %w",
+			clusterNames,
+			ErrMoreThanOneClusterNameFound,
+		)
+	}
+	return clusterNames[0], nil
+}
+
 // GetInstanceTypeFromTags is a utility method to read the caphv-device-type
 // from a slice of strings.
 // The slice is usually from the Hivelocity API of a device.
@@ -110,3 +170,99 @@ func GetMachineNameFromTags(tags []string) (string, error) {
 
 	return machineNames[0], nil
 }
+
+// GetNodeLabelsAndTaintsFromTags reads the node labels and taints from the
+// k8s-label/ and k8s-taint/ tags of a device. Other tags are ignored.
+// Invalid tags are skipped, an error gets returned for each of them.
+func GetNodeLabelsAndTaintsFromTags(tags []string) (map[string]string, []corev1.Taint, []error) {
+	labels := make(map[string]string)
+	var taints []corev1.Taint
+	var errs []error
+
+	for _, tag := range tags {
+		switch {
+		case strings.HasPrefix(tag, LabelTagPrefix):
+			key, value, err := ParseLabelTag(tag)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if existing, ok := labels[key]; ok && existing != value {
+				errs = append(errs, fmt.Errorf(
+					"[GetNodeLabelsAndTaintsFromTags] conflicting values %q and %q of label %q: %w",
+					existing, value, key, ErrInvalidNodeTag))
+				continue
+			}
+			labels[key] = value
+
+		case strings.HasPrefix(tag, TaintTagPrefix):
+			taint, err := ParseTaintTag(tag)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if containsTaint(taints, taint) {
+				errs = append(errs, fmt.Errorf(
+					"[GetNodeLabelsAndTaintsFromTags] more than one taint %s:%s: %w",
+					taint.Key, taint.Effect, ErrInvalidNodeTag))
+				continue
+			}
+			taints = append(taints, taint)
+		}
+	}
+	return labels, taints, errs
+}
+
+// ParseLabelTag parses a tag like "k8s-label/gpu=true" into key and value.
+func ParseLabelTag(tag string) (key, value string, err error) {
+	key, value, found := strings.Cut(strings.TrimPrefix(tag, LabelTagPrefix), "=")
+	if !found {
+		return "", "", fmt.Errorf("[ParseLabelTag] tag %q has no \"=\": %w", tag, ErrInvalidNodeTag)
+	}
+	if err := validateKeyValue(key, value); err != nil {
+		return "", "", fmt.Errorf("[ParseLabelTag] tag %q: %w", tag, err)
+	}
+	return key, value, nil
+}
+
+// ParseTaintTag parses a tag like "k8s-taint/dedicated=db:NoSchedule" into a taint.
+// The value is optional: "k8s-taint/dedicated:NoSchedule".
+func ParseTaintTag(tag string) (corev1.Taint, error) {
+	spec := strings.TrimPrefix(tag, TaintTagPrefix)
+	i := strings.LastIndex(spec, ":")
+	if i < 0 {
+		return corev1.Taint{}, fmt.Errorf("[ParseTaintTag] tag %q has no effect: %w", tag, ErrInvalidNodeTag)
+	}
+	keyValue, effect := spec[:i], corev1.TaintEffect(spec[i+1:])
+
+	switch effect {
+	case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+	default:
+		return corev1.Taint{}, fmt.Errorf("[ParseTaintTag] tag %q has invalid effect %q: %w", tag, effect, ErrInvalidNodeTag)
+	}
+
+	key, value, _ := strings.Cut(keyValue, "=")
+	if err := validateKeyValue(key, value); err != nil {
+		return corev1.Taint{}, fmt.Errorf("[ParseTaintTag] tag %q: %w", tag, err)
+	}
+	return corev1.Taint{Key: key, Value: value, Effect: effect}, nil
+}
+
+func validateKeyValue(key, value string) error {
+	if errs := validation.IsQualifiedName(key); len(errs) != 0 {
+		return fmt.Errorf("invalid key %q: %s: %w", key, strings.Join(errs, "; "), ErrInvalidNodeTag)
+	}
+	if errs := validation.IsValidLabelValue(value); len(errs) != 0 {
+		return fmt.Errorf("invalid value %q: %s: %w", value, strings.Join(errs, "; "), ErrInvalidNodeTag)
+	}
+	return nil
+}
+
+func containsTaint(taints []corev1.Taint, taint corev1.Taint) bool {
+	for i := range taints {
+		if taints[i].MatchTaint(&taint) {
+			return true
+		}
+	}
+	return false
+}
@@ -21,6 +21,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
 )
 
 func Test_getInstanceTypeFromTags(t *testing.T) {
@@ -70,3 +71,89 @@ func Test_getInstanceTypeFromTags(t *testing.T) {
 		})
 	}
 }
+
+func Test_GetNodeLabelsAndTaintsFromTags(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		tags       []string
+		wantLabels map[string]string
+		wantTaints []corev1.Taint
+		wantErrs   int
+	}{
+		{
+			name:       "no node tags",
+			tags:       []string{"caphv-device-type=abc", "foo"},
+			wantLabels: map[string]string{},
+		},
+		{
+			name: "labels and taints",
+			tags: []string{
+				"k8s-label/gpu=true",
+				"k8s-label/example.com/pool=",
+				"k8s-taint/dedicated=db:NoSchedule",
+				"k8s-taint/example.com/maintenance:NoExecute",
+			},
+			wantLabels: map[string]string{"gpu": "true", "example.com/pool": ""},
+			wantTaints: []corev1.Taint{
+				{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "example.com/maintenance", Effect: corev1.TaintEffectNoExecute},
+			},
+		},
+		{
+			name: "invalid tags are skipped",
+			tags: []string{
+				"k8s-label/gpu",
+				"k8s-label/gpu=&",
+				"k8s-label/-bad=x",
+				"k8s-taint/dedicated=db",
+				"k8s-taint/dedicated=db:Sometimes",
+				"k8s-label/ok=yes",
+			},
+			wantLabels: map[string]string{"ok": "yes"},
+			wantErrs:   5,
+		},
+		{
+			name:       "conflicting duplicates",
+			tags:       []string{"k8s-label/a=1", "k8s-label/a=2", "k8s-taint/t:NoSchedule", "k8s-taint/t=x:NoSchedule"},
+			wantLabels: map[string]string{"a": "1"},
+			wantTaints: []corev1.Taint{{Key: "t", Effect: corev1.TaintEffectNoSchedule}},
+			wantErrs:   2,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			labels, taints, errs := GetNodeLabelsAndTaintsFromTags(tt.tags)
+			require.Equal(t, tt.wantLabels, labels)
+			require.Equal(t, tt.wantTaints, taints)
+			require.Len(t, errs, tt.wantErrs)
+			for _, err := range errs {
+				require.ErrorIs(t, err, ErrInvalidNodeTag)
+			}
+		})
+	}
+}
+
+func Test_HasClusterNameTag(t *testing.T) {
+	t.Parallel()
+	tags := []string{"caphv-machine-name=prod-cp-1", "caphv-cluster-name=prod"}
+	require.True(t, HasClusterNameTag(tags, "prod"))
+	require.False(t, HasClusterNameTag(tags, "staging"))
+	require.False(t, HasClusterNameTag([]string{"prod"}, "prod"))
+	require.False(t, HasClusterNameTag(nil, "prod"))
+}
+
+func Test_GetClusterNameFromTags(t *testing.T) {
+	t.Parallel()
+	name, err := GetClusterNameFromTags([]string{"caphv-machine-name=prod-cp-1", "caphv-cluster-name=prod"})
+	require.NoError(t, err)
+	require.Equal(t, "prod", name)
+
+	_, err = GetClusterNameFromTags([]string{"caphv-machine-name=prod-cp-1"})
+	require.ErrorIs(t, err, ErrNoClusterNameFound)
+
+	_, err = GetClusterNameFromTags([]string{"caphv-cluster-name=prod", "caphv-cluster-name=staging"})
+	require.ErrorIs(t, err, ErrMoreThanOneClusterNameFound)
+}
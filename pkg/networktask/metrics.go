@@ -0,0 +1,68 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networktask
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+var (
+	tasksTotal = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Namespace:      "hivelocity",
+			Subsystem:      "network_task",
+			Name:           "finished_total",
+			Help:           "Number of finished network tasks. Result is success or failed.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"controller", "result"},
+	)
+
+	taskDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Namespace:      "hivelocity",
+			Subsystem:      "network_task",
+			Name:           "duration_seconds",
+			Help:           "Time from tracking a network task until it finished. Result is success or failed.",
+			Buckets:        metrics.ExponentialBuckets(1, 2, 12),
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"controller", "result"},
+	)
+
+	tasksPending = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Namespace:      "hivelocity",
+			Subsystem:      "network_task",
+			Name:           "pending",
+			Help:           "Number of network tasks which are tracked and not finished yet.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"controller"},
+	)
+)
+
+var registerMetricsOnce sync.Once
+
+func registerMetrics() {
+	registerMetricsOnce.Do(func() {
+		legacyregistry.MustRegister(tasksTotal, taskDuration, tasksPending)
+	})
+}
@@ -0,0 +1,341 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package networktask tracks the asynchronous network tasks of the Hivelocity
+// API, like updates of VLANs, ports and IP assignments.
+//
+// The IDs of the pending tasks get persisted in an annotation of the objects
+// they were started for. After a restart or a leader failover, Resume picks
+// them up again from the annotations.
+package networktask
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+)
+
+// AnnotationKey contains the comma separated IDs of the pending network tasks of an object.
+const AnnotationKey = "hivelocity.net/network-tasks"
+
+// Results of network tasks. An empty result means pending, too.
+const (
+	ResultSuccess = "Success"
+	ResultFailed  = "Failed"
+)
+
+const (
+	defaultInitialDelay = 2 * time.Second
+	defaultMaxDelay     = time.Minute
+	defaultTimeout      = time.Hour
+
+	// finishedRetention is the time finished tasks are remembered, so that
+	// annotations which are not updated in the informer cache yet do not
+	// resume them.
+	finishedRetention = time.Hour
+)
+
+// Client reads network tasks. It is implemented by client.Interface.
+type Client interface {
+	GetNetworkTask(ctx context.Context, taskID string) (*hv.NetworkTaskDump, error)
+}
+
+// Object is an object a network task gets tracked for, like a node or a service.
+type Object interface {
+	metav1.Object
+	runtime.Object
+}
+
+// PatchFunc sets the annotation key of the object to value. An empty value removes the annotation.
+type PatchFunc func(ctx context.Context, obj Object, key, value string) error
+
+// Result is a finished network task.
+type Result struct {
+	TaskID string
+
+	// Failed is true if the task failed.
+	Failed bool
+
+	// Objects are the objects the task was tracked for.
+	Objects []Object
+}
+
+// Options configure a Tracker.
+type Options struct {
+	// Controller is the name of the controller. It is the label of the metrics.
+	Controller string
+
+	Client   Client
+	Recorder record.EventRecorder
+	Patch    PatchFunc
+
+	// InitialDelay is the time until a task gets polled first. The delay
+	// doubles with every poll up to MaxDelay.
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+
+	// Timeout is the time after which a task which has not finished, or
+	// cannot be read, is given up and reported as failed. A resumed task
+	// gets the full timeout again.
+	Timeout time.Duration
+
+	// OnFinished gets called for every finished task. It is optional.
+	OnFinished func(ctx context.Context, result Result)
+}
+
+// Tracker polls network tasks until they finish. Finished tasks get reported
+// via Events on their objects, metrics and Options.OnFinished.
+type Tracker struct {
+	opts Options
+	now  func() time.Time
+
+	mu       sync.Mutex
+	tasks    map[string]*task
+	finished map[string]time.Time
+}
+
+type task struct {
+	id       string
+	objects  map[string]Object
+	started  time.Time
+	nextPoll time.Time
+	polls    int
+}
+
+// New creates a Tracker. Run needs to be called to poll the tasks.
+func New(opts Options) *Tracker {
+	registerMetrics()
+	if opts.InitialDelay <= 0 {
+		opts.InitialDelay = defaultInitialDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = defaultMaxDelay
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	return &Tracker{
+		opts:     opts,
+		now:      time.Now,
+		tasks:    make(map[string]*task),
+		finished: make(map[string]time.Time),
+	}
+}
+
+// Run polls the tasks until ctx is done.
+func (t *Tracker) Run(ctx context.Context) {
+	wait.UntilWithContext(ctx, t.Poll, time.Second)
+}
+
+// Track starts tracking the task for the objects. The task ID gets added to
+// the annotation of the objects. Objects are optional: a task without objects
+// is only tracked in memory.
+func (t *Tracker) Track(ctx context.Context, taskID string, objs ...Object) error {
+	t.mu.Lock()
+	tt := t.addLocked(taskID, t.now().Add(t.opts.InitialDelay))
+	for _, obj := range objs {
+		tt.objects[objectKey(obj)] = obj
+	}
+	t.updatePendingLocked()
+	t.mu.Unlock()
+
+	var firstErr error
+	for _, obj := range objs {
+		if err := t.patch(ctx, obj); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Resume tracks the tasks in the annotations of the objects which are not
+// tracked yet. It is cheap and can be called on every reconciliation.
+func (t *Tracker) Resume(objs ...Object) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, obj := range objs {
+		for _, taskID := range splitTaskIDs(obj.GetAnnotations()[AnnotationKey]) {
+			if _, ok := t.finished[taskID]; ok {
+				continue
+			}
+			tt := t.addLocked(taskID, t.now())
+			tt.objects[objectKey(obj)] = obj
+		}
+	}
+	t.updatePendingLocked()
+}
+
+// Len returns the number of pending tasks.
+func (t *Tracker) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.tasks)
+}
+
+// Pending returns true if a task is pending for the object.
+func (t *Tracker) Pending(obj Object) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.taskIDsLocked(objectKey(obj))) > 0
+}
+
+// Poll reads the tasks which are due.
+func (t *Tracker) Poll(ctx context.Context) {
+	now := t.now()
+	t.mu.Lock()
+	var due []*task
+	for _, tt := range t.tasks {
+		if !tt.nextPoll.After(now) {
+			due = append(due, tt)
+		}
+	}
+	for id, finishedAt := range t.finished {
+		if now.Sub(finishedAt) > finishedRetention {
+			delete(t.finished, id)
+		}
+	}
+	t.mu.Unlock()
+
+	for _, tt := range due {
+		dump, err := t.opts.Client.GetNetworkTask(ctx, tt.id)
+		switch {
+		case err != nil:
+			klog.Errorf("[networktask] %s: reading network task %q failed: %v", t.opts.Controller, tt.id, err)
+		case dump.Result == ResultSuccess:
+			t.finish(ctx, tt, false)
+			continue
+		case dump.Result == ResultFailed:
+			t.finish(ctx, tt, true)
+			continue
+		}
+
+		if now.Sub(tt.started) >= t.opts.Timeout {
+			klog.Errorf("[networktask] %s: giving up network task %q after %s",
+				t.opts.Controller, tt.id, t.opts.Timeout)
+			t.finish(ctx, tt, true)
+			continue
+		}
+		t.reschedule(tt)
+	}
+}
+
+func (t *Tracker) reschedule(tt *task) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tt.polls++
+	delay := t.opts.InitialDelay << tt.polls
+	if delay > t.opts.MaxDelay || delay <= 0 {
+		delay = t.opts.MaxDelay
+	}
+	tt.nextPoll = t.now().Add(delay)
+}
+
+func (t *Tracker) finish(ctx context.Context, tt *task, failed bool) {
+	now := t.now()
+	t.mu.Lock()
+	delete(t.tasks, tt.id)
+	t.finished[tt.id] = now
+	t.updatePendingLocked()
+	objs := make([]Object, 0, len(tt.objects))
+	for _, obj := range tt.objects {
+		objs = append(objs, obj)
+	}
+	t.mu.Unlock()
+	sort.Slice(objs, func(i, j int) bool { return objectKey(objs[i]) < objectKey(objs[j]) })
+
+	result, eventType, reason := "success", corev1.EventTypeNormal, "NetworkTaskSucceeded"
+	if failed {
+		result, eventType, reason = "failed", corev1.EventTypeWarning, "NetworkTaskFailed"
+		klog.Errorf("[networktask] %s: network task %q failed", t.opts.Controller, tt.id)
+	} else {
+		klog.Infof("[networktask] %s: network task %q succeeded", t.opts.Controller, tt.id)
+	}
+	tasksTotal.WithLabelValues(t.opts.Controller, result).Inc()
+	taskDuration.WithLabelValues(t.opts.Controller, result).Observe(now.Sub(tt.started).Seconds())
+
+	for _, obj := range objs {
+		t.opts.Recorder.Eventf(obj, eventType, reason, "Network task %q finished with result %s", tt.id, result)
+		if err := t.patch(ctx, obj); err != nil {
+			klog.Errorf("[networktask] %s: %v", t.opts.Controller, err)
+		}
+	}
+
+	if t.opts.OnFinished != nil {
+		t.opts.OnFinished(ctx, Result{TaskID: tt.id, Failed: failed, Objects: objs})
+	}
+}
+
+// patch writes the pending tasks of the object to its annotation.
+func (t *Tracker) patch(ctx context.Context, obj Object) error {
+	t.mu.Lock()
+	value := strings.Join(t.taskIDsLocked(objectKey(obj)), ",")
+	t.mu.Unlock()
+	return t.opts.Patch(ctx, obj, AnnotationKey, value)
+}
+
+func (t *Tracker) addLocked(taskID string, nextPoll time.Time) *task {
+	tt, ok := t.tasks[taskID]
+	if !ok {
+		tt = &task{
+			id:       taskID,
+			objects:  make(map[string]Object),
+			started:  t.now(),
+			nextPoll: nextPoll,
+		}
+		t.tasks[taskID] = tt
+	}
+	return tt
+}
+
+func (t *Tracker) taskIDsLocked(key string) []string {
+	var ids []string
+	for id, tt := range t.tasks {
+		if _, ok := tt.objects[key]; ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (t *Tracker) updatePendingLocked() {
+	tasksPending.WithLabelValues(t.opts.Controller).Set(float64(len(t.tasks)))
+}
+
+func objectKey(obj Object) string {
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
+func splitTaskIDs(value string) []string {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2023 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networktask
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	hv "github.com/hivelocity/hivelocity-client-go/client"
+	"github.com/hivelocity/hivelocity-cloud-controller-manager/client/mocks"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+type fakeAnnotations struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+func (f *fakeAnnotations) patch(_ context.Context, obj Object, key, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.values[obj.GetName()+"/"+key] = value
+	return nil
+}
+
+func newTestTracker(t *testing.T, m *mocks.Interface) (*Tracker, *fakeAnnotations, *record.FakeRecorder, *time.Time) {
+	t.Helper()
+	annotations := &fakeAnnotations{values: make(map[string]string)}
+	recorder := record.NewFakeRecorder(10)
+	tracker := New(Options{
+		Controller:   "test",
+		Client:       m,
+		Recorder:     recorder,
+		Patch:        annotations.patch,
+		InitialDelay: time.Second,
+		MaxDelay:     5 * time.Second,
+	})
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+	return tracker, annotations, recorder, &now
+}
+
+func newTestNode(name string, annotations map[string]string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+}
+
+func Test_Tracker(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	tracker, annotations, recorder, now := newTestTracker(t, m)
+	var results []Result
+	tracker.opts.OnFinished = func(_ context.Context, result Result) {
+		results = append(results, result)
+	}
+	ctx := context.Background()
+	node := newTestNode("node-1", nil)
+
+	require.NoError(t, tracker.Track(ctx, "task-1", node))
+	require.Equal(t, "task-1", annotations.values["node-1/"+AnnotationKey])
+	require.True(t, tracker.Pending(node))
+
+	// Not due yet.
+	tracker.Poll(ctx)
+
+	*now = now.Add(time.Second)
+	m.On("GetNetworkTask", mock.Anything, "task-1").Return(&hv.NetworkTaskDump{TaskId: "task-1"}, nil).Once()
+	tracker.Poll(ctx)
+	require.Equal(t, now.Add(2*time.Second), tracker.tasks["task-1"].nextPoll)
+
+	*now = now.Add(2 * time.Second)
+	m.On("GetNetworkTask", mock.Anything, "task-1").
+		Return(&hv.NetworkTaskDump{TaskId: "task-1", Result: ResultFailed}, nil).Once()
+	tracker.Poll(ctx)
+
+	require.Zero(t, tracker.Len())
+	require.False(t, tracker.Pending(node))
+	require.Equal(t, "", annotations.values["node-1/"+AnnotationKey])
+	require.Contains(t, <-recorder.Events, "NetworkTaskFailed")
+	require.Len(t, results, 1)
+	require.True(t, results[0].Failed)
+	require.Equal(t, []Object{node}, results[0].Objects)
+
+	// A stale annotation does not resume the finished task.
+	tracker.Resume(newTestNode("node-1", map[string]string{AnnotationKey: "task-1"}))
+	require.Zero(t, tracker.Len())
+}
+
+func Test_Tracker_Resume(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	tracker, annotations, recorder, _ := newTestTracker(t, m)
+	ctx := context.Background()
+
+	tracker.Resume(
+		newTestNode("node-1", map[string]string{AnnotationKey: "task-1,task-2"}),
+		newTestNode("node-2", map[string]string{AnnotationKey: "task-2"}),
+		newTestNode("node-3", nil),
+	)
+	require.Equal(t, 2, tracker.Len())
+
+	// Resumed tasks are polled immediately.
+	m.On("GetNetworkTask", mock.Anything, "task-1").Return(&hv.NetworkTaskDump{TaskId: "task-1"}, nil)
+	m.On("GetNetworkTask", mock.Anything, "task-2").
+		Return(&hv.NetworkTaskDump{TaskId: "task-2", Result: ResultSuccess}, nil)
+	tracker.Poll(ctx)
+
+	require.Equal(t, 1, tracker.Len())
+	require.Equal(t, "task-1", annotations.values["node-1/"+AnnotationKey])
+	require.Equal(t, "", annotations.values["node-2/"+AnnotationKey])
+	require.Contains(t, <-recorder.Events, "NetworkTaskSucceeded")
+	require.Contains(t, <-recorder.Events, "NetworkTaskSucceeded")
+}
+
+func Test_Tracker_timeout(t *testing.T) {
+	t.Parallel()
+	m := mocks.NewInterface(t)
+	tracker, annotations, recorder, now := newTestTracker(t, m)
+	ctx := context.Background()
+	node := newTestNode("node-1", nil)
+	require.NoError(t, tracker.Track(ctx, "task-1", node))
+
+	m.On("GetNetworkTask", mock.Anything, "task-1").Return(nil, errors.New("not found"))
+	*now = now.Add(time.Minute)
+	tracker.Poll(ctx)
+	require.Equal(t, 1, tracker.Len())
+
+	// The task is given up after the timeout.
+	*now = now.Add(time.Hour)
+	tracker.Poll(ctx)
+	require.Zero(t, tracker.Len())
+	require.Equal(t, "", annotations.values["node-1/"+AnnotationKey])
+	require.Contains(t, <-recorder.Events, "NetworkTaskFailed")
+}
+
+func Test_Tracker_backoff(t *testing.T) {
+	t.Parallel()
+	tracker, _, _, now := newTestTracker(t, mocks.NewInterface(t))
+	tt := tracker.addLocked("task-1", *now)
+
+	var delays []time.Duration
+	for i := 0; i < 4; i++ {
+		tracker.reschedule(tt)
+		delays = append(delays, tt.nextPoll.Sub(*now))
+	}
+	require.Equal(t, []time.Duration{2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}, delays)
+}